@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Environment variables recognised by studentd.
+const (
+	envConfigFile      = "STUDENTD_CONFIG"
+	envAddr            = "STUDENTD_ADDR"
+	envBackend         = "STUDENTD_BACKEND"
+	envSQLiteDSN       = "STUDENTD_SQLITE_DSN"
+	envReadTimeout     = "STUDENTD_READ_TIMEOUT"
+	envWriteTimeout    = "STUDENTD_WRITE_TIMEOUT"
+	envIdleTimeout     = "STUDENTD_IDLE_TIMEOUT"
+	envShutdownTimeout = "STUDENTD_SHUTDOWN_TIMEOUT"
+	envTrashRetention  = "STUDENTD_TRASH_RETENTION"
+	envPurgeInterval   = "STUDENTD_PURGE_INTERVAL"
+	envOutboxSinks     = "STUDENTD_OUTBOX_SINKS"
+	envOutboxInterval  = "STUDENTD_OUTBOX_INTERVAL"
+	envAuthJWKSFile    = "STUDENTD_AUTH_JWKS_FILE"
+	envAuthIssuer      = "STUDENTD_AUTH_ISSUER"
+	envAuthAudience    = "STUDENTD_AUTH_AUDIENCE"
+)
+
+// Supported repository backends.
+const (
+	backendMemory = "memory"
+	backendSQLite = "sqlite"
+)
+
+// Config holds the runtime settings of the studentd server.
+// Values are resolved in order: defaults, config file, environment, flags.
+type Config struct {
+	Addr            string   `json:"addr"`
+	Backend         string   `json:"backend"`
+	SQLiteDSN       string   `json:"sqlite_dsn"`
+	ReadTimeout     Duration `json:"read_timeout"`
+	WriteTimeout    Duration `json:"write_timeout"`
+	IdleTimeout     Duration `json:"idle_timeout"`
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+
+	// TrashRetention is how long deleted students stay restorable before
+	// the purge job removes them; PurgeInterval is how often it runs,
+	// 0 disabling it.
+	TrashRetention Duration `json:"trash_retention"`
+	PurgeInterval  Duration `json:"purge_interval"`
+
+	// OutboxSinks are the URLs every student change is POSTed to by the
+	// outbox relay, besides the registered webhooks. The relay and the
+	// webhook dispatcher poll every OutboxInterval.
+	OutboxSinks    []string `json:"outbox_sinks"`
+	OutboxInterval Duration `json:"outbox_interval"`
+
+	// AuthJWKSFile is a JWKS file whose keys sign the bearer tokens every
+	// API request must carry; without it requests are not authenticated.
+	// Tokens must also be issued by AuthIssuer and for AuthAudience when
+	// they are set.
+	AuthJWKSFile string `json:"auth_jwks_file"`
+	AuthIssuer   string `json:"auth_issuer"`
+	AuthAudience string `json:"auth_audience"`
+}
+
+// Duration is a time.Duration that reads from JSON strings such as "15s".
+type Duration time.Duration
+
+// UnmarshalJSON parses a Go duration string.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON renders the duration as a Go duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// defaultConfig returns the settings used when nothing else is provided.
+func defaultConfig() *Config {
+	return &Config{
+		Addr:            ":8080",
+		Backend:         backendMemory,
+		SQLiteDSN:       "studentd.db",
+		ReadTimeout:     Duration(15 * time.Second),
+		WriteTimeout:    Duration(15 * time.Second),
+		IdleTimeout:     Duration(60 * time.Second),
+		ShutdownTimeout: Duration(10 * time.Second),
+		TrashRetention:  Duration(30 * 24 * time.Hour),
+		PurgeInterval:   Duration(time.Hour),
+		OutboxInterval:  Duration(5 * time.Second),
+	}
+}
+
+// loadConfig resolves the server configuration from the command line
+// arguments, the environment and an optional JSON config file.
+func loadConfig(args []string, getenv func(string) string) (*Config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("studentd", flag.ContinueOnError)
+	configFile := fs.String("config", getenv(envConfigFile), "path to a JSON config file")
+	addr := fs.String("addr", "", "listen address (e.g. :8080)")
+	backend := fs.String("backend", "", "repository backend: memory or sqlite")
+	sqliteDSN := fs.String("sqlite-dsn", "", "SQLite database path used by the sqlite backend")
+	readTimeout := fs.Duration("read-timeout", 0, "HTTP read timeout")
+	writeTimeout := fs.Duration("write-timeout", 0, "HTTP write timeout")
+	idleTimeout := fs.Duration("idle-timeout", 0, "HTTP idle timeout")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "time allowed for in-flight requests to drain")
+	trashRetention := fs.Duration("trash-retention", 0, "how long deleted students can be restored before they are purged")
+	purgeInterval := fs.Duration("purge-interval", 0, "how often expired students are purged from the trash (0 disables)")
+	outboxSinks := fs.String("outbox-sinks", "", "comma-separated URLs that student changes are delivered to")
+	outboxInterval := fs.Duration("outbox-interval", 0, "how often the outbox is relayed to the sinks and webhooks are dispatched")
+	authJWKSFile := fs.String("auth-jwks-file", "", "JWKS file with the keys of accepted bearer tokens (empty disables authentication)")
+	authIssuer := fs.String("auth-issuer", "", "required iss claim of bearer tokens")
+	authAudience := fs.String("auth-audience", "", "required aud claim of bearer tokens")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.readFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	// Only flags given explicitly override earlier sources.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = *addr
+		case "backend":
+			cfg.Backend = *backend
+		case "sqlite-dsn":
+			cfg.SQLiteDSN = *sqliteDSN
+		case "read-timeout":
+			cfg.ReadTimeout = Duration(*readTimeout)
+		case "write-timeout":
+			cfg.WriteTimeout = Duration(*writeTimeout)
+		case "idle-timeout":
+			cfg.IdleTimeout = Duration(*idleTimeout)
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = Duration(*shutdownTimeout)
+		case "trash-retention":
+			cfg.TrashRetention = Duration(*trashRetention)
+		case "purge-interval":
+			cfg.PurgeInterval = Duration(*purgeInterval)
+		case "outbox-sinks":
+			cfg.OutboxSinks = splitList(*outboxSinks)
+		case "outbox-interval":
+			cfg.OutboxInterval = Duration(*outboxInterval)
+		case "auth-jwks-file":
+			cfg.AuthJWKSFile = *authJWKSFile
+		case "auth-issuer":
+			cfg.AuthIssuer = *authIssuer
+		case "auth-audience":
+			cfg.AuthAudience = *authAudience
+		}
+	})
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile overlays the values found in a JSON config file.
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overlays the values found in STUDENTD_* environment variables.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	if v := getenv(envAddr); v != "" {
+		c.Addr = v
+	}
+	if v := getenv(envBackend); v != "" {
+		c.Backend = v
+	}
+	if v := getenv(envSQLiteDSN); v != "" {
+		c.SQLiteDSN = v
+	}
+	if v := getenv(envOutboxSinks); v != "" {
+		c.OutboxSinks = splitList(v)
+	}
+	if v := getenv(envAuthJWKSFile); v != "" {
+		c.AuthJWKSFile = v
+	}
+	if v := getenv(envAuthIssuer); v != "" {
+		c.AuthIssuer = v
+	}
+	if v := getenv(envAuthAudience); v != "" {
+		c.AuthAudience = v
+	}
+
+	durations := []struct {
+		key string
+		dst *Duration
+	}{
+		{envReadTimeout, &c.ReadTimeout},
+		{envWriteTimeout, &c.WriteTimeout},
+		{envIdleTimeout, &c.IdleTimeout},
+		{envShutdownTimeout, &c.ShutdownTimeout},
+		{envTrashRetention, &c.TrashRetention},
+		{envPurgeInterval, &c.PurgeInterval},
+		{envOutboxInterval, &c.OutboxInterval},
+	}
+	for _, d := range durations {
+		v := getenv(d.key)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.key, err)
+		}
+		*d.dst = Duration(parsed)
+	}
+	return nil
+}
+
+// validate checks that the resolved configuration is usable.
+func (c *Config) validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("listen address must not be empty")
+	}
+	switch c.Backend {
+	case backendMemory:
+	case backendSQLite:
+		if c.SQLiteDSN == "" {
+			return fmt.Errorf("sqlite backend requires a DSN")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q", c.Backend)
+	}
+	if c.TrashRetention < 0 || c.PurgeInterval < 0 {
+		return fmt.Errorf("trash retention and purge interval must not be negative")
+	}
+	for _, sink := range c.OutboxSinks {
+		u, err := url.Parse(sink)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("outbox sink %q must be an http or https URL", sink)
+		}
+	}
+	if c.OutboxInterval <= 0 {
+		return fmt.Errorf("outbox interval must be positive")
+	}
+	if c.AuthJWKSFile == "" && (c.AuthIssuer != "" || c.AuthAudience != "") {
+		return fmt.Errorf("auth issuer and audience require a JWKS file")
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
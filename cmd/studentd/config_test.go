@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envMap(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	cfg, err := loadConfig(nil, envMap(nil))
+	require.NoError(t, err)
+	assert.Equal(t, defaultConfig(), cfg)
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	// Given: a config file, an environment override and a flag override
+	path := filepath.Join(t.TempDir(), "studentd.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"addr": ":9000",
+		"read_timeout": "3s",
+		"write_timeout": "4s"
+	}`), 0o600))
+
+	env := envMap(map[string]string{
+		envConfigFile:   path,
+		envWriteTimeout: "5s",
+		envAddr:         ":9001",
+	})
+
+	// When: the configuration is loaded
+	cfg, err := loadConfig([]string{"-addr", ":9002"}, env)
+	require.NoError(t, err)
+
+	// Then: flags win over environment, which wins over the file
+	assert.Equal(t, ":9002", cfg.Addr)
+	assert.Equal(t, Duration(3*time.Second), cfg.ReadTimeout)
+	assert.Equal(t, Duration(5*time.Second), cfg.WriteTimeout)
+	assert.Equal(t, defaultConfig().IdleTimeout, cfg.IdleTimeout)
+}
+
+func TestLoadConfig_TrashPurge(t *testing.T) {
+	env := envMap(map[string]string{envTrashRetention: "168h"})
+
+	cfg, err := loadConfig([]string{"-purge-interval", "0s"}, env)
+	require.NoError(t, err)
+	assert.Equal(t, Duration(7*24*time.Hour), cfg.TrashRetention)
+	assert.Equal(t, Duration(0), cfg.PurgeInterval)
+
+	_, err = loadConfig([]string{"-trash-retention", "-1h"}, envMap(nil))
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_UnknownBackend(t *testing.T) {
+	_, err := loadConfig([]string{"-backend", "oracle"}, envMap(nil))
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_OutboxSinks(t *testing.T) {
+	env := envMap(map[string]string{envOutboxSinks: "http://library.local/hook, https://lms.local/students"})
+
+	cfg, err := loadConfig([]string{"-outbox-interval", "1s"}, env)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://library.local/hook", "https://lms.local/students"}, cfg.OutboxSinks)
+	assert.Equal(t, Duration(time.Second), cfg.OutboxInterval)
+
+	_, err = loadConfig([]string{"-outbox-sinks", "library.local"}, envMap(nil))
+	assert.Error(t, err)
+	_, err = loadConfig([]string{"-outbox-sinks", "http://library.local", "-outbox-interval", "0s"}, envMap(nil))
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_Auth(t *testing.T) {
+	env := envMap(map[string]string{
+		envAuthJWKSFile: "/etc/studentd/jwks.json",
+		envAuthIssuer:   "https://id.school.edu",
+	})
+
+	cfg, err := loadConfig([]string{"-auth-audience", "student-api"}, env)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/studentd/jwks.json", cfg.AuthJWKSFile)
+	assert.Equal(t, "https://id.school.edu", cfg.AuthIssuer)
+	assert.Equal(t, "student-api", cfg.AuthAudience)
+
+	_, err = loadConfig([]string{"-auth-issuer", "https://id.school.edu"}, envMap(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+// Command studentd serves the student CRUD API over HTTP.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	auth "todo/internal/auth/student"
+	studentevent "todo/internal/event/student"
+	studenthandler "todo/internal/handler/student"
+	outbox "todo/internal/outbox/student"
+	studentrepo "todo/internal/repository/student"
+	studentusecase "todo/internal/usecase/student"
+	webhook "todo/internal/webhook/student"
+)
+
+func main() {
+	cfg, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatalf("studentd: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		log.Fatalf("studentd: %v", err)
+	}
+}
+
+// Sizes of the live feed: how many recent changes a reconnecting stream
+// can resume from, and how far a stream may lag before it is dropped.
+const (
+	streamHistory = 1000
+	streamBuffer  = 64
+)
+
+// run wires the application and serves until ctx is cancelled, then
+// drains in-flight requests within the configured shutdown timeout.
+func run(ctx context.Context, cfg *Config) error {
+	repo, closeRepo, err := newRepository(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	handlerOpts, err := authOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(logFormatter), gin.Recovery())
+
+	// Notification and sync components subscribe to bus.
+	bus := studentevent.NewSyncBus()
+	feed := studentevent.NewFeed(streamHistory, streamBuffer)
+	bus.Subscribe(feed.Handle)
+	uc := studentusecase.NewUseCase(repo, studentusecase.WithEventPublisher(bus))
+	handler := studenthandler.NewHandler(uc, handlerOpts...)
+	studenthandler.RegisterRoutes(router, handler)
+	studenthandler.RegisterStreamRoutes(router, studenthandler.NewStreamHandler(handler, feed))
+	webhooks := studentusecase.NewWebhookUseCase(repo)
+	studenthandler.RegisterWebhookRoutes(router, studenthandler.NewWebhookHandler(handler, webhooks))
+
+	// Background jobs must finish before the repository is closed.
+	jobCtx, stopJobs := context.WithCancel(ctx)
+	var jobs sync.WaitGroup
+	defer func() {
+		stopJobs()
+		jobs.Wait()
+	}()
+	jobs.Go(func() {
+		runPurger(jobCtx, uc, time.Duration(cfg.TrashRetention), time.Duration(cfg.PurgeInterval))
+	})
+	// The relay tracks delivery per sink, so a dead HTTP sink cannot keep
+	// events from webhooks or the other sinks.
+	sinks := []outbox.Sink{webhook.NewSink(repo)}
+	for _, u := range cfg.OutboxSinks {
+		sinks = append(sinks, outbox.NewHTTPSink(u))
+	}
+	relay := outbox.NewRelay(repo, sinks...)
+	dispatcher := webhook.NewDispatcher(repo)
+	jobs.Go(func() {
+		relay.Run(jobCtx, time.Duration(cfg.OutboxInterval))
+	})
+	jobs.Go(func() {
+		dispatcher.Run(jobCtx, time.Duration(cfg.OutboxInterval))
+	})
+
+	srv := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      router,
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.IdleTimeout),
+	}
+	// Shutdown waits for active requests, which live streams never finish
+	// on their own.
+	srv.RegisterOnShutdown(feed.Close)
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("studentd: listening on %s (backend=%s)", cfg.Addr, cfg.Backend)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("studentd: shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return <-errCh
+}
+
+// logFormatter formats access logs like gin's default formatter, with
+// bearer tokens passed in the query string of live streams redacted.
+func logFormatter(p gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if p.IsOutputColor() {
+		statusColor, methodColor, resetColor = p.StatusCodeColor(), p.MethodColor(), p.ResetColor()
+	}
+	if p.Latency > time.Minute {
+		p.Latency = p.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, p.StatusCode, resetColor,
+		p.Latency,
+		p.ClientIP,
+		methodColor, p.Method, resetColor,
+		studenthandler.RedactAccessToken(p.Path),
+		p.ErrorMessage,
+	)
+}
+
+// authOptions configures the handler to require bearer tokens signed by
+// the keys of cfg.AuthJWKSFile, if set.
+func authOptions(cfg *Config) ([]studenthandler.Option, error) {
+	if cfg.AuthJWKSFile == "" {
+		log.Printf("studentd: authentication disabled; set -auth-jwks-file to require bearer tokens")
+		return nil, nil
+	}
+	keys, err := auth.LoadJWKS(cfg.AuthJWKSFile)
+	if err != nil {
+		return nil, err
+	}
+	verifier := auth.NewVerifier(keys)
+	verifier.Issuer = cfg.AuthIssuer
+	verifier.Audience = cfg.AuthAudience
+	return []studenthandler.Option{studenthandler.WithAuthenticator(verifier)}, nil
+}
+
+// store is the persistence of studentd: students and webhooks share a
+// backend.
+type store interface {
+	studentrepo.Repository
+	studentrepo.WebhookRepository
+}
+
+// newRepository builds the store selected by cfg.Backend. The returned
+// func releases any resources held by the backend.
+func newRepository(ctx context.Context, cfg *Config) (store, func() error, error) {
+	noop := func() error { return nil }
+
+	switch cfg.Backend {
+	case backendMemory:
+		return studentrepo.NewMemoryRepository(), noop, nil
+	case backendSQLite:
+		db, err := studentrepo.OpenSQLite(cfg.SQLiteDSN)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open sqlite: %w", err)
+		}
+		repo, err := studentrepo.NewSQLiteRepository(ctx, db)
+		if err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("migrate sqlite: %w", err)
+		}
+		return repo, db.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	studentusecase "todo/internal/usecase/student"
+)
+
+// runPurger removes students that have been in the trash for longer than
+// retention, once at startup and then every interval, until ctx is done.
+// A zero interval disables purging.
+func runPurger(ctx context.Context, uc *studentusecase.UseCase, retention, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := uc.PurgeDeletedStudents(ctx, retention)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("studentd: purge trash: %v", err)
+		case n > 0:
+			log.Printf("studentd: purged %d students from the trash", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
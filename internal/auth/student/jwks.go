@@ -0,0 +1,151 @@
+// Package auth authenticates callers of the student API with JWT bearer
+// tokens verified against the keys of a local JWKS file.
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Signing algorithms accepted in tokens.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// minRSABits is the smallest RSA modulus accepted in a key set.
+const minRSABits = 2048
+
+// KeySet holds the verification keys of a JWKS document (RFC 7517).
+type KeySet struct {
+	keys []*key
+}
+
+// key is one verification key; secret is set for HS256 and public for
+// RS256.
+type key struct {
+	id     string
+	alg    string
+	secret []byte
+	public *rsa.PublicKey
+}
+
+// jsonWebKey is a key of a JWKS document. Only the members of symmetric
+// ("oct") and RSA keys are read.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// LoadJWKS reads the key set in the JWKS file at path.
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwks: %w", err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse jwks %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// ParseJWKS parses a JWKS document. Symmetric keys verify HS256 tokens and
+// RSA keys RS256 tokens; keys of other types, algorithms or uses are
+// skipped, but the document must hold at least one usable key.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{}
+	for i, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		k, err := parseKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		if k != nil {
+			set.keys = append(set.keys, k)
+		}
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("no HS256 or RS256 signing keys")
+	}
+	return set, nil
+}
+
+// parseKey converts jwk, returning nil for an unsupported key.
+func parseKey(jwk jsonWebKey) (*key, error) {
+	switch {
+	case jwk.Kty == "oct" && (jwk.Alg == "" || jwk.Alg == AlgHS256):
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("invalid symmetric key")
+		}
+		return &key{id: jwk.Kid, alg: AlgHS256, secret: secret}, nil
+	case jwk.Kty == "RSA" && (jwk.Alg == "" || jwk.Alg == AlgRS256):
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		public := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if public.N.BitLen() < minRSABits || public.E < 3 {
+			return nil, fmt.Errorf("RSA key must have at least %d bits", minRSABits)
+		}
+		return &key{id: jwk.Kid, alg: AlgRS256, public: public}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// verify reports whether signature is a valid alg signature of signed by
+// a key of the set. With an empty kid every key of the algorithm is
+// tried, so tokens need not name their key while only one is in use.
+func (s *KeySet) verify(alg, kid string, signed, signature []byte) bool {
+	for _, k := range s.keys {
+		if k.alg != alg || (kid != "" && k.id != kid) {
+			continue
+		}
+		if k.verify(signed, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *key) verify(signed, signature []byte) bool {
+	switch k.alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case AlgRS256:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(k.public, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
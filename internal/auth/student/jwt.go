@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"todo/internal/domain/student"
+)
+
+// Errors returned by Authenticate, wrapped with the reason. Either way the
+// caller is not authenticated.
+var (
+	ErrInvalidToken = errors.New("auth: invalid token")
+	ErrExpiredToken = errors.New("auth: token expired")
+)
+
+// Verifier authenticates callers by their JWT bearer token. A token must
+// be signed with HS256 or RS256 by a key of the key set, carry exp and
+// sub claims, and match Issuer and Audience when they are set.
+type Verifier struct {
+	keys *KeySet
+
+	Issuer   string        // required iss claim, if not empty
+	Audience string        // required among the aud claim, if not empty
+	Leeway   time.Duration // clock skew allowed when checking exp and nbf
+
+	now func() time.Time
+}
+
+// NewVerifier creates a verifier of tokens signed by keys with default
+// settings.
+func NewVerifier(keys *KeySet) *Verifier {
+	return &Verifier{
+		keys:   keys,
+		Leeway: time.Minute,
+		now:    time.Now,
+	}
+}
+
+// header is the JOSE header of a token.
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// claims are the registered claims read from a token, with the optional
+// name claim of OpenID Connect and the roles claim of RFC 9068.
+type claims struct {
+	Subject   string     `json:"sub"`
+	Name      string     `json:"name"`
+	Roles     stringList `json:"roles"`
+	Issuer    string     `json:"iss"`
+	Audience  stringList `json:"aud"`
+	ExpiresAt *float64   `json:"exp"`
+	NotBefore *float64   `json:"nbf"`
+}
+
+// stringList is a claim, such as aud, which is either a string or an
+// array of strings.
+type stringList []string
+
+func (a *stringList) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = stringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Authenticate verifies token and returns the principal it was issued to.
+func (v *Verifier) Authenticate(_ context.Context, token string) (*student.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if h.Alg != AlgHS256 && h.Alg != AlgRS256 {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, h.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	signed := token[:len(parts[0])+1+len(parts[1])]
+	if !v.keys.verify(h.Alg, h.Kid, []byte(signed), signature) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+	}
+
+	// Claims are only trusted once the signature is.
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.validate(&c); err != nil {
+		return nil, err
+	}
+	return &student.Principal{Subject: c.Subject, Name: c.Name, Roles: c.Roles}, nil
+}
+
+// validate checks the claims of a token with a valid signature.
+func (v *Verifier) validate(c *claims) error {
+	now := v.now()
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if now.After(numericDate(*c.ExpiresAt).Add(v.Leeway)) {
+		return ErrExpiredToken
+	}
+	if c.NotBefore != nil && now.Before(numericDate(*c.NotBefore).Add(-v.Leeway)) {
+		return fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return fmt.Errorf("%w: issuer %q", ErrInvalidToken, c.Issuer)
+	}
+	if v.Audience != "" && !slices.Contains(c.Audience, v.Audience) {
+		return fmt.Errorf("%w: audience %q", ErrInvalidToken, c.Audience)
+	}
+	if c.Subject == "" {
+		return fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// numericDate converts a JWT NumericDate, in seconds since the epoch,
+// dropping fractions of a second.
+func numericDate(seconds float64) time.Time {
+	return time.Unix(int64(seconds), 0)
+}
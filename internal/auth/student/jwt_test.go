@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/internal/domain/student"
+)
+
+var (
+	testNow    = time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	testSecret = []byte("0123456789abcdef0123456789abcdef")
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// unsignedToken encodes the header and claims of a token.
+func unsignedToken(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	require.NoError(t, err)
+	c, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return b64(h) + "." + b64(c)
+}
+
+func signHS256(t *testing.T, secret []byte, claims map[string]any) string {
+	t.Helper()
+	unsigned := unsignedToken(t, map[string]any{"alg": AlgHS256, "typ": "JWT"}, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + b64(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, priv *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	unsigned := unsignedToken(t, map[string]any{"alg": AlgRS256, "typ": "JWT", "kid": kid}, claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return unsigned + "." + b64(sig)
+}
+
+// validClaims returns the claims of a token valid at testNow.
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":  "teacher.lin",
+		"name": "林老師",
+		"iss":  "https://id.school.edu",
+		"aud":  "student-api",
+		"exp":  testNow.Add(time.Hour).Unix(),
+	}
+}
+
+// rsaJWK returns the JWKS entry of the public key of priv.
+func rsaJWK(priv *rsa.PrivateKey, kid string) map[string]any {
+	return map[string]any{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": AlgRS256,
+		"use": "sig",
+		"n":   b64(priv.N.Bytes()),
+		"e":   b64(big.NewInt(int64(priv.E)).Bytes()),
+	}
+}
+
+// writeJWKS writes a JWKS file holding keys and returns its path.
+func writeJWKS(t *testing.T, keys ...map[string]any) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func newTestVerifier(t *testing.T, keys ...map[string]any) *Verifier {
+	t.Helper()
+	set, err := LoadJWKS(writeJWKS(t, keys...))
+	require.NoError(t, err)
+	v := NewVerifier(set)
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestVerifier_HS256(t *testing.T) {
+	v := newTestVerifier(t, map[string]any{"kty": "oct", "k": b64(testSecret)})
+	ctx := context.Background()
+
+	p, err := v.Authenticate(ctx, signHS256(t, testSecret, validClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, "teacher.lin", p.Subject)
+	assert.Equal(t, "林老師", p.Name)
+	assert.Empty(t, p.Roles)
+
+	// Roles are read from a string or an array.
+	claims := validClaims()
+	claims["roles"] = []string{"teacher", student.RoleAdmin}
+	p, err = v.Authenticate(ctx, signHS256(t, testSecret, claims))
+	require.NoError(t, err)
+	assert.True(t, p.HasRole(student.RoleAdmin))
+	claims["roles"] = "teacher"
+	p, err = v.Authenticate(ctx, signHS256(t, testSecret, claims))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"teacher"}, p.Roles)
+	assert.False(t, p.HasRole(student.RoleAdmin))
+
+	_, err = v.Authenticate(ctx, signHS256(t, []byte("another secret of the same size!"), validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Expiry is checked with the leeway.
+	claims = validClaims()
+	claims["exp"] = testNow.Add(-30 * time.Second).Unix()
+	_, err = v.Authenticate(ctx, signHS256(t, testSecret, claims))
+	assert.NoError(t, err)
+	claims["exp"] = testNow.Add(-2 * time.Minute).Unix()
+	_, err = v.Authenticate(ctx, signHS256(t, testSecret, claims))
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestVerifier_RS256(t *testing.T) {
+	current, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	previous, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	v := newTestVerifier(t,
+		rsaJWK(previous, "2024-01"),
+		rsaJWK(current, "2024-09"),
+		map[string]any{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "AA", "y": "AA"},
+	)
+	ctx := context.Background()
+
+	// Every key of the set is accepted, named or not.
+	for _, kid := range []string{"2024-09", ""} {
+		p, err := v.Authenticate(ctx, signRS256(t, current, kid, validClaims()))
+		require.NoError(t, err, kid)
+		assert.Equal(t, "teacher.lin", p.Subject)
+	}
+	_, err = v.Authenticate(ctx, signRS256(t, previous, "2024-01", validClaims()))
+	assert.NoError(t, err)
+
+	// A token naming the wrong key, or an unknown one, is rejected.
+	_, err = v.Authenticate(ctx, signRS256(t, previous, "2024-09", validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = v.Authenticate(ctx, signRS256(t, current, "2023-01", validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// An HS256 token keyed with the public key must not verify against it.
+	_, err = v.Authenticate(ctx, signHS256(t, current.N.Bytes(), validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifier_Claims(t *testing.T) {
+	v := newTestVerifier(t, map[string]any{"kty": "oct", "k": b64(testSecret)})
+	v.Issuer = "https://id.school.edu"
+	v.Audience = "student-api"
+	ctx := context.Background()
+
+	claims := validClaims()
+	claims["aud"] = []string{"other-api", "student-api"}
+	_, err := v.Authenticate(ctx, signHS256(t, testSecret, claims))
+	assert.NoError(t, err)
+
+	invalid := map[string]func(map[string]any){
+		"issuer":      func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"audience":    func(c map[string]any) { c["aud"] = "other-api" },
+		"missing exp": func(c map[string]any) { delete(c, "exp") },
+		"missing sub": func(c map[string]any) { delete(c, "sub") },
+		"not before":  func(c map[string]any) { c["nbf"] = testNow.Add(time.Hour).Unix() },
+	}
+	for name, change := range invalid {
+		claims := validClaims()
+		change(claims)
+		_, err := v.Authenticate(ctx, signHS256(t, testSecret, claims))
+		assert.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+
+	// Unsigned and malformed tokens are rejected.
+	unsigned := unsignedToken(t, map[string]any{"alg": "none"}, validClaims()) + "."
+	for _, token := range []string{unsigned, "", "not-a-token", strings.Repeat(".", 2)} {
+		_, err := v.Authenticate(ctx, token)
+		assert.ErrorIs(t, err, ErrInvalidToken, token)
+	}
+}
+
+func TestParseJWKS_Errors(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	docs := map[string]string{
+		"not json":         `keys`,
+		"no usable key":    `{"keys": [{"kty": "EC", "crv": "P-256"}]}`,
+		"encryption only":  `{"keys": [{"kty": "oct", "use": "enc", "k": "c2VjcmV0"}]}`,
+		"bad symmetric":    `{"keys": [{"kty": "oct", "k": "***"}]}`,
+		"empty symmetric":  `{"keys": [{"kty": "oct"}]}`,
+		"missing exponent": `{"keys": [{"kty": "RSA", "n": "` + b64(weak.N.Bytes()) + `"}]}`,
+	}
+	weakDoc, err := json.Marshal(map[string]any{"keys": []any{rsaJWK(weak, "weak")}})
+	require.NoError(t, err)
+	docs["weak RSA key"] = string(weakDoc)
+
+	for name, doc := range docs {
+		_, err := ParseJWKS([]byte(doc))
+		assert.Error(t, err, name)
+	}
+
+	_, err = LoadJWKS(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package student
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// AuditAction names the kind of change recorded by an AuditEntry.
+type AuditAction string
+
+const (
+	AuditCreated  AuditAction = "created"
+	AuditUpdated  AuditAction = "updated"
+	AuditDeleted  AuditAction = "deleted"
+	AuditRestored AuditAction = "restored"
+	AuditReverted AuditAction = "reverted" // updated back to the fields of an earlier version
+)
+
+// AuditEntry records one change of a student: who made it, when, in which
+// request, and how each field changed. Entries are keyed by the student's
+// internal ID, so the history survives student number changes.
+type AuditEntry struct {
+	ID        string        `json:"id"`
+	StudentID string        `json:"student_id"`
+	Action    AuditAction   `json:"action"`
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id,omitempty"`
+	Version   int64         `json:"version"` // the student's version after the change
+	Timestamp time.Time     `json:"timestamp"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// FieldChange is the before and after value of one student field, as
+// JSON. A null value means the field was unset.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// Diff returns the changes of the audited fields between before and
+// after, in a fixed field order. A nil before yields every set field of
+// after, as for a newly created student.
+func Diff(before, after *Student) []FieldChange {
+	old, updated := auditedFields(before), auditedFields(after)
+	changes := make([]FieldChange, 0)
+	for i, f := range updated {
+		if !bytes.Equal(old[i].value, f.value) {
+			changes = append(changes, FieldChange{Field: f.name, Before: old[i].value, After: f.value})
+		}
+	}
+	return changes
+}
+
+type auditedField struct {
+	name  string
+	value json.RawMessage
+}
+
+// auditedFields returns the JSON value of each audited field of s, or
+// null for every field when s is nil.
+func auditedFields(s *Student) []auditedField {
+	values := []any{nil, nil, nil, nil, nil, nil}
+	if s != nil {
+		values = []any{s.StudentNumber, s.Name, s.Email, s.Class, s.Grade, s.DeletedAt}
+	}
+	names := []string{"student_number", "name", "email", "class", "grade", "deleted_at"}
+
+	fields := make([]auditedField, len(names))
+	for i, name := range names {
+		// Strings, ints and times always marshal.
+		value, _ := json.Marshal(values[i])
+		fields[i] = auditedField{name: name, value: value}
+	}
+	return fields
+}
@@ -0,0 +1,56 @@
+package student
+
+// BatchOperationType names the kind of a batch operation.
+type BatchOperationType string
+
+const (
+	BatchCreate BatchOperationType = "create"
+	BatchUpdate BatchOperationType = "update"
+	BatchDelete BatchOperationType = "delete"
+)
+
+// BatchOperation is one create, update or delete of a batch. Create is set
+// for BatchCreate; StudentNumber identifies the target of BatchUpdate and
+// BatchDelete, and Update holds the changes of BatchUpdate.
+type BatchOperation struct {
+	Type          BatchOperationType
+	StudentNumber string
+	Create        *CreateStudentRequest
+	Update        *UpdateStudentRequest
+
+	// ExpectedVersion makes a delete conditional, like If-Match.
+	// Updates carry it in Update.ExpectedVersion.
+	ExpectedVersion int64
+}
+
+// BatchStatus is the outcome of one batch operation.
+type BatchStatus string
+
+const (
+	BatchStatusSucceeded  BatchStatus = "succeeded"   // the operation was applied
+	BatchStatusFailed     BatchStatus = "failed"      // the operation was rejected, see Err
+	BatchStatusRolledBack BatchStatus = "rolled_back" // atomic batch: undone because another operation failed
+	BatchStatusSkipped    BatchStatus = "skipped"     // not attempted after a failure of an atomic batch or of the repository
+)
+
+// BatchResult reports the outcome of one BatchOperation.
+type BatchResult struct {
+	Type    BatchOperationType
+	Status  BatchStatus
+	Student *Student // the created or updated student when succeeded
+	Err     error    // *StudentError or ValidationErrors when rejected, any other error when the repository failed
+}
+
+// BatchReport reports the outcome of a batch, one result per operation in
+// request order. Committed is false only when an atomic batch was rolled
+// back.
+type BatchReport struct {
+	Atomic    bool
+	Committed bool
+	Succeeded int
+	Failed    int
+	Results   []*BatchResult
+}
+
+// MaxBatchOperations bounds the number of operations in one batch.
+const MaxBatchOperations = 100
@@ -0,0 +1,30 @@
+package student
+
+// ChangeType tells whether a Change upserts or deletes a student.
+type ChangeType string
+
+const (
+	ChangeUpsert ChangeType = "upsert" // the student was created, updated or restored
+	ChangeDelete ChangeType = "delete" // the student was deleted; a tombstone
+)
+
+// Change is the latest change of one student in the change log. Every
+// write of a student gives it a new, higher Sequence, so reading the log
+// in sequence order from a cursor yields each student changed since, once,
+// in its current state.
+type Change struct {
+	Sequence      int64      `json:"sequence"`
+	Type          ChangeType `json:"type"`
+	StudentID     string     `json:"student_id"`
+	StudentNumber string     `json:"student_number"`
+	Student       *Student   `json:"student,omitempty"` // the current state, for upserts
+}
+
+// ChangeFeed is one page of the change log. NextSince is the cursor to
+// ask for the following changes with; it equals the requested cursor when
+// there were none. HasMore is set when the page was cut at its limit.
+type ChangeFeed struct {
+	Changes   []*Change `json:"changes"`
+	NextSince int64     `json:"next_since"`
+	HasMore   bool      `json:"has_more"`
+}
@@ -1,6 +1,9 @@
 package student
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // ErrorType represents different types of student domain errors.
 // Source: 各驗證場景（第 36-83 行）
@@ -26,13 +29,34 @@ const (
 	// ErrorTypeStudentNotFound indicates student does not exist.
 	// Source: "學生不存在" (第 57 行)
 	ErrorTypeStudentNotFound ErrorType = "STUDENT_NOT_FOUND"
+
+	// ErrorTypeInvalidQueryParameter indicates a malformed listing parameter
+	// such as an unknown sort field or an expired page token.
+	ErrorTypeInvalidQueryParameter ErrorType = "INVALID_QUERY_PARAMETER"
+
+	// ErrorTypeVersionConflict indicates the student was modified since the
+	// version the caller based its write on.
+	ErrorTypeVersionConflict ErrorType = "VERSION_CONFLICT"
+
+	// ErrorTypeInvalidWebhookURL indicates a webhook URL that is not an
+	// absolute http or https URL.
+	ErrorTypeInvalidWebhookURL ErrorType = "INVALID_WEBHOOK_URL"
+
+	// ErrorTypeInvalidEventType indicates an unknown event type in a
+	// webhook filter.
+	ErrorTypeInvalidEventType ErrorType = "INVALID_EVENT_TYPE"
+
+	// ErrorTypeWebhookNotFound indicates the webhook subscription does not
+	// exist.
+	ErrorTypeWebhookNotFound ErrorType = "WEBHOOK_NOT_FOUND"
 )
 
 // StudentError represents a domain error in student operations.
 type StudentError struct {
 	Type    ErrorType
-	Message string
+	Message string // Rendered in DefaultLanguage; see Localize
 	Field   string // For field-specific errors
+	Args    []any  // Arguments of the message template
 }
 
 // Error implements the error interface.
@@ -43,46 +67,93 @@ func (e *StudentError) Error() string {
 	return fmt.Sprintf("[%s] %s", e.Type, e.Message)
 }
 
+// ValidationErrors collects every field violation found while validating a
+// request, so clients can fix them all in one round trip. errors.As on a
+// ValidationErrors yields its first StudentError.
+type ValidationErrors []*StudentError
+
+// Error implements the error interface.
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap exposes the individual violations to errors.Is and errors.As.
+func (v ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(v))
+	for i, e := range v {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Add records a violation; nil is ignored.
+func (v *ValidationErrors) Add(err *StudentError) {
+	if err != nil {
+		*v = append(*v, err)
+	}
+}
+
+// Err returns v as an error, or nil when no violation was recorded.
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
 // NewMissingRequiredFieldError creates a new missing required field error.
+// field is the JSON name of the missing field, e.g. "name".
 func NewMissingRequiredFieldError(field string) *StudentError {
-	return &StudentError{
-		Type:    ErrorTypeMissingRequiredField,
-		Message: fmt.Sprintf("%s為必填欄位", field),
-		Field:   field,
-	}
+	return newStudentError(ErrorTypeMissingRequiredField, field, fieldLabel(field))
 }
 
 // NewInvalidEmailError creates a new invalid email error.
 func NewInvalidEmailError() *StudentError {
-	return &StudentError{
-		Type:    ErrorTypeInvalidEmail,
-		Message: "無效的電子郵件格式",
-		Field:   "email",
-	}
+	return newStudentError(ErrorTypeInvalidEmail, "email")
 }
 
 // NewInvalidGradeError creates a new invalid grade error.
 func NewInvalidGradeError() *StudentError {
-	return &StudentError{
-		Type:    ErrorTypeInvalidGrade,
-		Message: fmt.Sprintf("年級必須在 %d-%d 之間", MinGrade, MaxGrade),
-		Field:   "grade",
-	}
+	return newStudentError(ErrorTypeInvalidGrade, "grade", MinGrade, MaxGrade)
 }
 
 // NewStudentNumberAlreadyExistsError creates a new duplicate student number error.
 func NewStudentNumberAlreadyExistsError() *StudentError {
-	return &StudentError{
-		Type:    ErrorTypeStudentNumberAlreadyExists,
-		Message: "學號已存在",
-		Field:   "student_number",
-	}
+	return newStudentError(ErrorTypeStudentNumberAlreadyExists, "student_number")
 }
 
 // NewStudentNotFoundError creates a new student not found error.
 func NewStudentNotFoundError() *StudentError {
-	return &StudentError{
-		Type:    ErrorTypeStudentNotFound,
-		Message: "學生不存在",
-	}
+	return newStudentError(ErrorTypeStudentNotFound, "")
+}
+
+// NewInvalidQueryParameterError creates a new invalid query parameter error
+// for the offending parameter and value.
+func NewInvalidQueryParameterError(param, value string) *StudentError {
+	return newStudentError(ErrorTypeInvalidQueryParameter, param, param, value)
+}
+
+// NewVersionConflictError creates a new optimistic concurrency error.
+func NewVersionConflictError() *StudentError {
+	return newStudentError(ErrorTypeVersionConflict, "")
+}
+
+// NewInvalidWebhookURLError creates a new invalid webhook URL error.
+func NewInvalidWebhookURLError() *StudentError {
+	return newStudentError(ErrorTypeInvalidWebhookURL, "url")
+}
+
+// NewInvalidEventTypeError creates a new unknown event type error for the
+// offending value.
+func NewInvalidEventTypeError(value string) *StudentError {
+	return newStudentError(ErrorTypeInvalidEventType, "event_types", value)
+}
+
+// NewWebhookNotFoundError creates a new webhook not found error.
+func NewWebhookNotFoundError() *StudentError {
+	return newStudentError(ErrorTypeWebhookNotFound, "")
 }
@@ -0,0 +1,140 @@
+package student
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// EventType names a kind of domain event.
+type EventType string
+
+const (
+	EventStudentCreated  EventType = "student.created"
+	EventStudentUpdated  EventType = "student.updated"
+	EventStudentDeleted  EventType = "student.deleted"
+	EventStudentRestored EventType = "student.restored"
+)
+
+// Event is a domain event describing a committed change of a student.
+// Subscribers switch on the concrete type: *StudentCreated,
+// *StudentUpdated, *StudentDeleted or *StudentRestored.
+type Event interface {
+	// Type names the kind of event.
+	Type() EventType
+	// Metadata describes when, by whom and in which request the change
+	// was made.
+	Metadata() EventMetadata
+	// Subject is the student as it is after the change.
+	Subject() *Student
+}
+
+// EventMetadata is common to every domain event. ID is unique per event.
+type EventMetadata struct {
+	ID         string    `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Actor      string    `json:"actor"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// Metadata returns m; it lets the event types satisfy Event by embedding
+// EventMetadata.
+func (m EventMetadata) Metadata() EventMetadata { return m }
+
+// StudentCreated is published when a student is created.
+type StudentCreated struct {
+	EventMetadata
+	Student *Student `json:"student"`
+}
+
+func (*StudentCreated) Type() EventType     { return EventStudentCreated }
+func (e *StudentCreated) Subject() *Student { return e.Student }
+
+// StudentUpdated is published when a student's fields change, including
+// by a revert. Changes lists the changed fields.
+type StudentUpdated struct {
+	EventMetadata
+	Student *Student      `json:"student"`
+	Changes []FieldChange `json:"changes"`
+}
+
+func (*StudentUpdated) Type() EventType     { return EventStudentUpdated }
+func (e *StudentUpdated) Subject() *Student { return e.Student }
+
+// StudentDeleted is published when a student is moved to the trash.
+type StudentDeleted struct {
+	EventMetadata
+	Student *Student `json:"student"`
+}
+
+func (*StudentDeleted) Type() EventType     { return EventStudentDeleted }
+func (e *StudentDeleted) Subject() *Student { return e.Student }
+
+// StudentRestored is published when a student is taken out of the trash.
+type StudentRestored struct {
+	EventMetadata
+	Student *Student `json:"student"`
+}
+
+func (*StudentRestored) Type() EventType     { return EventStudentRestored }
+func (e *StudentRestored) Subject() *Student { return e.Student }
+
+// NewEvent returns the event published for an audited change, sharing the
+// entry's metadata; after is the student after the change.
+func NewEvent(entry *AuditEntry, after *Student) Event {
+	meta := EventMetadata{
+		ID:         entry.ID,
+		OccurredAt: entry.Timestamp,
+		Actor:      entry.Actor,
+		RequestID:  entry.RequestID,
+	}
+	switch entry.Action {
+	case AuditCreated:
+		return &StudentCreated{EventMetadata: meta, Student: after}
+	case AuditDeleted:
+		return &StudentDeleted{EventMetadata: meta, Student: after}
+	case AuditRestored:
+		return &StudentRestored{EventMetadata: meta, Student: after}
+	default:
+		return &StudentUpdated{EventMetadata: meta, Student: after, Changes: entry.Changes}
+	}
+}
+
+// EventEnvelope is the wire format of a domain event, shared by every
+// consumer outside the process. Changes is only set for
+// student.updated.
+type EventEnvelope struct {
+	EventMetadata
+	Type    EventType     `json:"type"`
+	Student *Student      `json:"student"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// NewEventEnvelope wraps e for the wire.
+func NewEventEnvelope(e Event) *EventEnvelope {
+	env := &EventEnvelope{
+		EventMetadata: e.Metadata(),
+		Type:          e.Type(),
+		Student:       e.Subject(),
+	}
+	if updated, ok := e.(*StudentUpdated); ok {
+		env.Changes = updated.Changes
+	}
+	return env
+}
+
+// InClass reports whether the student of env is in one of classes after
+// the change or, when the change moved the student, was in one before it,
+// so that a class roster also learns about students leaving it.
+func (env *EventEnvelope) InClass(classes ...string) bool {
+	if env.Student != nil && slices.Contains(classes, env.Student.Class) {
+		return true
+	}
+	for _, change := range env.Changes {
+		var before string
+		if change.Field == "class" && json.Unmarshal(change.Before, &before) == nil {
+			return slices.Contains(classes, before)
+		}
+	}
+	return false
+}
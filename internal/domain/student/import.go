@@ -0,0 +1,42 @@
+package student
+
+// ImportRow is one student read from an import file.
+type ImportRow struct {
+	Line    int // line of the row in the source file, for reporting
+	Request CreateStudentRequest
+
+	// Err reports a row that could not be decoded, such as a grade that
+	// is not a number. Such rows are reported as failed without being
+	// validated.
+	Err error
+}
+
+// ImportStatus is the outcome of importing one row.
+type ImportStatus string
+
+const (
+	ImportStatusCreated ImportStatus = "created" // the student was saved
+	ImportStatusValid   ImportStatus = "valid"   // dry run: the student would be saved
+	ImportStatusFailed  ImportStatus = "failed"  // the row was rejected, see Err
+)
+
+// ImportRowResult reports the outcome of one ImportRow.
+type ImportRowResult struct {
+	Line          int
+	StudentNumber string
+	Status        ImportStatus
+	Student       *Student // the created student, nil unless Status is created
+	Err           error    // *StudentError or ValidationErrors when failed
+}
+
+// ImportReport summarises an import. Rows are independent: a failed row
+// does not prevent the others from being imported.
+type ImportReport struct {
+	DryRun    bool
+	Succeeded int
+	Failed    int
+	Rows      []*ImportRowResult
+}
+
+// MaxImportRows bounds the number of rows accepted by one import.
+const MaxImportRows = 5000
@@ -0,0 +1,128 @@
+package student
+
+import "fmt"
+
+// Language identifies a supported message language by its BCP 47 tag.
+type Language string
+
+const (
+	LanguageZhTW Language = "zh-TW"
+	LanguageEn   Language = "en"
+	LanguageJa   Language = "ja"
+)
+
+// DefaultLanguage is used when no preferred language is supported.
+const DefaultLanguage = LanguageZhTW
+
+// SupportedLanguages lists the catalog languages, default first.
+var SupportedLanguages = []Language{LanguageZhTW, LanguageEn, LanguageJa}
+
+// messageCatalog holds the message template of each ErrorType per language.
+// Templates are formatted with StudentError.Args.
+var messageCatalog = map[ErrorType]map[Language]string{
+	ErrorTypeMissingRequiredField: {
+		LanguageZhTW: "%s為必填欄位",
+		LanguageEn:   "%s is required",
+		LanguageJa:   "%sは必須項目です",
+	},
+	ErrorTypeInvalidEmail: {
+		LanguageZhTW: "無效的電子郵件格式",
+		LanguageEn:   "invalid email format",
+		LanguageJa:   "メールアドレスの形式が無効です",
+	},
+	ErrorTypeInvalidGrade: {
+		LanguageZhTW: "年級必須在 %d-%d 之間",
+		LanguageEn:   "grade must be between %d and %d",
+		LanguageJa:   "学年は %d から %d の間で指定してください",
+	},
+	ErrorTypeStudentNumberAlreadyExists: {
+		LanguageZhTW: "學號已存在",
+		LanguageEn:   "student number already exists",
+		LanguageJa:   "学籍番号は既に存在します",
+	},
+	ErrorTypeStudentNotFound: {
+		LanguageZhTW: "學生不存在",
+		LanguageEn:   "student not found",
+		LanguageJa:   "学生が見つかりません",
+	},
+	ErrorTypeVersionConflict: {
+		LanguageZhTW: "學生資料已被其他人修改，請重新讀取後再試",
+		LanguageEn:   "the student was modified by someone else; reload and try again",
+		LanguageJa:   "学生データは他のユーザーによって更新されました。再読み込みしてからやり直してください",
+	},
+	ErrorTypeInvalidQueryParameter: {
+		LanguageZhTW: "查詢參數 %s 的值「%s」無效",
+		LanguageEn:   "invalid value %[2]q for query parameter %[1]s",
+		LanguageJa:   "クエリパラメータ %s の値「%s」は無効です",
+	},
+	ErrorTypeInvalidWebhookURL: {
+		LanguageZhTW: "Webhook 網址必須是 http 或 https 的完整網址",
+		LanguageEn:   "webhook url must be an absolute http or https URL",
+		LanguageJa:   "Webhook の URL は http または https の絶対 URL で指定してください",
+	},
+	ErrorTypeInvalidEventType: {
+		LanguageZhTW: "事件類型「%s」無效",
+		LanguageEn:   "invalid event type %q",
+		LanguageJa:   "イベント種別「%s」は無効です",
+	},
+	ErrorTypeWebhookNotFound: {
+		LanguageZhTW: "Webhook 不存在",
+		LanguageEn:   "webhook not found",
+		LanguageJa:   "Webhook が見つかりません",
+	},
+}
+
+// fieldLabels holds the display name of each JSON field per language.
+var fieldLabels = map[string]map[Language]string{
+	"student_number": {LanguageZhTW: "學號", LanguageEn: "student number", LanguageJa: "学籍番号"},
+	"name":           {LanguageZhTW: "姓名", LanguageEn: "name", LanguageJa: "氏名"},
+	"email":          {LanguageZhTW: "電子郵件", LanguageEn: "email", LanguageJa: "メールアドレス"},
+	"class":          {LanguageZhTW: "班級", LanguageEn: "class", LanguageJa: "クラス"},
+	"grade":          {LanguageZhTW: "年級", LanguageEn: "grade", LanguageJa: "学年"},
+	"url":            {LanguageZhTW: "網址", LanguageEn: "url", LanguageJa: "URL"},
+}
+
+// fieldLabel is a message argument rendered as the localized field name.
+type fieldLabel string
+
+// Localize renders the message of e in lang, falling back to
+// DefaultLanguage. The machine-readable Type is unaffected.
+func (e *StudentError) Localize(lang Language) string {
+	templates, ok := messageCatalog[e.Type]
+	if !ok {
+		return e.Message
+	}
+	template, ok := templates[lang]
+	if !ok {
+		lang = DefaultLanguage
+		template = templates[lang]
+	}
+
+	args := make([]any, len(e.Args))
+	for i, arg := range e.Args {
+		if label, ok := arg.(fieldLabel); ok {
+			args[i] = localizeField(string(label), lang)
+			continue
+		}
+		args[i] = arg
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// localizeField returns the display name of a JSON field in lang.
+func localizeField(field string, lang Language) string {
+	if labels, ok := fieldLabels[field]; ok {
+		if label, ok := labels[lang]; ok {
+			return label
+		}
+	}
+	return field
+}
+
+// newStudentError builds a StudentError whose Message is rendered in
+// DefaultLanguage.
+func newStudentError(t ErrorType, field string, args ...any) *StudentError {
+	e := &StudentError{Type: t, Field: field, Args: args}
+	e.Message = e.Localize(DefaultLanguage)
+	return e
+}
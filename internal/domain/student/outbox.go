@@ -0,0 +1,69 @@
+package student
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxStatus is the delivery state of an OutboxMessage.
+type OutboxStatus string
+
+const (
+	OutboxPending   OutboxStatus = "pending"   // waiting for its first or next delivery attempt
+	OutboxDelivered OutboxStatus = "delivered" // accepted by every sink
+	OutboxDead      OutboxStatus = "dead"      // given up after too many failed attempts
+)
+
+// OutboxMessage is a domain event waiting to be delivered to downstream
+// systems. It is written in the same unit of work as the change it
+// describes, so that no committed change goes unannounced even if the
+// process stops right after the commit. Delivery is at least once: ID,
+// the event ID, is sent along as an idempotency key for receivers to
+// discard duplicates.
+type OutboxMessage struct {
+	ID        string
+	EventType EventType
+	StudentID string
+	Payload   json.RawMessage // the EventEnvelope
+	CreatedAt time.Time
+
+	Status        OutboxStatus
+	Attempts      int
+	NextAttemptAt time.Time // when a pending message is due
+	LastError     string
+	DeliveredAt   *time.Time
+	DeliveredTo   []string // names of the sinks that accepted the message
+}
+
+// NewOutboxMessage returns the pending outbox message announcing e.
+func NewOutboxMessage(e Event) (*OutboxMessage, error) {
+	payload, err := json.Marshal(NewEventEnvelope(e))
+	if err != nil {
+		return nil, err
+	}
+	meta := e.Metadata()
+	return &OutboxMessage{
+		ID:            meta.ID,
+		EventType:     e.Type(),
+		StudentID:     e.Subject().ID,
+		Payload:       payload,
+		CreatedAt:     meta.OccurredAt,
+		Status:        OutboxPending,
+		NextAttemptAt: meta.OccurredAt,
+	}, nil
+}
+
+// Clone returns a deep copy of m.
+func (m *OutboxMessage) Clone() *OutboxMessage {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	c.Payload = append(json.RawMessage(nil), m.Payload...)
+	c.DeliveredTo = append([]string(nil), m.DeliveredTo...)
+	if m.DeliveredAt != nil {
+		deliveredAt := *m.DeliveredAt
+		c.DeliveredAt = &deliveredAt
+	}
+	return &c
+}
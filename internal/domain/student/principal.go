@@ -0,0 +1,18 @@
+package student
+
+import "slices"
+
+// RoleAdmin is the role allowed to manage webhook subscriptions.
+const RoleAdmin = "admin"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string   // stable ID of the caller, recorded as the audit actor
+	Name    string   // display name, if the identity provider gives one
+	Roles   []string // roles granted by the identity provider
+}
+
+// HasRole reports whether p was granted role.
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
@@ -0,0 +1,93 @@
+package student
+
+import "strings"
+
+// SortField names a student attribute that listings can be ordered by.
+type SortField string
+
+const (
+	SortByStudentNumber SortField = "student_number"
+	SortByName          SortField = "name"
+	SortByClass         SortField = "class"
+	SortByGrade         SortField = "grade"
+	SortByCreatedAt     SortField = "created_at"
+	SortByUpdatedAt     SortField = "updated_at"
+)
+
+// sortFields lists every valid SortField.
+var sortFields = map[SortField]bool{
+	SortByStudentNumber: true,
+	SortByName:          true,
+	SortByClass:         true,
+	SortByGrade:         true,
+	SortByCreatedAt:     true,
+	SortByUpdatedAt:     true,
+}
+
+// SortOrder is one ordering term of a listing.
+type SortOrder struct {
+	Field SortField
+	Desc  bool
+}
+
+// DefaultSort orders listings by student number when no sort is given.
+var DefaultSort = []SortOrder{{Field: SortByStudentNumber}}
+
+// MaxPageSize bounds the number of students returned per page.
+const MaxPageSize = 1000
+
+// ListQuery describes a filtered, ordered and paginated student listing.
+// Results are always ordered by ID after the requested sort terms so that
+// ordering is total and page tokens are stable.
+type ListQuery struct {
+	Class     string      // exact class match, empty for any
+	Grade     *int        // exact grade match, nil for any
+	Search    string      // case-insensitive substring of name, student number or email
+	Sort      []SortOrder // empty means DefaultSort
+	PageSize  int         // 0 returns every matching student
+	PageToken string      // opaque cursor from a previous StudentPage
+	Deleted   bool        // list the trash instead of active students
+}
+
+// StudentPage is one page of a student listing.
+type StudentPage struct {
+	Students      []*Student `json:"students"`
+	NextPageToken string     `json:"next_page_token,omitempty"`
+}
+
+// ParseSort parses a comma separated sort specification such as
+// "name,-created_at"; a leading "-" sorts that field descending.
+func ParseSort(spec string) ([]SortOrder, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	var orders []SortOrder
+	seen := make(map[SortField]bool)
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.TrimSpace(term)
+		order := SortOrder{}
+		if strings.HasPrefix(term, "-") {
+			order.Desc = true
+			term = term[1:]
+		}
+		order.Field = SortField(term)
+		if !sortFields[order.Field] {
+			return nil, NewInvalidQueryParameterError("sort", term)
+		}
+		if seen[order.Field] {
+			return nil, NewInvalidQueryParameterError("sort", term)
+		}
+		seen[order.Field] = true
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// String renders the sort specification in the form accepted by ParseSort.
+func (o SortOrder) String() string {
+	if o.Desc {
+		return "-" + string(o.Field)
+	}
+	return string(o.Field)
+}
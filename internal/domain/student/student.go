@@ -11,8 +11,44 @@ type Student struct {
 	Email         string    `json:"email"`
 	Class         string    `json:"class"`
 	Grade         *int      `json:"grade,omitempty"`
+	Version       int64     `json:"version"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
+
+	// DeletedAt is set while the student is in the trash. Trashed
+	// students are hidden from lookups and listings until restored, and
+	// their student number may be reused meanwhile.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// InitialVersion is the version of a newly created student. Every
+// successful update increments it by one.
+const InitialVersion int64 = 1
+
+// AnyVersion disables the version check of a conditional write.
+const AnyVersion int64 = 0
+
+// Clone returns a deep copy of s, including the Grade and DeletedAt
+// pointers, so that callers cannot mutate state shared with the original.
+func (s *Student) Clone() *Student {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	if s.Grade != nil {
+		grade := *s.Grade
+		c.Grade = &grade
+	}
+	if s.DeletedAt != nil {
+		deletedAt := *s.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return &c
+}
+
+// IsDeleted reports whether s is in the trash.
+func (s *Student) IsDeleted() bool {
+	return s.DeletedAt != nil
 }
 
 // CreateStudentRequest represents the request for creating a student.
@@ -34,6 +70,44 @@ type UpdateStudentRequest struct {
 	Email         *string `json:"email,omitempty"`
 	Class         *string `json:"class,omitempty"`
 	Grade         *int    `json:"grade,omitempty"`
+
+	// ClearGrade removes the optional grade; a nil Grade alone means
+	// "not provided". Set by merge patches carrying "grade": null.
+	ClearGrade bool `json:"-"`
+
+	// ExpectedVersion rejects the update with VersionConflict unless the
+	// stored student still has this version (If-Match). AnyVersion skips
+	// the check.
+	ExpectedVersion int64 `json:"-"`
+}
+
+// ReplaceStudentRequest represents the request for replacing every field
+// of a student (PUT). Omitting the optional grade clears it.
+type ReplaceStudentRequest struct {
+	StudentNumber string `json:"student_number"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	Class         string `json:"class"`
+	Grade         *int   `json:"grade,omitempty"`
+
+	// ExpectedVersion has the same meaning as in UpdateStudentRequest.
+	ExpectedVersion int64 `json:"-"`
+}
+
+// AsUpdate expresses the replacement as an update of every field.
+func (r *ReplaceStudentRequest) AsUpdate() *UpdateStudentRequest {
+	u := &UpdateStudentRequest{
+		StudentNumber:   &r.StudentNumber,
+		Name:            &r.Name,
+		Email:           &r.Email,
+		Class:           &r.Class,
+		Grade:           r.Grade,
+		ExpectedVersion: r.ExpectedVersion,
+	}
+	if r.Grade == nil {
+		u.ClearGrade = true
+	}
+	return u
 }
 
 // MinGrade and MaxGrade define the valid range for student grade.
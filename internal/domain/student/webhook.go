@@ -0,0 +1,92 @@
+package student
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// WebhookSubscription asks for the student events matching its filters to
+// be POSTed to URL, signed with Secret. An empty filter matches
+// everything.
+type WebhookSubscription struct {
+	ID         string      `json:"id"`
+	URL        string      `json:"url"`
+	Secret     string      `json:"-"` // only revealed when the subscription is created
+	EventTypes []EventType `json:"event_types"`
+	Classes    []string    `json:"classes"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// Matches reports whether the event in env passes the subscription's
+// filters; see EventEnvelope.InClass for the class filter.
+func (s *WebhookSubscription) Matches(env *EventEnvelope) bool {
+	if len(s.EventTypes) > 0 && !slices.Contains(s.EventTypes, env.Type) {
+		return false
+	}
+	return len(s.Classes) == 0 || env.InClass(s.Classes...)
+}
+
+// Clone returns a deep copy of s.
+func (s *WebhookSubscription) Clone() *WebhookSubscription {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.EventTypes = slices.Clone(s.EventTypes)
+	c.Classes = slices.Clone(s.Classes)
+	return &c
+}
+
+// CreateWebhookRequest represents the request for registering a webhook.
+// A secret is generated when none is given.
+type CreateWebhookRequest struct {
+	URL        string      `json:"url"`
+	Secret     string      `json:"secret,omitempty"`
+	EventTypes []EventType `json:"event_types,omitempty"`
+	Classes    []string    `json:"classes,omitempty"`
+}
+
+// EventTypes lists every domain event type, for validating filters.
+var EventTypes = []EventType{EventStudentCreated, EventStudentUpdated, EventStudentDeleted, EventStudentRestored}
+
+// WebhookDeliveryStatus is the state of a WebhookDelivery.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // waiting for its first or next attempt
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded" // the receiver answered 2xx
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"      // dead-lettered after too many failures
+)
+
+// WebhookDelivery is one event to be POSTed to one subscription, and the
+// log of the attempts made so far. There is at most one delivery per
+// subscription and event.
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	SubscriptionID string                `json:"subscription_id"`
+	EventID        string                `json:"event_id"`
+	EventType      EventType             `json:"event_type"`
+	Payload        json.RawMessage       `json:"-"` // the EventEnvelope
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	LastError      string                `json:"last_error,omitempty"`
+	ResponseStatus int                   `json:"response_status,omitempty"` // of the last attempt
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
+
+// Clone returns a deep copy of d.
+func (d *WebhookDelivery) Clone() *WebhookDelivery {
+	if d == nil {
+		return nil
+	}
+	c := *d
+	c.Payload = slices.Clone(d.Payload)
+	if d.DeliveredAt != nil {
+		deliveredAt := *d.DeliveredAt
+		c.DeliveredAt = &deliveredAt
+	}
+	return &c
+}
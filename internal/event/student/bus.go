@@ -0,0 +1,87 @@
+// Package event delivers student domain events to in-process subscribers.
+package event
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"todo/internal/domain/student"
+)
+
+// Subscriber handles a domain event. It runs on the publisher's goroutine,
+// after the change has been committed, so it must not block for long and
+// cannot undo the change.
+type Subscriber func(ctx context.Context, e student.Event)
+
+// Bus is an in-process publish/subscribe channel for student domain
+// events. Implementations must be safe for concurrent use.
+type Bus interface {
+	// Publish delivers events, in order, to every current subscriber.
+	Publish(ctx context.Context, events ...student.Event)
+
+	// Subscribe registers fn for every event published afterwards. The
+	// returned func unregisters it.
+	Subscribe(fn Subscriber) (unsubscribe func())
+}
+
+// SyncBus is a Bus that calls subscribers synchronously, in subscription
+// order. A panicking subscriber is logged and does not affect the others.
+type SyncBus struct {
+	mu   sync.RWMutex
+	subs []*subscription
+}
+
+type subscription struct {
+	fn Subscriber
+}
+
+// NewSyncBus creates a bus without subscribers.
+func NewSyncBus() *SyncBus {
+	return &SyncBus{}
+}
+
+// Publish delivers events to the subscribers registered when it is called.
+func (b *SyncBus) Publish(ctx context.Context, events ...student.Event) {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, e := range events {
+		for _, sub := range subs {
+			deliver(ctx, sub.fn, e)
+		}
+	}
+}
+
+// Subscribe registers fn.
+func (b *SyncBus) Subscribe(fn Subscriber) func() {
+	sub := &subscription{fn: fn}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Publish reads subs without holding the lock, so the slice is never
+	// modified in place.
+	b.subs = append(b.subs[:len(b.subs):len(b.subs)], sub)
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := make([]*subscription, 0, len(b.subs))
+		for _, s := range b.subs {
+			if s != sub {
+				subs = append(subs, s)
+			}
+		}
+		b.subs = subs
+	}
+}
+
+func deliver(ctx context.Context, fn Subscriber, e student.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("event: subscriber panicked on %s %s: %v", e.Type(), e.Metadata().ID, r)
+		}
+	}()
+	fn(ctx, e)
+}
@@ -0,0 +1,59 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"todo/internal/domain/student"
+)
+
+func newEvent(id string) student.Event {
+	return &student.StudentCreated{
+		EventMetadata: student.EventMetadata{ID: id},
+		Student:       &student.Student{StudentNumber: "2024001"},
+	}
+}
+
+func TestSyncBus_DeliversInOrder(t *testing.T) {
+	// Given: 兩個訂閱者
+	bus := NewSyncBus()
+	var got []string
+	bus.Subscribe(func(_ context.Context, e student.Event) {
+		got = append(got, "a:"+e.Metadata().ID)
+	})
+	unsubscribe := bus.Subscribe(func(_ context.Context, e student.Event) {
+		got = append(got, "b:"+e.Metadata().ID)
+	})
+
+	// When: 發布兩個事件
+	bus.Publish(context.Background(), newEvent("1"), newEvent("2"))
+
+	// Then: 每個訂閱者依訂閱順序收到每個事件
+	assert.Equal(t, []string{"a:1", "b:1", "a:2", "b:2"}, got)
+
+	// When: 取消訂閱後再發布
+	unsubscribe()
+	got = nil
+	bus.Publish(context.Background(), newEvent("3"))
+
+	// Then: 只有仍在訂閱的訂閱者收到事件
+	assert.Equal(t, []string{"a:3"}, got)
+}
+
+func TestSyncBus_IsolatesPanickingSubscriber(t *testing.T) {
+	// Given: 第一個訂閱者會 panic
+	bus := NewSyncBus()
+	bus.Subscribe(func(context.Context, student.Event) { panic("boom") })
+	delivered := 0
+	bus.Subscribe(func(context.Context, student.Event) { delivered++ })
+
+	// When: 發布事件
+	assert.NotPanics(t, func() {
+		bus.Publish(context.Background(), newEvent("1"))
+	})
+
+	// Then: 其他訂閱者仍然收到事件
+	assert.Equal(t, 1, delivered)
+}
@@ -0,0 +1,124 @@
+package event
+
+import (
+	"context"
+	"sync"
+
+	"todo/internal/domain/student"
+)
+
+// Feed keeps the most recent events published on a bus, as envelopes, and
+// passes new ones to live followers such as server-sent event streams. A
+// follower that reconnects can resume after the last event it received
+// as long as that event is still in the history.
+type Feed struct {
+	size   int // events kept for resuming
+	buffer int // events queued per follower before it is dropped
+
+	mu        sync.Mutex
+	history   []*student.EventEnvelope // oldest first, at most size
+	followers map[*Follower]struct{}
+	closed    bool
+}
+
+// Follower receives the events handled by a Feed after it started
+// following. C is closed when the follower falls too far behind or the
+// feed is closed; it can then follow again, possibly on another server,
+// from the last event it received.
+type Follower struct {
+	C <-chan *student.EventEnvelope
+
+	feed *Feed
+	ch   chan *student.EventEnvelope
+}
+
+// NewFeed creates a feed keeping the last size events, whose followers
+// may lag up to buffer events behind.
+func NewFeed(size, buffer int) *Feed {
+	return &Feed{
+		size:      size,
+		buffer:    buffer,
+		followers: make(map[*Follower]struct{}),
+	}
+}
+
+// Handle records e and passes it to every follower. It is a Subscriber and
+// never blocks: a follower whose queue is full is dropped.
+func (f *Feed) Handle(_ context.Context, e student.Event) {
+	env := student.NewEventEnvelope(e)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.history) == f.size {
+		// Shift rather than reslice, so the backing array does not grow.
+		copy(f.history, f.history[1:])
+		f.history = f.history[:len(f.history)-1]
+	}
+	f.history = append(f.history, env)
+
+	for follower := range f.followers {
+		select {
+		case follower.ch <- env:
+		default:
+			f.drop(follower)
+		}
+	}
+}
+
+// Follow starts following the feed. With an empty lastEventID only new
+// events are received. Otherwise the events handled after lastEventID are
+// returned first, and resumed is false if that event is no longer, or was
+// never, in the history, in which case events may have been missed.
+// The caller must Close the follower.
+func (f *Feed) Follow(lastEventID string) (backlog []*student.EventEnvelope, follower *Follower, resumed bool) {
+	ch := make(chan *student.EventEnvelope, f.buffer)
+	follower = &Follower{C: ch, feed: f, ch: ch}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resumed = true
+	if lastEventID != "" {
+		resumed = false
+		for i, env := range f.history {
+			if env.ID == lastEventID {
+				backlog = append(backlog, f.history[i+1:]...)
+				resumed = true
+				break
+			}
+		}
+	}
+	if f.closed {
+		close(ch)
+		return backlog, follower, resumed
+	}
+	f.followers[follower] = struct{}{}
+	return backlog, follower, resumed
+}
+
+// Close drops every follower and makes later ones start closed, so that
+// streams end and the server can shut down. Events are still recorded.
+func (f *Feed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for follower := range f.followers {
+		f.drop(follower)
+	}
+}
+
+// Close stops following; C is closed if it was not already.
+func (fw *Follower) Close() {
+	fw.feed.mu.Lock()
+	defer fw.feed.mu.Unlock()
+	if _, ok := fw.feed.followers[fw]; ok {
+		fw.feed.drop(fw)
+	}
+}
+
+// drop unregisters a follower and closes its channel. f.mu must be held.
+func (f *Feed) drop(follower *Follower) {
+	delete(f.followers, follower)
+	close(follower.ch)
+}
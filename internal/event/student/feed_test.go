@@ -0,0 +1,123 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/internal/domain/student"
+)
+
+// receive drains the events queued for follower.
+func receive(follower *Follower) []string {
+	var ids []string
+	for {
+		select {
+		case env, ok := <-follower.C:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, env.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func envelopeIDs(envs []*student.EventEnvelope) []string {
+	ids := make([]string, len(envs))
+	for i, env := range envs {
+		ids[i] = env.ID
+	}
+	return ids
+}
+
+func TestFeed_FollowsNewEvents(t *testing.T) {
+	// Given: 訂閱事件匯流排的 feed 與一個追蹤者
+	bus := NewSyncBus()
+	feed := NewFeed(10, 10)
+	bus.Subscribe(feed.Handle)
+	bus.Publish(context.Background(), newEvent("1"))
+
+	backlog, follower, resumed := feed.Follow("")
+	defer follower.Close()
+
+	// When: 發布新事件
+	bus.Publish(context.Background(), newEvent("2"), newEvent("3"))
+
+	// Then: 追蹤者只收到開始追蹤後的事件
+	assert.True(t, resumed)
+	assert.Empty(t, backlog)
+	assert.Equal(t, []string{"2", "3"}, receive(follower))
+}
+
+func TestFeed_ResumesAfterLastEventID(t *testing.T) {
+	// Given: 只保留三個事件的 feed 已處理五個事件
+	feed := NewFeed(3, 10)
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		feed.Handle(context.Background(), newEvent(id))
+	}
+
+	// When: 追蹤者從仍在歷史中的事件續傳
+	backlog, follower, resumed := feed.Follow("3")
+	follower.Close()
+
+	// Then: 收到該事件之後的事件
+	assert.True(t, resumed)
+	assert.Equal(t, []string{"4", "5"}, envelopeIDs(backlog))
+
+	// When: 從已被淘汰的事件續傳
+	backlog, follower, resumed = feed.Follow("1")
+	follower.Close()
+
+	// Then: 回報無法續傳
+	assert.False(t, resumed)
+	assert.Empty(t, backlog)
+}
+
+func TestFeed_DropsLaggingFollower(t *testing.T) {
+	// Given: 佇列只能容納兩個事件的追蹤者
+	feed := NewFeed(10, 2)
+	_, slow, _ := feed.Follow("")
+	defer slow.Close()
+
+	// When: 追蹤者未讀取時發布三個事件
+	for _, id := range []string{"1", "2", "3"} {
+		feed.Handle(context.Background(), newEvent(id))
+	}
+
+	// Then: 追蹤者在收到已排入的事件後被關閉
+	assert.Equal(t, []string{"1", "2"}, receive(slow))
+	_, open := <-slow.C
+	assert.False(t, open)
+
+	// And: 可以從最後收到的事件續傳
+	backlog, follower, resumed := feed.Follow("2")
+	defer follower.Close()
+	require.True(t, resumed)
+	assert.Equal(t, []string{"3"}, envelopeIDs(backlog))
+}
+
+func TestFeed_CloseDropsFollowers(t *testing.T) {
+	// Given: 一個追蹤中的追蹤者
+	feed := NewFeed(10, 10)
+	_, follower, _ := feed.Follow("")
+	defer follower.Close()
+	feed.Handle(context.Background(), newEvent("1"))
+
+	// When: 伺服器關閉時關閉 feed
+	feed.Close()
+
+	// Then: 追蹤者收完已排入的事件後被關閉
+	assert.Equal(t, []string{"1"}, receive(follower))
+	_, open := <-follower.C
+	assert.False(t, open)
+
+	// And: 之後的追蹤者一開始就被關閉
+	_, late, _ := feed.Follow("")
+	defer late.Close()
+	_, open = <-late.C
+	assert.False(t, open)
+}
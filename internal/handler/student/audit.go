@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"todo/internal/domain/student"
+	studentusecase "todo/internal/usecase/student"
+)
+
+// Headers identifying a request and its caller in the audit trail.
+const (
+	RequestIDHeader = "X-Request-ID"
+	ActorHeader     = "X-Actor" // trusted only when requests are not authenticated
+)
+
+// maxRequestIDLength bounds a client-supplied request ID; longer ones are
+// replaced by a generated ID.
+const maxRequestIDLength = 128
+
+// requestContext passes the request ID and principal of a request to the
+// use case for the audit trail. The request ID is taken from
+// X-Request-ID, or generated, and echoed in the response. With an
+// Authenticator the request is rejected unless its bearer token
+// identifies the principal. Without one the principal is whatever the
+// caller puts in X-Actor, and changes are anonymous if it is absent.
+func (h *Handler) requestContext(c *gin.Context) {
+	requestID := c.GetHeader(RequestIDHeader)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = uuid.New().String()
+	}
+	c.Header(RequestIDHeader, requestID)
+
+	ctx := studentusecase.WithRequestID(c.Request.Context(), requestID)
+	if h.auth != nil {
+		principal, ok := h.authenticate(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+		ctx = studentusecase.WithPrincipal(ctx, principal)
+	} else if actor := c.GetHeader(ActorHeader); actor != "" {
+		ctx = studentusecase.WithPrincipal(ctx, &student.Principal{Subject: actor})
+	}
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// GetStudentHistory handles GET /api/students/:studentNumber/history
+// Returns the audit trail of the student, oldest first: who changed which
+// fields, when and in which request. A trashed student's history is
+// returned when no active student has the number.
+func (h *Handler) GetStudentHistory(c *gin.Context) {
+	entries, err := h.useCase.GetStudentHistory(c.Request.Context(), c.Param("studentNumber"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"todo/internal/domain/student"
+	studentusecase "todo/internal/usecase/student"
+)
+
+// Authenticator identifies the caller presenting a bearer token. It is
+// satisfied by the JWT verifier; any error means the token is not
+// accepted.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*student.Principal, error)
+}
+
+// WithAuthenticator requires every request to carry a bearer token
+// accepted by a. Without it requests are not authenticated.
+func WithAuthenticator(a Authenticator) Option {
+	return func(h *Handler) {
+		h.auth = a
+	}
+}
+
+// bearerScheme prefixes the token in the Authorization header (RFC 6750).
+const bearerScheme = "Bearer "
+
+// AccessTokenParam is the query parameter carrying the bearer token of
+// clients that cannot set headers (RFC 6750, section 2.3). It is only
+// accepted on the live feed route, for browsers' EventSource.
+const AccessTokenParam = "access_token"
+
+// accessTokenFromQuery passes the token in AccessTokenParam on to
+// authenticate as if it were sent in the Authorization header, unless
+// that header is set.
+func accessTokenFromQuery(c *gin.Context) {
+	if token := c.Query(AccessTokenParam); token != "" && c.GetHeader("Authorization") == "" {
+		c.Request.Header.Set("Authorization", bearerScheme+token)
+	}
+	c.Next()
+}
+
+// RedactAccessToken returns the request URI uri, as logged, with the value
+// of AccessTokenParam replaced so that tokens do not end up in logs.
+func RedactAccessToken(uri string) string {
+	path, rawQuery, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || !query.Has(AccessTokenParam) {
+		return uri
+	}
+	query.Set(AccessTokenParam, "REDACTED")
+	return path + "?" + query.Encode()
+}
+
+// authenticate returns the principal of the request's bearer token, or
+// responds 401 and returns false.
+func (h *Handler) authenticate(c *gin.Context) (*student.Principal, bool) {
+	authorization := c.GetHeader("Authorization")
+	if len(authorization) < len(bearerScheme) || !strings.EqualFold(authorization[:len(bearerScheme)], bearerScheme) {
+		h.handleUnauthenticated(c, false)
+		return nil, false
+	}
+
+	token := strings.TrimSpace(authorization[len(bearerScheme):])
+	principal, err := h.auth.Authenticate(c.Request.Context(), token)
+	if err != nil {
+		h.handleUnauthenticated(c, true)
+		return nil, false
+	}
+	return principal, true
+}
+
+// handleUnauthenticated responds to a request without an accepted bearer
+// token, telling the client with WWW-Authenticate whether the token it
+// presented was rejected.
+func (h *Handler) handleUnauthenticated(c *gin.Context, tokenRejected bool) {
+	challenge := `Bearer realm="student-api"`
+	if tokenRejected {
+		challenge += `, error="invalid_token"`
+	}
+	c.Header("WWW-Authenticate", challenge)
+	h.writeError(c, &apiError{
+		status: http.StatusUnauthorized,
+		code:   codeUnauthenticated,
+	})
+}
+
+// requireRole returns a middleware, run after requestContext, that
+// responds 403 unless the principal was granted role. Without an
+// Authenticator there is no principal and every request is let through.
+func (h *Handler) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.auth == nil {
+			c.Next()
+			return
+		}
+		principal, _ := studentusecase.PrincipalFrom(c.Request.Context())
+		if !principal.HasRole(role) {
+			h.writeError(c, &apiError{
+				status: http.StatusForbidden,
+				code:   codeForbidden,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"todo/internal/domain/student"
+)
+
+// BatchRequest is the body of POST /api/students:batch.
+type BatchRequest struct {
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+// BatchOperationRequest is one operation of a batch. Student holds the
+// new student of a create and the JSON Merge Patch of an update; Version,
+// when set, makes an update or delete conditional like If-Match.
+type BatchOperationRequest struct {
+	Op            string          `json:"op"`
+	StudentNumber string          `json:"student_number,omitempty"`
+	Version       int64           `json:"version,omitempty"`
+	Student       json.RawMessage `json:"student,omitempty"`
+}
+
+// BatchResponse is the response of POST /api/students:batch, with one
+// result per operation in request order.
+type BatchResponse struct {
+	Atomic    bool                   `json:"atomic"`
+	Committed bool                   `json:"committed"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+	Results   []BatchOperationResult `json:"results"`
+}
+
+// BatchOperationResult is the outcome of one batch operation.
+type BatchOperationResult struct {
+	Index   int              `json:"index"`
+	Op      string           `json:"op"`
+	Status  string           `json:"status"`
+	Student *student.Student `json:"student,omitempty"`
+	Errors  []FieldError     `json:"errors,omitempty"`
+}
+
+// customMethod routes the custom methods of the student collection, such
+// as POST /api/students:batch. gin cannot match a literal colon inside a
+// path segment, so they share a single wildcard route.
+func (h *Handler) customMethod(c *gin.Context) {
+	switch c.Param("customMethod") {
+	case "students:batch":
+		h.BatchStudents(c)
+	default:
+		c.AbortWithStatus(http.StatusNotFound)
+	}
+}
+
+// BatchStudents handles POST /api/students:batch
+// Applies a list of create, update and delete operations and reports the
+// outcome of each. With ?atomic=true the first failure rolls back every
+// change and the response has "committed": false.
+func (h *Handler) BatchStudents(c *gin.Context) {
+	atomic, err := strconv.ParseBool(c.DefaultQuery("atomic", "false"))
+	if err != nil {
+		h.handleError(c, student.NewInvalidQueryParameterError("atomic", c.Query("atomic")))
+		return
+	}
+
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleInvalidRequest(c)
+		return
+	}
+	ops, err := req.operations()
+	if err != nil {
+		h.handleInvalidRequest(c)
+		return
+	}
+
+	report, err := h.useCase.BatchStudents(c.Request.Context(), ops, atomic)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batchResponse(report, negotiateLanguage(c)))
+}
+
+// operations converts the request into batch operations. A malformed
+// operation rejects the whole batch before anything is applied.
+func (r *BatchRequest) operations() ([]*student.BatchOperation, error) {
+	if len(r.Operations) > student.MaxBatchOperations {
+		return nil, errors.New("too many operations")
+	}
+
+	ops := make([]*student.BatchOperation, len(r.Operations))
+	for i, o := range r.Operations {
+		op := &student.BatchOperation{
+			Type:            student.BatchOperationType(o.Op),
+			StudentNumber:   o.StudentNumber,
+			ExpectedVersion: o.Version,
+		}
+		switch op.Type {
+		case student.BatchCreate:
+			if o.Student == nil {
+				return nil, errors.New("create requires student")
+			}
+			op.Create = &student.CreateStudentRequest{}
+			if err := json.Unmarshal(o.Student, op.Create); err != nil {
+				return nil, err
+			}
+		case student.BatchUpdate:
+			if o.StudentNumber == "" || o.Student == nil {
+				return nil, errors.New("update requires student_number and student")
+			}
+			update, err := parseMergePatch(o.Student)
+			if err != nil {
+				return nil, err
+			}
+			update.ExpectedVersion = o.Version
+			op.Update = update
+		case student.BatchDelete:
+			if o.StudentNumber == "" {
+				return nil, errors.New("delete requires student_number")
+			}
+		default:
+			return nil, errors.New("unknown op")
+		}
+		ops[i] = op
+	}
+	return ops, nil
+}
+
+// batchResponse renders report with its errors localized in lang.
+func batchResponse(report *student.BatchReport, lang student.Language) *BatchResponse {
+	resp := &BatchResponse{
+		Atomic:    report.Atomic,
+		Committed: report.Committed,
+		Succeeded: report.Succeeded,
+		Failed:    report.Failed,
+		Results:   make([]BatchOperationResult, len(report.Results)),
+	}
+	for i, result := range report.Results {
+		resp.Results[i] = BatchOperationResult{
+			Index:   i,
+			Op:      string(result.Type),
+			Status:  string(result.Status),
+			Student: result.Student,
+		}
+		if result.Err != nil {
+			resp.Results[i].Errors = toAPIError(result.Err).allFieldErrors(lang)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"todo/internal/domain/student"
+)
+
+// Media types offered for error responses.
+const (
+	mediaTypeJSON        = "application/json"
+	mediaTypeProblemJSON = "application/problem+json"
+)
+
+// Codes for errors raised by the HTTP layer itself.
+const (
+	codeInvalidRequest       = "INVALID_REQUEST"
+	codeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	codePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	codeValidationFailed     = "VALIDATION_FAILED"
+	codePatchTestFailed      = "PATCH_TEST_FAILED"
+	codeInvalidCSV           = "INVALID_CSV"
+	codeUnauthenticated      = "UNAUTHENTICATED"
+	codeForbidden            = "FORBIDDEN"
+	codeInternalError        = "INTERNAL_ERROR"
+)
+
+// errNotAnObject reports a JSON body that is not an object.
+var errNotAnObject = errors.New("request body must be a JSON object")
+
+// ProblemTypeBase prefixes the problem type URI of every error code.
+const ProblemTypeBase = "urn:student-api:problem:"
+
+// problemTitles holds the short summary of each problem type per language.
+var problemTitles = map[string]map[student.Language]string{
+	codeInvalidRequest: {
+		student.LanguageZhTW: "請求格式無效",
+		student.LanguageEn:   "Invalid request format",
+		student.LanguageJa:   "リクエスト形式が無効です",
+	},
+	codeUnsupportedMediaType: {
+		student.LanguageZhTW: "不支援的媒體類型",
+		student.LanguageEn:   "Unsupported media type",
+		student.LanguageJa:   "サポートされていないメディアタイプです",
+	},
+	codePayloadTooLarge: {
+		student.LanguageZhTW: "請求內容過大",
+		student.LanguageEn:   "Payload too large",
+		student.LanguageJa:   "リクエストの内容が大きすぎます",
+	},
+	codeValidationFailed: {
+		student.LanguageZhTW: "請求驗證失敗",
+		student.LanguageEn:   "Validation failed",
+		student.LanguageJa:   "入力値の検証に失敗しました",
+	},
+	codePatchTestFailed: {
+		student.LanguageZhTW: "修補前置條件不成立",
+		student.LanguageEn:   "Patch test operation failed",
+		student.LanguageJa:   "パッチの前提条件を満たしていません",
+	},
+	codeInvalidCSV: {
+		student.LanguageZhTW: "CSV 檔案格式無效",
+		student.LanguageEn:   "Invalid CSV file",
+		student.LanguageJa:   "CSV ファイルの形式が無効です",
+	},
+	codeUnauthenticated: {
+		student.LanguageZhTW: "未通過身分驗證",
+		student.LanguageEn:   "Authentication required",
+		student.LanguageJa:   "認証が必要です",
+	},
+	codeForbidden: {
+		student.LanguageZhTW: "權限不足",
+		student.LanguageEn:   "Forbidden",
+		student.LanguageJa:   "権限がありません",
+	},
+	codeInternalError: {
+		student.LanguageZhTW: "伺服器內部錯誤",
+		student.LanguageEn:   "Internal server error",
+		student.LanguageJa:   "サーバー内部エラー",
+	},
+	string(student.ErrorTypeMissingRequiredField): {
+		student.LanguageZhTW: "缺少必填欄位",
+		student.LanguageEn:   "Missing required field",
+		student.LanguageJa:   "必須項目がありません",
+	},
+	string(student.ErrorTypeInvalidEmail): {
+		student.LanguageZhTW: "電子郵件格式無效",
+		student.LanguageEn:   "Invalid email",
+		student.LanguageJa:   "メールアドレスが無効です",
+	},
+	string(student.ErrorTypeInvalidGrade): {
+		student.LanguageZhTW: "年級超出範圍",
+		student.LanguageEn:   "Invalid grade",
+		student.LanguageJa:   "学年が範囲外です",
+	},
+	string(student.ErrorTypeStudentNumberAlreadyExists): {
+		student.LanguageZhTW: "學號已存在",
+		student.LanguageEn:   "Student number already exists",
+		student.LanguageJa:   "学籍番号が重複しています",
+	},
+	string(student.ErrorTypeStudentNotFound): {
+		student.LanguageZhTW: "學生不存在",
+		student.LanguageEn:   "Student not found",
+		student.LanguageJa:   "学生が見つかりません",
+	},
+	string(student.ErrorTypeVersionConflict): {
+		student.LanguageZhTW: "版本衝突",
+		student.LanguageEn:   "Version conflict",
+		student.LanguageJa:   "バージョンの競合",
+	},
+	string(student.ErrorTypeInvalidQueryParameter): {
+		student.LanguageZhTW: "查詢參數無效",
+		student.LanguageEn:   "Invalid query parameter",
+		student.LanguageJa:   "クエリパラメータが無効です",
+	},
+	string(student.ErrorTypeInvalidWebhookURL): {
+		student.LanguageZhTW: "Webhook 網址無效",
+		student.LanguageEn:   "Invalid webhook URL",
+		student.LanguageJa:   "Webhook の URL が無効です",
+	},
+	string(student.ErrorTypeInvalidEventType): {
+		student.LanguageZhTW: "事件類型無效",
+		student.LanguageEn:   "Invalid event type",
+		student.LanguageJa:   "イベント種別が無効です",
+	},
+	string(student.ErrorTypeWebhookNotFound): {
+		student.LanguageZhTW: "Webhook 不存在",
+		student.LanguageEn:   "Webhook not found",
+		student.LanguageJa:   "Webhook が見つかりません",
+	},
+}
+
+// localizedText returns the entry of texts for lang, falling back to
+// student.DefaultLanguage.
+func localizedText(texts map[student.Language]string, lang student.Language) string {
+	if t, ok := texts[lang]; ok {
+		return t
+	}
+	return texts[student.DefaultLanguage]
+}
+
+// ErrorResponse represents a standard error response.
+// For validation failures Error and Code describe the first violation and
+// Errors lists every violation.
+type ErrorResponse struct {
+	Error  string       `json:"error"`
+	Code   string       `json:"code,omitempty"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes a single field violation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ProblemDetails is an RFC 9457 problem details object. Code and Field
+// are extension members carrying StudentError.Type and StudentError.Field.
+type ProblemDetails struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code,omitempty"`
+	Field    string       `json:"field,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// apiError is the format-neutral description of an error response.
+// Messages are localized when the response is written.
+type apiError struct {
+	status     int
+	code       string
+	field      string
+	source     *student.StudentError    // nil for errors raised by the handler
+	violations student.ValidationErrors // set for validation failures
+}
+
+// handleError maps domain errors to HTTP responses.
+func (h *Handler) handleError(c *gin.Context, err error) {
+	h.writeError(c, toAPIError(err))
+}
+
+// handleInvalidRequest responds to a body that could not be decoded.
+func (h *Handler) handleInvalidRequest(c *gin.Context) {
+	h.writeError(c, &apiError{
+		status: http.StatusBadRequest,
+		code:   codeInvalidRequest,
+	})
+}
+
+// handleUnsupportedMediaType responds to a body in an unsupported format.
+func (h *Handler) handleUnsupportedMediaType(c *gin.Context) {
+	h.writeError(c, &apiError{
+		status: http.StatusUnsupportedMediaType,
+		code:   codeUnsupportedMediaType,
+	})
+}
+
+// handlePayloadTooLarge responds to a body exceeding the size accepted by
+// the endpoint.
+func (h *Handler) handlePayloadTooLarge(c *gin.Context) {
+	h.writeError(c, &apiError{
+		status: http.StatusRequestEntityTooLarge,
+		code:   codePayloadTooLarge,
+	})
+}
+
+// handlePatchTestFailed responds to a JSON Patch whose "test" operation
+// did not match the current student.
+func (h *Handler) handlePatchTestFailed(c *gin.Context) {
+	h.writeError(c, &apiError{
+		status: http.StatusConflict,
+		code:   codePatchTestFailed,
+	})
+}
+
+// handleInvalidCSV responds to an import file that cannot be read as
+// CSV. A missing required column is reported in the field member.
+func (h *Handler) handleInvalidCSV(c *gin.Context, err error) {
+	e := &apiError{
+		status: http.StatusBadRequest,
+		code:   codeInvalidCSV,
+	}
+	var missing *missingColumnError
+	if errors.As(err, &missing) {
+		e.field = missing.field
+	}
+	h.writeError(c, e)
+}
+
+// toAPIError maps err to its status code and error description.
+func toAPIError(err error) *apiError {
+	var validationErrs student.ValidationErrors
+	if errors.As(err, &validationErrs) && len(validationErrs) > 0 {
+		return &apiError{
+			status:     http.StatusBadRequest,
+			code:       string(validationErrs[0].Type),
+			source:     validationErrs[0],
+			violations: validationErrs,
+		}
+	}
+
+	var studentErr *student.StudentError
+	if errors.As(err, &studentErr) {
+		e := &apiError{
+			code:   string(studentErr.Type),
+			field:  studentErr.Field,
+			source: studentErr,
+		}
+		switch studentErr.Type {
+		case student.ErrorTypeMissingRequiredField:
+			// Source: "姓名為必填欄位" (第 39 行)
+			e.status = http.StatusBadRequest
+		case student.ErrorTypeInvalidEmail:
+			// Source: "無效的電子郵件格式" (第 51 行)
+			e.status = http.StatusBadRequest
+		case student.ErrorTypeInvalidGrade:
+			// Source: "年級必須在 1-6 之間" (第 82 行)
+			e.status = http.StatusBadRequest
+		case student.ErrorTypeInvalidQueryParameter,
+			student.ErrorTypeInvalidWebhookURL,
+			student.ErrorTypeInvalidEventType:
+			e.status = http.StatusBadRequest
+		case student.ErrorTypeStudentNumberAlreadyExists:
+			// Source: "學號已存在" (第 45 行)
+			e.status = http.StatusConflict
+		case student.ErrorTypeStudentNotFound:
+			// Source: "學生不存在" (第 57 行)
+			e.status = http.StatusNotFound
+		case student.ErrorTypeWebhookNotFound:
+			e.status = http.StatusNotFound
+		case student.ErrorTypeVersionConflict:
+			e.status = http.StatusPreconditionFailed
+		default:
+			return internalError()
+		}
+		return e
+	}
+
+	// Unknown error
+	return internalError()
+}
+
+func internalError() *apiError {
+	return &apiError{
+		status: http.StatusInternalServerError,
+		code:   codeInternalError,
+	}
+}
+
+// writeError renders e in the language chosen from Accept-Language, as
+// RFC 9457 problem details when the client asks for
+// application/problem+json and as ErrorResponse otherwise.
+func (h *Handler) writeError(c *gin.Context, e *apiError) {
+	lang := negotiateLanguage(c)
+	c.Header("Content-Language", string(lang))
+
+	if c.NegotiateFormat(mediaTypeJSON, mediaTypeProblemJSON) == mediaTypeProblemJSON {
+		c.Header("Content-Type", mediaTypeProblemJSON)
+		c.JSON(e.status, e.problem(c.Request.URL.Path, lang))
+		return
+	}
+
+	c.JSON(e.status, ErrorResponse{
+		Error:  e.message(lang),
+		Code:   e.code,
+		Errors: e.fieldErrors(lang),
+	})
+}
+
+// message returns the localized human-readable message of e.
+func (e *apiError) message(lang student.Language) string {
+	if e.source != nil {
+		return e.source.Localize(lang)
+	}
+	return localizedText(problemTitles[e.code], lang)
+}
+
+// fieldErrors returns the localized violations of e, if any.
+func (e *apiError) fieldErrors(lang student.Language) []FieldError {
+	if len(e.violations) == 0 {
+		return nil
+	}
+	errs := make([]FieldError, len(e.violations))
+	for i, v := range e.violations {
+		errs[i] = FieldError{
+			Field:   v.Field,
+			Code:    string(v.Type),
+			Message: v.Localize(lang),
+		}
+	}
+	return errs
+}
+
+// allFieldErrors returns the localized violations of e, describing e
+// itself as the only violation when it has no list.
+func (e *apiError) allFieldErrors(lang student.Language) []FieldError {
+	if len(e.violations) > 0 {
+		return e.fieldErrors(lang)
+	}
+	return []FieldError{{
+		Field:   e.field,
+		Code:    e.code,
+		Message: e.message(lang),
+	}}
+}
+
+// problem converts e into problem details for the request at instance.
+func (e *apiError) problem(instance string, lang student.Language) *ProblemDetails {
+	p := &ProblemDetails{
+		Status:   e.status,
+		Detail:   e.message(lang),
+		Instance: instance,
+		Code:     e.code,
+		Field:    e.field,
+		Errors:   e.fieldErrors(lang),
+	}
+
+	// A list of violations is reported as one validation problem.
+	code := e.code
+	if len(e.violations) > 0 {
+		code = codeValidationFailed
+		p.Code = ""
+	}
+	p.Type = ProblemTypeBase + strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+	p.Title = localizedText(problemTitles[code], lang)
+	if p.Title == "" {
+		p.Title = http.StatusText(e.status)
+	}
+	return p
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"todo/internal/domain/student"
+)
+
+// Export formats of GET /api/students/export.
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+	exportFormatXLSX   = "xlsx"
+)
+
+// exportContentTypes holds the media type of each export format.
+var exportContentTypes = map[string]string{
+	exportFormatCSV:    "text/csv; charset=utf-8",
+	exportFormatNDJSON: "application/x-ndjson",
+	exportFormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// exportHeader names the roster columns. The headers match the ones
+// accepted by the CSV import, so an exported file can be imported again.
+var exportHeader = []string{"學號", "姓名", "電子郵件", "班級", "年級", "建立時間", "更新時間"}
+
+// exportWriteTimeout is how long the client has to accept each chunk of
+// an export. It replaces the server's write timeout, which would cut off
+// large or slow downloads.
+const exportWriteTimeout = 30 * time.Second
+
+// utf8BOM lets Excel recognise a CSV file as UTF-8, so that Chinese text
+// is not garbled.
+const utf8BOM = "\ufeff"
+
+// formulaTriggers are the leading characters that make spreadsheet
+// applications evaluate a CSV cell as a formula.
+const formulaTriggers = "=+-@\t\r"
+
+// neutraliseFormula prefixes v with an apostrophe if a spreadsheet would
+// evaluate it as a formula, so that it is shown as text instead. The
+// import strips the apostrophe again.
+func neutraliseFormula(v string) string {
+	if v != "" && strings.ContainsRune(formulaTriggers, rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// rosterWriter encodes a student roster in one export format.
+type rosterWriter interface {
+	WriteStudent(s *student.Student) error
+	Close() error
+}
+
+// ExportStudents handles GET /api/students/export
+// Streams every student matching ?class=, ?grade= and ?q= in the order
+// given by ?sort=, as ?format=csv (default), ndjson or xlsx.
+func (h *Handler) ExportStudents(c *gin.Context) {
+	format := c.DefaultQuery("format", exportFormatCSV)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		h.handleError(c, student.NewInvalidQueryParameterError("format", format))
+		return
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// The response starts with the first student, so that errors raised
+	// before any data was read can still be reported with a status code.
+	var w rosterWriter
+	start := func() error {
+		filename := "students-" + time.Now().Format("20060102") + "." + format
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		c.Status(http.StatusOK)
+
+		var err error
+		w, err = newRosterWriter(&deadlineWriter{w: c.Writer, rc: http.NewResponseController(c.Writer)}, format)
+		return err
+	}
+
+	err = h.useCase.ExportStudents(c.Request.Context(), q, func(s *student.Student) error {
+		if w == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return w.WriteStudent(s)
+	})
+	if err == nil && w == nil {
+		err = start()
+	}
+	if err != nil && w == nil {
+		h.handleError(c, err)
+		return
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		// The status has been sent; the truncated body is all that can
+		// signal the failure.
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
+
+// deadlineWriter extends the write deadline of the connection before
+// every chunk, so that an export is only cut off when the client stops
+// reading.
+type deadlineWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	// Writers that cannot set deadlines, such as recorders, have none.
+	_ = w.rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	return w.w.Write(p)
+}
+
+// newRosterWriter starts a roster in format on out.
+func newRosterWriter(out io.Writer, format string) (rosterWriter, error) {
+	switch format {
+	case exportFormatNDJSON:
+		return &ndjsonRosterWriter{enc: json.NewEncoder(out)}, nil
+	case exportFormatXLSX:
+		w, err := newXLSXWriter(out, "Students")
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxRosterWriter{w: w}, w.WriteRow(toCells(exportHeader))
+	default:
+		if _, err := io.WriteString(out, utf8BOM); err != nil {
+			return nil, err
+		}
+		w := &csvRosterWriter{w: csv.NewWriter(out)}
+		return w, w.w.Write(exportHeader)
+	}
+}
+
+// rosterRecord returns the column values of s in exportHeader order. The
+// grade is an int, or nil when unknown.
+func rosterRecord(s *student.Student) []any {
+	var grade any
+	if s.Grade != nil {
+		grade = *s.Grade
+	}
+	return []any{
+		s.StudentNumber,
+		s.Name,
+		s.Email,
+		s.Class,
+		grade,
+		s.CreatedAt.Format(time.RFC3339),
+		s.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func toCells(values []string) []any {
+	cells := make([]any, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	return cells
+}
+
+// csvRosterWriter writes RFC 4180 CSV. Text cells that a spreadsheet would
+// evaluate as formulas are neutralised.
+type csvRosterWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvRosterWriter) WriteStudent(s *student.Student) error {
+	values := rosterRecord(s)
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			record[i] = neutraliseFormula(v)
+		case int:
+			record[i] = strconv.Itoa(v)
+		}
+	}
+	return w.w.Write(record)
+}
+
+func (w *csvRosterWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// ndjsonRosterWriter writes one JSON student per line.
+type ndjsonRosterWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonRosterWriter) WriteStudent(s *student.Student) error {
+	return w.enc.Encode(s)
+}
+
+func (w *ndjsonRosterWriter) Close() error {
+	return nil
+}
+
+// xlsxRosterWriter writes a single-sheet Excel workbook.
+type xlsxRosterWriter struct {
+	w *xlsxWriter
+}
+
+func (w *xlsxRosterWriter) WriteStudent(s *student.Student) error {
+	return w.w.WriteRow(rosterRecord(s))
+}
+
+func (w *xlsxRosterWriter) Close() error {
+	return w.w.Close()
+}
@@ -1,8 +1,9 @@
 package handler
 
 import (
-	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -13,20 +14,25 @@ import (
 // Handler handles HTTP requests for student management.
 type Handler struct {
 	useCase *studentusecase.UseCase
+	auth    Authenticator // nil when requests are not authenticated
 }
 
+// Option configures a Handler.
+type Option func(*Handler)
+
 // NewHandler creates a new student HTTP handler.
-func NewHandler(useCase *studentusecase.UseCase) *Handler {
-	return &Handler{
+func NewHandler(useCase *studentusecase.UseCase, opts ...Option) *Handler {
+	h := &Handler{
 		useCase: useCase,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
-// ErrorResponse represents a standard error response.
-type ErrorResponse struct {
-	Error string `json:"error"`
-	Code  string `json:"code,omitempty"`
-}
+// NextPageTokenHeader carries the cursor for the next page of a listing.
+const NextPageTokenHeader = "X-Next-Page-Token"
 
 // CreateStudent handles POST /api/students
 // Source: "我提交新學生資訊" (第 5-10 行)
@@ -36,10 +42,7 @@ type ErrorResponse struct {
 func (h *Handler) CreateStudent(c *gin.Context) {
 	var req student.CreateStudentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: "Invalid request format",
-			Code:  "INVALID_REQUEST",
-		})
+		h.handleInvalidRequest(c)
 		return
 	}
 
@@ -49,10 +52,13 @@ func (h *Handler) CreateStudent(c *gin.Context) {
 		return
 	}
 
+	setETag(c, s)
 	c.JSON(http.StatusCreated, s)
 }
 
 // GetStudent handles GET /api/students/:studentNumber
+// With ?as_of= (RFC 3339) it returns the student that had the number at
+// that time, as it was then, without an ETag.
 // Source: "我使用學號查詢學生" (第 12-16 行)
 //
 // When: 我使用學號「2024001」查詢學生
@@ -60,132 +66,365 @@ func (h *Handler) CreateStudent(c *gin.Context) {
 func (h *Handler) GetStudent(c *gin.Context) {
 	studentNumber := c.Param("studentNumber")
 
+	if v := c.Query("as_of"); v != "" {
+		asOf, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.handleError(c, student.NewInvalidQueryParameterError("as_of", v))
+			return
+		}
+		s, err := h.useCase.GetStudentAsOf(c.Request.Context(), studentNumber, asOf)
+		if err != nil {
+			h.handleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, s)
+		return
+	}
+
 	s, err := h.useCase.GetStudent(c.Request.Context(), studentNumber)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
+	setETag(c, s)
+	c.JSON(http.StatusOK, s)
+}
+
+// GetStudentByID handles GET /api/students/by-id/:id
+func (h *Handler) GetStudentByID(c *gin.Context) {
+	s, err := h.useCase.GetStudentByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	setETag(c, s)
 	c.JSON(http.StatusOK, s)
 }
 
 // GetAllStudents handles GET /api/students
 // Source: "我請求查詢所有學生" (第 18-22 行)
 //
+// Supports ?class=, ?grade=, ?q=, ?sort=name,-created_at and
+// ?page_size=&page_token=. The body stays a JSON array; the cursor for the
+// next page is returned in the X-Next-Page-Token header.
+//
 // When: 我請求查詢所有學生
 // Then: 系統應該返回所有學生記錄
 func (h *Handler) GetAllStudents(c *gin.Context) {
-	students, err := h.useCase.GetAllStudents(c.Request.Context())
+	q, err := parseListQuery(c)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, students)
+	page, err := h.useCase.ListStudents(c.Request.Context(), q)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	if page.NextPageToken != "" {
+		c.Header(NextPageTokenHeader, page.NextPageToken)
+	}
+	c.JSON(http.StatusOK, page.Students)
 }
 
 // UpdateStudent handles PUT /api/students/:studentNumber
+// PUT replaces the whole student: every required field must be present and
+// an omitted grade is cleared. Use PATCH for partial updates.
+// An If-Match header makes the update conditional on the student's ETag.
 // Source: "我將該學生的電子郵件更新" (第 24-28 行)
 //
 // When: 我將該學生的電子郵件更新為「wang.new@school.edu」
 // Then: 系統應該成功更新學生記錄
 func (h *Handler) UpdateStudent(c *gin.Context) {
+	ctx := c.Request.Context()
 	studentNumber := c.Param("studentNumber")
 
-	var req student.UpdateStudentRequest
+	var req student.ReplaceStudentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: "Invalid request format",
-			Code:  "INVALID_REQUEST",
-		})
+		h.handleInvalidRequest(c)
 		return
 	}
 
-	s, err := h.useCase.UpdateStudent(c.Request.Context(), studentNumber, &req)
+	version, err := expectedVersion(c, func() (*student.Student, error) {
+		return h.useCase.GetStudent(ctx, studentNumber)
+	})
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
+	req.ExpectedVersion = version
 
+	s, err := h.useCase.ReplaceStudent(ctx, studentNumber, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	setETag(c, s)
+	c.JSON(http.StatusOK, s)
+}
+
+// UpdateStudentByID handles PUT /api/students/by-id/:id
+func (h *Handler) UpdateStudentByID(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	var req student.ReplaceStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleInvalidRequest(c)
+		return
+	}
+
+	version, err := expectedVersion(c, func() (*student.Student, error) {
+		return h.useCase.GetStudentByID(ctx, id)
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	req.ExpectedVersion = version
+
+	s, err := h.useCase.ReplaceStudentByID(ctx, id, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	setETag(c, s)
 	c.JSON(http.StatusOK, s)
 }
 
 // DeleteStudent handles DELETE /api/students/:studentNumber
+// The student is moved to the trash and can be restored until purged.
+// An If-Match header makes the delete conditional on the student's ETag.
 // Source: "我請求刪除該學生記錄" (第 30-34 行)
 //
 // When: 我請求刪除該學生記錄
 // Then: 系統應該成功刪除該學生
 func (h *Handler) DeleteStudent(c *gin.Context) {
+	ctx := c.Request.Context()
 	studentNumber := c.Param("studentNumber")
 
-	err := h.useCase.DeleteStudent(c.Request.Context(), studentNumber)
+	version, err := expectedVersion(c, func() (*student.Student, error) {
+		return h.useCase.GetStudent(ctx, studentNumber)
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	if err := h.useCase.DeleteStudent(ctx, studentNumber, version); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteStudentByID handles DELETE /api/students/by-id/:id
+func (h *Handler) DeleteStudentByID(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	version, err := expectedVersion(c, func() (*student.Student, error) {
+		return h.useCase.GetStudentByID(ctx, id)
+	})
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
+	if err := h.useCase.DeleteStudentByID(ctx, id, version); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
-// handleError maps domain errors to HTTP responses.
-func (h *Handler) handleError(c *gin.Context, err error) {
-	var studentErr *student.StudentError
-	if errors.As(err, &studentErr) {
-		switch studentErr.Type {
-		case student.ErrorTypeMissingRequiredField:
-			// Source: "姓名為必填欄位" (第 39 行)
-			c.JSON(http.StatusBadRequest, ErrorResponse{
-				Error: studentErr.Message,
-				Code:  string(studentErr.Type),
-			})
-		case student.ErrorTypeInvalidEmail:
-			// Source: "無效的電子郵件格式" (第 51 行)
-			c.JSON(http.StatusBadRequest, ErrorResponse{
-				Error: studentErr.Message,
-				Code:  string(studentErr.Type),
-			})
-		case student.ErrorTypeInvalidGrade:
-			// Source: "年級必須在 1-6 之間" (第 82 行)
-			c.JSON(http.StatusBadRequest, ErrorResponse{
-				Error: studentErr.Message,
-				Code:  string(studentErr.Type),
-			})
-		case student.ErrorTypeStudentNumberAlreadyExists:
-			// Source: "學號已存在" (第 45 行)
-			c.JSON(http.StatusConflict, ErrorResponse{
-				Error: studentErr.Message,
-				Code:  string(studentErr.Type),
-			})
-		case student.ErrorTypeStudentNotFound:
-			// Source: "學生不存在" (第 57 行)
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Error: studentErr.Message,
-				Code:  string(studentErr.Type),
-			})
-		default:
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error: "Internal server error",
-				Code:  "INTERNAL_ERROR",
-			})
+// GetDeletedStudents handles GET /api/students/trash
+// Lists trashed students with the same query parameters and paging as
+// GET /api/students.
+func (h *Handler) GetDeletedStudents(c *gin.Context) {
+	q, err := parseListQuery(c)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	page, err := h.useCase.ListDeletedStudents(c.Request.Context(), q)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	if page.NextPageToken != "" {
+		c.Header(NextPageTokenHeader, page.NextPageToken)
+	}
+	c.JSON(http.StatusOK, page.Students)
+}
+
+// GetStudentChanges handles GET /api/students/changes
+// Returns the students changed after the ?since= cursor (default 0) in
+// change order, at most ?limit= (default and maximum 1000): upserts carry
+// the current student, deletions are tombstones. Passing next_since back
+// as since reads the following changes; has_more tells whether to do so
+// right away.
+func (h *Handler) GetStudentChanges(c *gin.Context) {
+	var since int64
+	if v := c.Query("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			h.handleError(c, student.NewInvalidQueryParameterError("since", v))
+			return
+		}
+		since = n
+	}
+
+	var limit int
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			h.handleError(c, student.NewInvalidQueryParameterError("limit", v))
+			return
 		}
+		limit = n
+	}
+
+	feed, err := h.useCase.GetChanges(c.Request.Context(), since, limit)
+	if err != nil {
+		h.handleError(c, err)
 		return
 	}
 
-	// Unknown error
-	c.JSON(http.StatusInternalServerError, ErrorResponse{
-		Error: "Internal server error",
-		Code:  "INTERNAL_ERROR",
+	c.JSON(http.StatusOK, feed)
+}
+
+// RestoreStudent handles POST /api/students/:studentNumber/restore
+// Restores the most recently trashed student with the student number.
+// An If-Match header makes the restore conditional on the trashed
+// student's ETag.
+func (h *Handler) RestoreStudent(c *gin.Context) {
+	ctx := c.Request.Context()
+	studentNumber := c.Param("studentNumber")
+
+	version, err := expectedVersion(c, func() (*student.Student, error) {
+		return h.useCase.GetDeletedStudent(ctx, studentNumber)
 	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	s, err := h.useCase.RestoreStudent(ctx, studentNumber, version)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	setETag(c, s)
+	c.JSON(http.StatusOK, s)
+}
+
+// RevertRequest is the body of POST /api/students/:studentNumber/revert.
+type RevertRequest struct {
+	Version int64 `json:"version"`
+}
+
+// RevertStudent handles POST /api/students/:studentNumber/revert
+// Sets the student's fields back to those of the given earlier version,
+// validated like a PUT. An If-Match header makes the revert conditional
+// on the student's ETag.
+func (h *Handler) RevertStudent(c *gin.Context) {
+	ctx := c.Request.Context()
+	studentNumber := c.Param("studentNumber")
+
+	var req RevertRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Version < student.InitialVersion {
+		h.handleInvalidRequest(c)
+		return
+	}
+
+	version, err := expectedVersion(c, func() (*student.Student, error) {
+		return h.useCase.GetStudent(ctx, studentNumber)
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	s, err := h.useCase.RevertStudent(ctx, studentNumber, req.Version, version)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	setETag(c, s)
+	c.JSON(http.StatusOK, s)
+}
+
+// parseListQuery reads the listing parameters of GET /api/students.
+func parseListQuery(c *gin.Context) (*student.ListQuery, error) {
+	q := &student.ListQuery{
+		Class:     c.Query("class"),
+		Search:    c.Query("q"),
+		PageToken: c.Query("page_token"),
+	}
+
+	if v := c.Query("grade"); v != "" {
+		grade, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, student.NewInvalidQueryParameterError("grade", v)
+		}
+		q.Grade = &grade
+	}
+
+	if v := c.Query("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			return nil, student.NewInvalidQueryParameterError("page_size", v)
+		}
+		q.PageSize = size
+	}
+
+	sort, err := student.ParseSort(c.Query("sort"))
+	if err != nil {
+		return nil, err
+	}
+	q.Sort = sort
+
+	return q, nil
 }
 
 // RegisterRoutes registers all student routes to the router.
 func RegisterRoutes(router *gin.Engine, handler *Handler) {
-	group := router.Group("/api/students")
+	api := router.Group("/api", handler.requestContext)
+	group := api.Group("/students")
 	{
 		group.POST("", handler.CreateStudent)
 		group.GET("", handler.GetAllStudents)
+		group.POST("/import", handler.ImportStudents)
+		group.GET("/export", handler.ExportStudents)
+		group.GET("/trash", handler.GetDeletedStudents)
+		group.GET("/changes", handler.GetStudentChanges)
+		group.POST("/:studentNumber/restore", handler.RestoreStudent)
+		group.GET("/:studentNumber/history", handler.GetStudentHistory)
+		group.POST("/:studentNumber/revert", handler.RevertStudent)
 		group.GET("/:studentNumber", handler.GetStudent)
 		group.PUT("/:studentNumber", handler.UpdateStudent)
+		group.PATCH("/:studentNumber", handler.PatchStudent)
 		group.DELETE("/:studentNumber", handler.DeleteStudent)
+
+		group.GET("/by-id/:id", handler.GetStudentByID)
+		group.PUT("/by-id/:id", handler.UpdateStudentByID)
+		group.PATCH("/by-id/:id", handler.PatchStudentByID)
+		group.DELETE("/by-id/:id", handler.DeleteStudentByID)
 	}
+
+	// Custom methods such as POST /api/students:batch.
+	api.POST("/:customMethod", handler.customMethod)
 }
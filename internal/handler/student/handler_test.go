@@ -1,26 +1,51 @@
 package handler
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/traditionalchinese"
 
 	"todo/internal/domain/student"
+	studentevent "todo/internal/event/student"
 	studentrepo "todo/internal/repository/student"
+	"todo/internal/repository/student/repositorytest"
 	studentusecase "todo/internal/usecase/student"
 )
 
-func setupTestHandler() *Handler {
+func setupTestHandler(opts ...Option) *Handler {
 	gin.SetMode(gin.TestMode)
 	repo := studentrepo.NewMemoryRepository()
 	uc := studentusecase.NewUseCase(repo)
-	return NewHandler(uc)
+	return NewHandler(uc, opts...)
+}
+
+// tokenAuthenticator accepts the bearer tokens it maps to principals.
+type tokenAuthenticator map[string]*student.Principal
+
+func (a tokenAuthenticator) Authenticate(_ context.Context, token string) (*student.Principal, error) {
+	if p, ok := a[token]; ok {
+		return p, nil
+	}
+	return nil, errors.New("unknown token")
 }
 
 func TestCreateStudent_Success(t *testing.T) {
@@ -161,8 +186,8 @@ func TestUpdateStudent_Success(t *testing.T) {
 		Email: strPtr("wang.new@school.edu"),
 	}
 	updateBody, _ := json.Marshal(updatePayload)
-	updateReq, _ := http.NewRequest("PUT", "/api/students/2024001", bytes.NewBuffer(updateBody))
-	updateReq.Header.Set("Content-Type", "application/json")
+	updateReq, _ := http.NewRequest("PATCH", "/api/students/2024001", bytes.NewBuffer(updateBody))
+	updateReq.Header.Set("Content-Type", "application/merge-patch+json")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, updateReq)
 
@@ -362,7 +387,1500 @@ func TestCreateStudent_InvalidGrade(t *testing.T) {
 	assert.Equal(t, student.ErrorTypeInvalidGrade, student.ErrorType(errorResp.Code))
 }
 
-// Helper function for pointer to string
-func strPtr(s string) *string {
-	return &s
+func TestStudentByID_SurvivesStudentNumberChange(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 先建立一個學生並取得其 ID
+	createPayload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+	}
+	body, _ := json.Marshal(createPayload)
+	createReq, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// When: 透過 ID 將學號更新為「2024100」
+	updateBody, _ := json.Marshal(student.UpdateStudentRequest{StudentNumber: strPtr("2024100")})
+	updateReq, _ := http.NewRequest("PATCH", "/api/students/by-id/"+created.ID, bytes.NewBuffer(updateBody))
+	updateReq.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, updateReq)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Then: 使用相同 ID 查詢應該返回新的學號
+	getReq, _ := http.NewRequest("GET", "/api/students/by-id/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, getReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, "2024100", result.StudentNumber)
+
+	// And: 透過 ID 刪除後再查詢應該返回 404
+	deleteReq, _ := http.NewRequest("DELETE", "/api/students/by-id/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, deleteReq)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	getReq, _ = http.NewRequest("GET", "/api/students/by-id/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, getReq)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetAllStudents_PaginationAndSort(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 建立 5 筆學生記錄
+	for i := 1; i <= 5; i++ {
+		payload := student.CreateStudentRequest{
+			StudentNumber: "202400" + string(rune(i+'0')),
+			Name:          "学生" + string(rune(i+'0')),
+			Email:         "student" + string(rune(i+'0')) + "@school.edu",
+			Class:         "一年一班",
+		}
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	// When: 我以每頁 2 筆、學號遞減的方式逐頁查詢
+	var numbers []string
+	url := "/api/students?page_size=2&sort=-student_number"
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5)
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var results []*student.Student
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+		for _, s := range results {
+			numbers = append(numbers, s.StudentNumber)
+		}
+
+		token := w.Header().Get(NextPageTokenHeader)
+		if token == "" {
+			break
+		}
+		url = "/api/students?page_size=2&sort=-student_number&page_token=" + token
+	}
+
+	// Then: 系統應該依序返回所有學生且不重複
+	assert.Equal(t, []string{"2024005", "2024004", "2024003", "2024002", "2024001"}, numbers)
+}
+
+func TestGetAllStudents_InvalidSort(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	req, _ := http.NewRequest("GET", "/api/students?sort=password", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errorResp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &errorResp)
+	assert.Equal(t, student.ErrorTypeInvalidQueryParameter, student.ErrorType(errorResp.Code))
+}
+
+func TestCreateStudent_ReportsAllValidationErrors(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// When: 我提交缺少姓名、電子郵件格式無效且年級超出範圍的學生資訊
+	grade := 10
+	payload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Email:         "invalid-email",
+		Class:         "一年一班",
+		Grade:         &grade,
+	}
+
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 系統應該一次返回所有欄位錯誤
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errorResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+	assert.Equal(t, []FieldError{
+		{Field: "name", Code: string(student.ErrorTypeMissingRequiredField), Message: "姓名為必填欄位"},
+		{Field: "email", Code: string(student.ErrorTypeInvalidEmail), Message: "無效的電子郵件格式"},
+		{Field: "grade", Code: string(student.ErrorTypeInvalidGrade), Message: "年級必須在 1-6 之間"},
+	}, errorResp.Errors)
+}
+
+func TestGetStudent_NotFoundProblemDetails(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// When: 客戶端要求 problem+json 格式並查詢不存在的學生
+	req, _ := http.NewRequest("GET", "/api/students/9999999", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 系統應該以 RFC 9457 格式返回錯誤
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem ProblemDetails
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, ProblemTypeBase+"student-not-found", problem.Type)
+	assert.Equal(t, "學生不存在", problem.Title)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "/api/students/9999999", problem.Instance)
+	assert.Equal(t, string(student.ErrorTypeStudentNotFound), problem.Code)
+}
+
+func TestCreateStudent_ValidationProblemDetails(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// When: 客戶端要求 problem+json 格式並提交缺少姓名與班級的學生資訊
+	payload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Email:         "wang@school.edu",
+	}
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/problem+json, application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 系統應該返回單一驗證問題並列出所有欄位錯誤
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem ProblemDetails
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, ProblemTypeBase+"validation-failed", problem.Type)
+	require.Len(t, problem.Errors, 2)
+	assert.Equal(t, "name", problem.Errors[0].Field)
+	assert.Equal(t, "class", problem.Errors[1].Field)
+}
+
+func TestGetStudent_NotFoundLocalized(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	cases := []struct {
+		acceptLanguage string
+		wantLanguage   string
+		wantMessage    string
+	}{
+		{"", "zh-TW", "學生不存在"},
+		{"en-US,en;q=0.9", "en", "student not found"},
+		{"ja", "ja", "学生が見つかりません"},
+		{"fr-FR", "zh-TW", "學生不存在"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.acceptLanguage, func(t *testing.T) {
+			// When: 以不同的 Accept-Language 查詢不存在的學生
+			req, _ := http.NewRequest("GET", "/api/students/9999999", nil)
+			if tc.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tc.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Then: 錯誤訊息應該依語言切換，錯誤代碼保持不變
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, tc.wantLanguage, w.Header().Get("Content-Language"))
+
+			var errorResp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+			assert.Equal(t, tc.wantMessage, errorResp.Error)
+			assert.Equal(t, string(student.ErrorTypeStudentNotFound), errorResp.Code)
+		})
+	}
+}
+
+func TestCreateStudent_ValidationErrorsLocalized(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	grade := 9
+	payload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+		Grade:         &grade,
+	}
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "en")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errorResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+	require.Len(t, errorResp.Errors, 2)
+	assert.Equal(t, "name is required", errorResp.Errors[0].Message)
+	assert.Equal(t, "grade must be between 1 and 6", errorResp.Errors[1].Message)
+}
+
+func TestUpdateStudent_IfMatch(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 先建立一個學生，回應應帶有 ETag
+	createPayload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+	}
+	body, _ := json.Marshal(createPayload)
+	createReq, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	update := func(ifMatch, email string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(student.UpdateStudentRequest{Email: strPtr(email)})
+		req, _ := http.NewRequest("PATCH", "/api/students/2024001", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// When: 第一位管理員以目前的 ETag 更新
+	w = update(etag, "first@school.edu")
+
+	// Then: 更新成功且 ETag 改變
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	// When: 第二位管理員以舊的 ETag 更新
+	w = update(etag, "second@school.edu")
+
+	// Then: 系統應該返回 412 且不覆蓋第一位的修改
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	var errorResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+	assert.Equal(t, string(student.ErrorTypeVersionConflict), errorResp.Code)
+
+	getReq, _ := http.NewRequest("GET", "/api/students/2024001", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, getReq)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	var result student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, "first@school.edu", result.Email)
+
+	// And: 以舊的 ETag 刪除應該被拒絕，以目前的 ETag 刪除則成功
+	deleteReq, _ := http.NewRequest("DELETE", "/api/students/2024001", nil)
+	deleteReq.Header.Set("If-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, deleteReq)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	deleteReq, _ = http.NewRequest("DELETE", "/api/students/2024001", nil)
+	deleteReq.Header.Set("If-Match", `"1", "2"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, deleteReq)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestPatchStudent_NullClearsGrade(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 先建立一個有年級的學生
+	grade := 1
+	createPayload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+		Grade:         &grade,
+	}
+	body, _ := json.Marshal(createPayload)
+	createReq, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// When: 以 merge patch 將年級設為 null 並更新班級
+	patchReq, _ := http.NewRequest("PATCH", "/api/students/2024001",
+		bytes.NewBufferString(`{"grade": null, "class": "一年二班"}`))
+	patchReq.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patchReq)
+
+	// Then: 年級應該被清除，其他欄位保持不變
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var result student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Nil(t, result.Grade)
+	assert.Equal(t, "一年二班", result.Class)
+	assert.Equal(t, "王小明", result.Name)
+
+	// And: 將必填欄位設為 null 應該被拒絕
+	patchReq, _ = http.NewRequest("PATCH", "/api/students/2024001", bytes.NewBufferString(`{"name": null}`))
+	patchReq.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patchReq)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// And: 不支援的媒體類型應該返回 415
+	patchReq, _ = http.NewRequest("PATCH", "/api/students/2024001", bytes.NewBufferString(`name=x`))
+	patchReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patchReq)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Header().Get("Accept-Patch"), "application/merge-patch+json")
+	assert.Contains(t, w.Header().Get("Accept-Patch"), "application/json-patch+json")
+}
+
+func TestReplaceStudent_FullReplacement(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 先建立一個有年級的學生
+	grade := 1
+	createPayload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+		Grade:         &grade,
+	}
+	body, _ := json.Marshal(createPayload)
+	createReq, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// When: 以 PUT 只提交電子郵件
+	putReq, _ := http.NewRequest("PUT", "/api/students/2024001",
+		bytes.NewBufferString(`{"email": "wang.new@school.edu"}`))
+	putReq.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, putReq)
+
+	// Then: 系統應該要求提供所有必填欄位
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errorResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+	assert.Len(t, errorResp.Errors, 3)
+
+	// When: 以 PUT 提交完整資料但省略年級
+	putReq, _ = http.NewRequest("PUT", "/api/students/2024001", bytes.NewBufferString(
+		`{"student_number": "2024001", "name": "王小明", "email": "wang.new@school.edu", "class": "一年二班"}`))
+	putReq.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, putReq)
+
+	// Then: 記錄應該被完整取代，年級被清除
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var result student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, "wang.new@school.edu", result.Email)
+	assert.Equal(t, "一年二班", result.Class)
+	assert.Nil(t, result.Grade)
+}
+
+func TestPatchStudent_JSONPatch(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 先建立一個學生
+	createPayload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+	}
+	body, _ := json.Marshal(createPayload)
+	createReq, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	patch := func(ops string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "/api/students/2024001", bytes.NewBufferString(ops))
+		req.Header.Set("Content-Type", "application/json-patch+json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// When: test 不成立時，整份修補都不應該套用
+	w = patch(`[
+		{"op": "replace", "path": "/email", "value": "wang.new@school.edu"},
+		{"op": "test", "path": "/class", "value": "一年二班"}
+	]`)
+
+	// Then: 系統應該返回 409 且記錄保持不變
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var errorResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+	assert.Equal(t, codePatchTestFailed, errorResp.Code)
+
+	getReq, _ := http.NewRequest("GET", "/api/students/2024001", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, getReq)
+	var unchanged student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &unchanged))
+	assert.Equal(t, "wang@school.edu", unchanged.Email)
+
+	// When: test 成立時套用所有操作
+	w = patch(`[
+		{"op": "test", "path": "/class", "value": "一年一班"},
+		{"op": "replace", "path": "/email", "value": "wang.new@school.edu"},
+		{"op": "add", "path": "/grade", "value": 2}
+	]`)
+
+	// Then: 記錄應該被更新
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var result student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, "wang.new@school.edu", result.Email)
+	require.NotNil(t, result.Grade)
+	assert.Equal(t, 2, *result.Grade)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	// And: 修補後的結果一樣要通過驗證
+	w = patch(`[{"op": "replace", "path": "/grade", "value": 9}, {"op": "remove", "path": "/name"}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+	assert.Len(t, errorResp.Errors, 2)
+
+	// And: 唯讀欄位、不存在的路徑與未知操作都是無效的請求
+	for _, ops := range []string{
+		`[{"op": "replace", "path": "/version", "value": 7}]`,
+		`[{"op": "remove", "path": "/nickname"}]`,
+		`[{"op": "increment", "path": "/grade"}]`,
+		`{"op": "replace", "path": "/email", "value": "x@school.edu"}`,
+	} {
+		w = patch(ops)
+		assert.Equal(t, http.StatusBadRequest, w.Code, ops)
+	}
+}
+
+func TestImportStudents_CSV(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	csvBody := "學號,姓名,電子郵件,班級,年級\n" +
+		"2024001,王小明,wang@school.edu,一年一班,1\n" +
+		"2024002,李小華,invalid-email,一年一班,9\n" +
+		"2024003,陳大文,chen@school.edu,一年二班,\n"
+
+	importCSV := func(query string, body []byte, contentType string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/students/import"+query, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// When: 以試算模式匯入 UTF-8 CSV
+	w := importCSV("?dry_run=true", []byte(csvBody), "text/csv")
+
+	// Then: 系統應該回報每一列的結果，但不建立任何學生
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Rows, 3)
+	assert.Equal(t, "valid", report.Rows[0].Status)
+	assert.Equal(t, 3, report.Rows[1].Line)
+	assert.Equal(t, "failed", report.Rows[1].Status)
+	assert.Len(t, report.Rows[1].Errors, 2)
+
+	getReq, _ := http.NewRequest("GET", "/api/students/2024001", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, getReq)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// When: 正式匯入 Big5 編碼的 CSV
+	big5, err := traditionalchinese.Big5.NewEncoder().Bytes([]byte(csvBody))
+	require.NoError(t, err)
+	w = importCSV("", big5, "text/csv")
+
+	// Then: 有效的列應該被建立，姓名正確解碼
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.False(t, report.DryRun)
+	assert.Equal(t, "created", report.Rows[0].Status)
+	require.NotNil(t, report.Rows[0].Student)
+	assert.Equal(t, "王小明", report.Rows[0].Student.Name)
+
+	getReq, _ = http.NewRequest("GET", "/api/students/2024003", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, getReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// And: 缺少必要欄位的標題應該被拒絕
+	w = importCSV("", []byte("學號,姓名,班級\n2024009,張三,一年一班\n"), "text/csv")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errorResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+	assert.Equal(t, codeInvalidCSV, errorResp.Code)
+
+	// And: 超過大小上限的檔案應該以 413 拒絕
+	oversized := bytes.Repeat([]byte("2024009,張三,chang@school.edu,一年一班,1\n"), maxImportBytes/40+1)
+	w = importCSV("", oversized, "text/csv")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResp))
+	assert.Equal(t, codePayloadTooLarge, errorResp.Code)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, err := mw.CreateFormFile(importFormField, "students.csv")
+	require.NoError(t, err)
+	_, err = part.Write(oversized)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	w = importCSV("", form.Bytes(), mw.FormDataContentType())
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestExportStudents_Formats(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 系統中已存在三位學生，分屬兩個班級
+	grade := 1
+	for _, payload := range []student.CreateStudentRequest{
+		{StudentNumber: "2024001", Name: "王小明", Email: "wang@school.edu", Class: "一年一班", Grade: &grade},
+		{StudentNumber: "2024002", Name: "李小華", Email: "lee@school.edu", Class: "一年二班"},
+		{StudentNumber: "2024003", Name: "陳大文", Email: "chen@school.edu", Class: "一年一班"},
+	} {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	export := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/students/export"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// When: 匯出一年一班的 CSV
+	w := export("?class=一年一班")
+
+	// Then: 回應應該是帶有 BOM 的 CSV 附件，只包含該班學生
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+
+	body := w.Body.String()
+	require.True(t, strings.HasPrefix(body, "\ufeff"))
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(body, "\ufeff"))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"學號", "姓名", "電子郵件", "班級", "年級"}, records[0][:5])
+	assert.Equal(t, []string{"2024001", "王小明", "wang@school.edu", "一年一班", "1"}, records[1][:5])
+	assert.Equal(t, "", records[2][4])
+
+	// When: 以 NDJSON 依學號遞減匯出
+	w = export("?format=ndjson&sort=-student_number")
+
+	// Then: 每一行是一位學生
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	var first student.Student
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "2024003", first.StudentNumber)
+
+	// When: 匯出 XLSX
+	w = export("?format=xlsx")
+
+	// Then: 回應應該是有效的活頁簿，且中文內容正確
+	require.Equal(t, http.StatusOK, w.Code)
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			sheet = string(data)
+		}
+	}
+	assert.Contains(t, sheet, "王小明")
+	assert.Contains(t, sheet, "<v>1</v>")
+	assert.Equal(t, 4, strings.Count(sheet, "<row "))
+
+	// And: 不支援的格式應該被拒絕
+	w = export("?format=pdf")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportStudents_NeutralisesFormulas(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 學生姓名與班級以試算表公式字元開頭
+	formula := `=HYPERLINK("https://evil.example.com","點我")`
+	body, _ := json.Marshal(student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          formula,
+		Email:         "wang@school.edu",
+		Class:         "+一年一班",
+	})
+	req, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// When: 匯出 CSV
+	req, _ = http.NewRequest("GET", "/api/students/export", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 這些儲存格以單引號開頭，試算表會當成文字顯示
+	require.Equal(t, http.StatusOK, w.Code)
+	exported := w.Body.Bytes()
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(exported), "\ufeff"))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "'"+formula, records[1][1])
+	assert.Equal(t, "'+一年一班", records[1][3])
+
+	// And: 匯出的檔案重新匯入後還原原本的值
+	other := gin.New()
+	RegisterRoutes(other, setupTestHandler())
+	req, _ = http.NewRequest("POST", "/api/students/import", bytes.NewReader(exported))
+	req.Header.Set("Content-Type", "text/csv")
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Rows, 1)
+	require.NotNil(t, report.Rows[0].Student)
+	assert.Equal(t, formula, report.Rows[0].Student.Name)
+	assert.Equal(t, "+一年一班", report.Rows[0].Student.Class)
+
+	// When: 匯出 XLSX
+	req, _ = http.NewRequest("GET", "/api/students/export?format=xlsx", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 儲存格是內嵌文字而非公式
+	require.Equal(t, http.StatusOK, w.Code)
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	rc, err := zr.Open("xl/worksheets/sheet1.xml")
+	require.NoError(t, err)
+	sheet, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sheet), "<f>")
+	assert.Contains(t, string(sheet), `<c t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(`)
+}
+
+// slowListRepository makes every listing after the first take delay, like
+// a large roster read from a busy database.
+type slowListRepository struct {
+	studentrepo.Repository
+	delay time.Duration
+	calls int
+}
+
+func (r *slowListRepository) List(ctx context.Context, q *student.ListQuery) (*student.StudentPage, error) {
+	if r.calls++; r.calls > 1 {
+		time.Sleep(r.delay)
+	}
+	return r.Repository.List(ctx, q)
+}
+
+func TestExportStudents_OutlivesWriteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Given: 讀取名冊的時間超過伺服器寫入逾時；匯出每次讀取 500 位學生
+	const rosterSize = 1500
+	repo := &slowListRepository{Repository: studentrepo.NewMemoryRepository(), delay: 200 * time.Millisecond}
+	for i := range rosterSize {
+		require.NoError(t, repo.Save(context.Background(), repositorytest.NewStudent(strconv.Itoa(2024000+i))))
+	}
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(studentusecase.NewUseCase(repo)))
+	srv := httptest.NewUnstartedServer(router)
+	srv.Config.WriteTimeout = 300 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	// When: 匯出名冊
+	resp, err := http.Get(srv.URL + "/api/students/export")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+
+	// Then: 完整收到每一位學生
+	require.NoError(t, err)
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, records, 1+rosterSize)
+}
+
+func TestBatchStudents(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	batch := func(query, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/students:batch"+query, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Given: 一批包含無效資料的操作
+	body := `{"operations": [
+		{"op": "create", "student": {"student_number": "2024001", "name": "王小明", "email": "wang@school.edu", "class": "一年一班"}},
+		{"op": "update", "student_number": "2024001", "student": {"grade": 2}},
+		{"op": "create", "student": {"student_number": "2024002", "name": "李小華", "email": "invalid", "class": "一年一班"}}
+	]}`
+
+	// When: 以原子模式送出
+	w := batch("?atomic=true", body)
+
+	// Then: 整批應該被復原，並回報失敗的操作
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp BatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Atomic)
+	assert.False(t, resp.Committed)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, "rolled_back", resp.Results[0].Status)
+	assert.Equal(t, "rolled_back", resp.Results[1].Status)
+	assert.Equal(t, "failed", resp.Results[2].Status)
+	require.Len(t, resp.Results[2].Errors, 1)
+	assert.Equal(t, "email", resp.Results[2].Errors[0].Field)
+
+	getReq, _ := http.NewRequest("GET", "/api/students/2024001", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, getReq)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// When: 不使用原子模式送出
+	w = batch("", body)
+
+	// Then: 成功的操作應該生效
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Committed)
+	assert.Equal(t, 2, resp.Succeeded)
+	assert.Equal(t, 1, resp.Failed)
+	require.NotNil(t, resp.Results[1].Student)
+	require.NotNil(t, resp.Results[1].Student.Grade)
+	assert.Equal(t, 2, *resp.Results[1].Student.Grade)
+
+	// And: 格式錯誤的操作應該在執行前拒絕整批
+	w = batch("", `{"operations": [{"op": "upsert", "student_number": "2024001"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// And: 未知的自訂方法應該返回 404
+	req, _ := http.NewRequest("POST", "/api/students:merge", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteStudent_TrashAndRestore(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 先建立一個學生並刪除
+	createPayload := student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+	}
+	body, _ := json.Marshal(createPayload)
+	createReq, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	deleteReq, _ := http.NewRequest("DELETE", "/api/students/2024001", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, deleteReq)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	// When: 我查詢垃圾桶
+	trashReq, _ := http.NewRequest("GET", "/api/students/trash", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, trashReq)
+
+	// Then: 被刪除的學生應該出現在垃圾桶中
+	require.Equal(t, http.StatusOK, w.Code)
+	var trash []student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trash))
+	require.Len(t, trash, 1)
+	assert.Equal(t, "2024001", trash[0].StudentNumber)
+	assert.NotNil(t, trash[0].DeletedAt)
+
+	// When: 以過期的版本還原
+	restoreReq, _ := http.NewRequest("POST", "/api/students/2024001/restore", nil)
+	restoreReq.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, restoreReq)
+
+	// Then: 系統應該拒絕還原
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// When: 以目前的版本還原
+	restoreReq, _ = http.NewRequest("POST", "/api/students/2024001/restore", nil)
+	restoreReq.Header.Set("If-Match", formatETag(trash[0].Version))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, restoreReq)
+
+	// Then: 學生應該恢復
+	require.Equal(t, http.StatusOK, w.Code)
+	getReq, _ := http.NewRequest("GET", "/api/students/2024001", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, getReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// And: 不在垃圾桶中的學生無法還原
+	restoreReq, _ = http.NewRequest("POST", "/api/students/2024001/restore", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, restoreReq)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetStudentHistory(t *testing.T) {
+	handler := setupTestHandler(WithAuthenticator(tokenAuthenticator{
+		"lin-token": {Subject: "teacher.lin"},
+	}))
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 管理員新增一個學生後修改其班級
+	body, _ := json.Marshal(student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+	})
+	createReq, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Authorization", "Bearer lin-token")
+	createReq.Header.Set(RequestIDHeader, "req-create")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "req-create", w.Header().Get(RequestIDHeader))
+
+	patchReq, _ := http.NewRequest("PATCH", "/api/students/2024001", strings.NewReader(`{"class": "一年二班"}`))
+	patchReq.Header.Set("Content-Type", "application/merge-patch+json")
+	patchReq.Header.Set("Authorization", "Bearer lin-token")
+	patchReq.Header.Set(ActorHeader, "someone.else")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patchReq)
+	require.Equal(t, http.StatusOK, w.Code)
+	requestID := w.Header().Get(RequestIDHeader)
+	require.NotEmpty(t, requestID)
+
+	// When: 我查詢該學生的變更歷史
+	req, _ := http.NewRequest("GET", "/api/students/2024001/history", nil)
+	req.Header.Set("Authorization", "Bearer lin-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 系統應該依時間順序返回每一次變更
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []student.AuditEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history, 2)
+	assert.Equal(t, student.AuditCreated, history[0].Action)
+	assert.Equal(t, "req-create", history[0].RequestID)
+	assert.Equal(t, "teacher.lin", history[0].Actor)
+	assert.Equal(t, student.AuditUpdated, history[1].Action)
+	assert.Equal(t, "teacher.lin", history[1].Actor, "X-Actor is ignored when authenticated")
+	assert.Equal(t, requestID, history[1].RequestID)
+	require.Len(t, history[1].Changes, 1)
+	assert.Equal(t, "class", history[1].Changes[0].Field)
+	assert.JSONEq(t, `"一年一班"`, string(history[1].Changes[0].Before))
+	assert.JSONEq(t, `"一年二班"`, string(history[1].Changes[0].After))
+
+	// And: 不存在的學生應該返回 404
+	req, _ = http.NewRequest("GET", "/api/students/9999999/history", nil)
+	req.Header.Set("Authorization", "Bearer lin-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetStudentHistory_ActorHeaderWithoutAuthentication(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 未啟用身分驗證時，一個帶 X-Actor 與一個未帶的變更
+	body := `{"student_number": "2024001", "name": "王小明", "email": "wang@school.edu", "class": "一年一班"}`
+	createReq, _ := http.NewRequest("POST", "/api/students", strings.NewReader(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set(ActorHeader, "teacher.lin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	patchReq, _ := http.NewRequest("PATCH", "/api/students/2024001", strings.NewReader(`{"class": "一年二班"}`))
+	patchReq.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patchReq)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// When: 我查詢該學生的變更歷史
+	req, _ := http.NewRequest("GET", "/api/students/2024001/history", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 操作者取自 X-Actor，未提供時為 anonymous
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []student.AuditEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history, 2)
+	assert.Equal(t, "teacher.lin", history[0].Actor)
+	assert.Equal(t, studentusecase.AnonymousActor, history[1].Actor)
+}
+
+func TestGetStudentAsOfAndRevert(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 學生建立後被調到另一個班級
+	body, _ := json.Marshal(student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+	})
+	createReq, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createReq)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	beforeMove := time.Now().UTC()
+	time.Sleep(time.Millisecond)
+
+	patchReq, _ := http.NewRequest("PATCH", "/api/students/2024001", strings.NewReader(`{"class": "一年二班"}`))
+	patchReq.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, patchReq)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// When: 我查詢調班前的時間點
+	req, _ := http.NewRequest("GET", "/api/students/2024001?as_of="+url.QueryEscape(beforeMove.Format(time.RFC3339Nano)), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 系統應該返回當時的班級
+	require.Equal(t, http.StatusOK, w.Code)
+	var then student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &then))
+	assert.Equal(t, "一年一班", then.Class)
+	assert.Equal(t, int64(1), then.Version)
+	assert.Empty(t, w.Header().Get("ETag"))
+
+	// And: 建立之前的時間點查無此學生，無效的時間格式返回 400
+	req, _ = http.NewRequest("GET", "/api/students/2024001?as_of=2000-01-01T00:00:00Z", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/students/2024001?as_of=yesterday", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// When: 我將學生還原到第一個版本
+	revertReq, _ := http.NewRequest("POST", "/api/students/2024001/revert", strings.NewReader(`{"version": 1}`))
+	revertReq.Header.Set("Content-Type", "application/json")
+	revertReq.Header.Set("If-Match", `"2"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, revertReq)
+
+	// Then: 學生應該回到原本的班級
+	require.Equal(t, http.StatusOK, w.Code)
+	var reverted student.Student
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reverted))
+	assert.Equal(t, "一年一班", reverted.Class)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+}
+
+// Helper function for pointer to string
+func TestGetStudentChanges(t *testing.T) {
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterRoutes(router, handler)
+
+	// Given: 建立兩個學生並刪除其中一個
+	for _, number := range []string{"2024001", "2024002"} {
+		body, _ := json.Marshal(student.CreateStudentRequest{
+			StudentNumber: number,
+			Name:          "王小明",
+			Email:         "s" + number + "@school.edu",
+			Class:         "一年一班",
+		})
+		req, _ := http.NewRequest("POST", "/api/students", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+	deleteReq, _ := http.NewRequest("DELETE", "/api/students/2024001", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, deleteReq)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	// When: 同步程式讀取第一筆異動
+	req, _ := http.NewRequest("GET", "/api/students/changes?limit=1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 回傳新增的學生與下一頁的游標
+	require.Equal(t, http.StatusOK, w.Code)
+	var feed student.ChangeFeed
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &feed))
+	require.Len(t, feed.Changes, 1)
+	assert.True(t, feed.HasMore)
+	assert.Equal(t, student.ChangeUpsert, feed.Changes[0].Type)
+	require.NotNil(t, feed.Changes[0].Student)
+	assert.Equal(t, "2024002", feed.Changes[0].Student.StudentNumber)
+
+	// When: 從游標繼續讀取
+	req, _ = http.NewRequest("GET", "/api/students/changes?since="+strconv.FormatInt(feed.NextSince, 10), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 取得被刪除學生的墓碑
+	require.Equal(t, http.StatusOK, w.Code)
+	feed = student.ChangeFeed{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &feed))
+	require.Len(t, feed.Changes, 1)
+	assert.False(t, feed.HasMore)
+	assert.Equal(t, student.ChangeDelete, feed.Changes[0].Type)
+	assert.Equal(t, "2024001", feed.Changes[0].StudentNumber)
+	assert.Nil(t, feed.Changes[0].Student)
+
+	// And: 不合法的查詢參數會被拒絕
+	for _, query := range []string{"since=abc", "since=-1", "limit=0", "limit=1001"} {
+		req, _ = http.NewRequest("GET", "/api/students/changes?"+query, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := studentrepo.NewMemoryRepository()
+	handler := NewHandler(studentusecase.NewUseCase(repo))
+	router := gin.New()
+	RegisterRoutes(router, handler)
+	RegisterWebhookRoutes(router, NewWebhookHandler(handler, studentusecase.NewWebhookUseCase(repo)))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// When: 管理員註冊一年一班的新增事件 webhook
+	w := do("POST", "/api/webhooks",
+		`{"url": "https://hooks.example.com/students", "event_types": ["student.created"], "classes": ["一年一班"]}`)
+
+	// Then: 系統應該返回訂閱與產生的密鑰
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created CreateWebhookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.NotNil(t, created.WebhookSubscription)
+	assert.NotEmpty(t, created.ID)
+	assert.NotEmpty(t, created.Secret)
+	assert.Equal(t, []student.EventType{student.EventStudentCreated}, created.EventTypes)
+
+	// And: 之後查詢不會再揭露密鑰
+	w = do("GET", "/api/webhooks/"+created.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Secret)
+	w = do("GET", "/api/webhooks", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Secret)
+
+	// And: 派送紀錄一開始是空的
+	w = do("GET", "/api/webhooks/"+created.ID+"/deliveries?status=dead", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	// When: 使用無效網址註冊
+	w = do("POST", "/api/webhooks", `{"url": "not a url"}`)
+
+	// Then: 系統應該返回 400 與錯誤代碼
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var errResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, string(student.ErrorTypeInvalidWebhookURL), errResp.Code)
+
+	// When: 刪除 webhook
+	w = do("DELETE", "/api/webhooks/"+created.ID, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Then: 之後查詢其派送紀錄應該返回 404
+	w = do("GET", "/api/webhooks/"+created.ID+"/deliveries", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, string(student.ErrorTypeWebhookNotFound), errResp.Code)
+
+	// And: 自訂方法路由不受影響
+	w = do("POST", "/api/students:batch", `{"operations": []}`)
+	assert.NotEqual(t, http.StatusNotFound, w.Code)
+}
+
+// sseEvent is one event read from a server-sent event stream.
+type sseEvent struct {
+	id, event, data string
+}
+
+// readSSE reads the next event from r, skipping comments.
+func readSSE(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var e sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && e.event != "":
+			return e
+		case strings.HasPrefix(line, "id:"):
+			e.id = line[len("id:"):]
+		case strings.HasPrefix(line, "event:"):
+			e.event = line[len("event:"):]
+		case strings.HasPrefix(line, "data:"):
+			e.data = line[len("data:"):]
+		}
+	}
+}
+
+func TestStreamStudents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bus := studentevent.NewSyncBus()
+	feed := studentevent.NewFeed(100, 10)
+	bus.Subscribe(feed.Handle)
+	handler := NewHandler(studentusecase.NewUseCase(studentrepo.NewMemoryRepository(),
+		studentusecase.WithEventPublisher(bus)))
+	router := gin.New()
+	RegisterRoutes(router, handler)
+	RegisterStreamRoutes(router, NewStreamHandler(handler, feed))
+	server := httptest.NewServer(router)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := func(lastEventID string) *bufio.Reader {
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/students/stream?class="+url.QueryEscape("一年一班"), nil)
+		if lastEventID != "" {
+			req.Header.Set(LastEventIDHeader, lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewReader(resp.Body)
+	}
+	do := func(method, path, body string) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Less(t, resp.StatusCode, 300)
+	}
+
+	// Given: 櫃台看板訂閱一年一班的即時名冊
+	live := stream("")
+
+	// When: 新增一年一班與一年二班的學生，再修改一年一班的學生
+	do("POST", "/api/students", `{"student_number": "2024001", "name": "王小明", "email": "wang@school.edu", "class": "一年一班"}`)
+	do("POST", "/api/students", `{"student_number": "2024002", "name": "李小華", "email": "lee@school.edu", "class": "一年二班"}`)
+	do("PATCH", "/api/students/2024001", `{"name": "王大明"}`)
+
+	// Then: 看板只收到一年一班的新增與修改事件
+	created := readSSE(t, live)
+	assert.Equal(t, "student.created", created.event)
+	assert.NotEmpty(t, created.id)
+	var envelope student.EventEnvelope
+	require.NoError(t, json.Unmarshal([]byte(created.data), &envelope))
+	assert.Equal(t, "2024001", envelope.Student.StudentNumber)
+
+	updated := readSSE(t, live)
+	assert.Equal(t, "student.updated", updated.event)
+	require.NoError(t, json.Unmarshal([]byte(updated.data), &envelope))
+	assert.Equal(t, "王大明", envelope.Student.Name)
+
+	// When: 看板斷線後帶 Last-Event-ID 重新連線
+	resumed := readSSE(t, stream(created.id))
+
+	// Then: 補送錯過的事件
+	assert.Equal(t, updated.id, resumed.id)
+
+	// When: 以已不存在的事件 ID 重新連線
+	reset := readSSE(t, stream("unknown-event"))
+
+	// Then: 通知看板重新載入名冊
+	assert.Equal(t, streamEventReset, reset.event)
+}
+
+func TestStreamStudents_AccessToken(t *testing.T) {
+	handler := setupTestHandler(WithAuthenticator(tokenAuthenticator{
+		"lin-token": {Subject: "teacher.lin"},
+	}))
+	router := gin.New()
+	RegisterRoutes(router, handler)
+	RegisterStreamRoutes(router, NewStreamHandler(handler, studentevent.NewFeed(100, 10)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	get := func(path string) *http.Response {
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// When: 瀏覽器的 EventSource 以查詢參數帶權杖訂閱
+	resp := get("/api/students/stream?access_token=lin-token")
+
+	// Then: 訂閱成功
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// When: 帶無效的權杖，或在其他路由以查詢參數帶權杖
+	for _, path := range []string{"/api/students/stream?access_token=forged-token", "/api/students?access_token=lin-token"} {
+		resp := get(path)
+
+		// Then: 系統返回 401
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, path)
+	}
+}
+
+func TestRedactAccessToken(t *testing.T) {
+	assert.Equal(t, "/api/students/stream?access_token=REDACTED&class=%E4%B8%80",
+		RedactAccessToken("/api/students/stream?class=%E4%B8%80&access_token=lin-token"))
+	assert.Equal(t, "/api/students?page_size=10", RedactAccessToken("/api/students?page_size=10"))
+	assert.Equal(t, "/api/students", RedactAccessToken("/api/students"))
+}
+
+func TestStreamStudents_EndsOnShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	feed := studentevent.NewFeed(100, 10)
+	handler := setupTestHandler()
+	router := gin.New()
+	RegisterStreamRoutes(router, NewStreamHandler(handler, feed))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: router}
+	srv.RegisterOnShutdown(feed.Close)
+	go srv.Serve(listener)
+
+	// Given: 一個開啟中的即時推送連線
+	resp, err := http.Get("http://" + listener.Addr().String() + "/api/students/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// When: 伺服器關閉
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = srv.Shutdown(ctx)
+
+	// Then: 連線被結束，伺服器不必等到逾時
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestAuthentication(t *testing.T) {
+	handler := setupTestHandler(WithAuthenticator(tokenAuthenticator{
+		"lin-token": {Subject: "teacher.lin"},
+	}))
+	router := gin.New()
+	RegisterRoutes(router, handler)
+	RegisterWebhookRoutes(router, NewWebhookHandler(handler, studentusecase.NewWebhookUseCase(studentrepo.NewMemoryRepository())))
+
+	// When: 未帶權杖查詢學生
+	req, _ := http.NewRequest("GET", "/api/students", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 系統以一般錯誤格式返回 401，並要求 Bearer 權杖
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="student-api"`, w.Header().Get("WWW-Authenticate"))
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, codeUnauthenticated, resp.Code)
+	assert.Equal(t, "未通過身分驗證", resp.Error)
+
+	// When: 以無效的權杖要求 problem details
+	req, _ = http.NewRequest("POST", "/api/students:batch", strings.NewReader(`{"operations": []}`))
+	req.Header.Set("Authorization", "Bearer forged-token")
+	req.Header.Set("Accept", mediaTypeProblemJSON)
+	req.Header.Set("Accept-Language", "en")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 系統指出權杖無效
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	var problem ProblemDetails
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, ProblemTypeBase+"unauthenticated", problem.Type)
+	assert.Equal(t, "Authentication required", problem.Title)
+
+	// And: 非 Bearer 的驗證方式與 webhook 路由同樣受保護
+	req, _ = http.NewRequest("GET", "/api/webhooks", nil)
+	req.SetBasicAuth("teacher.lin", "lin-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// When: 帶有效的權杖查詢
+	req, _ = http.NewRequest("GET", "/api/students", nil)
+	req.Header.Set("Authorization", "bearer lin-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 請求成功
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestWebhookAuthorization(t *testing.T) {
+	handler := setupTestHandler(WithAuthenticator(tokenAuthenticator{
+		"lin-token":   {Subject: "teacher.lin", Roles: []string{"teacher"}},
+		"admin-token": {Subject: "admin.chen", Roles: []string{"teacher", student.RoleAdmin}},
+	}))
+	router := gin.New()
+	RegisterRoutes(router, handler)
+	RegisterWebhookRoutes(router, NewWebhookHandler(handler, studentusecase.NewWebhookUseCase(studentrepo.NewMemoryRepository())))
+
+	// When: 非管理員查詢或訂閱 webhook
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/webhooks", nil),
+		httptest.NewRequest("POST", "/api/webhooks", strings.NewReader(`{"url": "https://lms.school.edu/hooks"}`)),
+	} {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer lin-token")
+		req.Header.Set("Accept-Language", "en")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// Then: 系統返回 403
+		require.Equal(t, http.StatusForbidden, w.Code, req.Method)
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, codeForbidden, resp.Code)
+		assert.Equal(t, "Forbidden", resp.Error)
+	}
+
+	// And: 非管理員仍可使用學生 API
+	req := httptest.NewRequest("GET", "/api/students", nil)
+	req.Header.Set("Authorization", "Bearer lin-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// When: 管理員查詢 webhook
+	req = httptest.NewRequest("GET", "/api/webhooks", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Then: 請求成功
+	assert.Equal(t, http.StatusOK, w.Code)
 }
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/traditionalchinese"
+
+	"todo/internal/domain/student"
+)
+
+// maxImportBytes bounds the size of an uploaded import file.
+const maxImportBytes = 10 << 20
+
+// importFormField is the multipart form field carrying the CSV file.
+const importFormField = "file"
+
+// importColumns maps the accepted CSV headers, in Chinese or as the JSON
+// field names, to student fields.
+var importColumns = map[string]string{
+	"學號":             "student_number",
+	"姓名":             "name",
+	"電子郵件":           "email",
+	"班級":             "class",
+	"年級":             "grade",
+	"student_number": "student_number",
+	"name":           "name",
+	"email":          "email",
+	"class":          "class",
+	"grade":          "grade",
+}
+
+// requiredImportColumns must be present in the header of every import.
+var requiredImportColumns = []string{"student_number", "name", "email", "class"}
+
+// missingColumnError reports an import header lacking a required column.
+type missingColumnError struct {
+	field string
+}
+
+func (e *missingColumnError) Error() string {
+	return "missing column " + e.field
+}
+
+// ImportReport is the response of POST /api/students/import.
+type ImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Rows      []ImportRowReport `json:"rows"`
+}
+
+// ImportRowReport is the outcome of one imported row. Errors uses the same
+// format as validation error responses.
+type ImportRowReport struct {
+	Line          int              `json:"line"`
+	StudentNumber string           `json:"student_number,omitempty"`
+	Status        string           `json:"status"`
+	Student       *student.Student `json:"student,omitempty"`
+	Errors        []FieldError     `json:"errors,omitempty"`
+}
+
+// ImportStudents handles POST /api/students/import
+// The body is a CSV file, sent as text/csv or as the "file" field of a
+// multipart form, encoded in UTF-8 or Big5. The first line is a header
+// naming the columns 學號, 姓名, 電子郵件, 班級 and optionally 年級 (or their
+// JSON field names). Every row is validated like POST /api/students and
+// the response reports the outcome of each row. With ?dry_run=true the
+// rows are only checked.
+func (h *Handler) ImportStudents(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		h.handleError(c, student.NewInvalidQueryParameterError("dry_run", c.Query("dry_run")))
+		return
+	}
+
+	data, charset, err := readImportFile(c)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.handlePayloadTooLarge(c)
+		return
+	}
+	if err != nil {
+		h.handleInvalidRequest(c)
+		return
+	}
+
+	rows, err := parseImportCSV(data, charset)
+	if err != nil {
+		h.handleInvalidCSV(c, err)
+		return
+	}
+
+	report, err := h.useCase.ImportStudents(c.Request.Context(), rows, dryRun)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, importReport(report, negotiateLanguage(c)))
+}
+
+// readImportFile returns the uploaded CSV file and the charset declared
+// for it, if any.
+func readImportFile(c *gin.Context) ([]byte, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	if c.ContentType() != "multipart/form-data" {
+		data, err := io.ReadAll(c.Request.Body)
+		return data, contentCharset(c.GetHeader("Content-Type")), err
+	}
+
+	header, err := c.FormFile(importFormField)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	return data, contentCharset(header.Header.Get("Content-Type")), err
+}
+
+// contentCharset returns the charset parameter of a Content-Type header.
+func contentCharset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(params["charset"])
+}
+
+// decodeImportFile converts data to UTF-8. Without a declared charset,
+// data that is not valid UTF-8 is assumed to be Big5, the encoding used by
+// spreadsheets exported on Traditional Chinese Windows.
+func decodeImportFile(data []byte, charset string) ([]byte, error) {
+	switch charset {
+	case "":
+		if !utf8.Valid(data) {
+			return traditionalchinese.Big5.NewDecoder().Bytes(data)
+		}
+	case "utf-8", "utf8":
+	case "big5", "big-5", "cp950":
+		return traditionalchinese.Big5.NewDecoder().Bytes(data)
+	default:
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return bytes.TrimPrefix(data, []byte(utf8BOM)), nil
+}
+
+// parseImportCSV decodes an import file into rows. Cells that cannot be
+// decoded are reported on their row; a missing trailing cell is treated
+// as empty.
+func parseImportCSV(data []byte, charset string) ([]*student.ImportRow, error) {
+	data, err := decodeImportFile(data, charset)
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		if field, ok := importColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[field] = i
+		}
+	}
+	for _, field := range requiredImportColumns {
+		if _, ok := columns[field]; !ok {
+			return nil, &missingColumnError{field: field}
+		}
+	}
+
+	var rows []*student.ImportRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == student.MaxImportRows {
+			return nil, fmt.Errorf("more than %d rows", student.MaxImportRows)
+		}
+
+		line, _ := r.FieldPos(0)
+		cell := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return restoreFormula(strings.TrimSpace(record[i]))
+		}
+
+		row := &student.ImportRow{
+			Line: line,
+			Request: student.CreateStudentRequest{
+				StudentNumber: cell("student_number"),
+				Name:          cell("name"),
+				Email:         cell("email"),
+				Class:         cell("class"),
+			},
+		}
+		if v := cell("grade"); v != "" {
+			if grade, err := strconv.Atoi(v); err == nil {
+				row.Request.Grade = &grade
+			} else {
+				row.Err = student.NewInvalidGradeError()
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// restoreFormula undoes neutraliseFormula, so that an exported roster
+// imports with the original values.
+func restoreFormula(v string) string {
+	if len(v) > 1 && v[0] == '\'' && strings.ContainsRune(formulaTriggers, rune(v[1])) {
+		return v[1:]
+	}
+	return v
+}
+
+// importReport renders report with its row errors localized in lang.
+func importReport(report *student.ImportReport, lang student.Language) *ImportReport {
+	resp := &ImportReport{
+		DryRun:    report.DryRun,
+		Total:     len(report.Rows),
+		Succeeded: report.Succeeded,
+		Failed:    report.Failed,
+		Rows:      make([]ImportRowReport, len(report.Rows)),
+	}
+	for i, row := range report.Rows {
+		resp.Rows[i] = ImportRowReport{
+			Line:          row.Line,
+			StudentNumber: row.StudentNumber,
+			Status:        string(row.Status),
+			Student:       row.Student,
+		}
+		if row.Err != nil {
+			resp.Rows[i].Errors = toAPIError(row.Err).allFieldErrors(lang)
+		}
+	}
+	return resp
+}
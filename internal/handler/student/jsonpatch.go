@@ -0,0 +1,387 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"todo/internal/domain/student"
+)
+
+// mediaTypeJSONPatch is the media type of an RFC 6902 JSON Patch document.
+const mediaTypeJSONPatch = "application/json-patch+json"
+
+var (
+	// errPatchTestFailed reports a "test" operation whose value did not
+	// match; the patch is not applied.
+	errPatchTestFailed = errors.New("json patch: test operation failed")
+
+	// errReadOnlyMember reports a patch that changes a member the client
+	// cannot write, such as id or version.
+	errReadOnlyMember = errors.New("json patch: read-only member changed")
+)
+
+// readOnlyMembers are the members of a student that a patch may test but
+// not change.
+var readOnlyMembers = []string{"id", "version", "created_at", "updated_at"}
+
+// jsonPatchOperation is a single operation of an RFC 6902 JSON Patch.
+// Path and From are pointers to tell a missing member from the root
+// pointer "".
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// parseJSONPatch decodes an RFC 6902 JSON Patch document.
+func parseJSONPatch(body []byte) ([]jsonPatchOperation, error) {
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, err
+	}
+	if ops == nil {
+		return nil, errors.New("json patch: document must be an array")
+	}
+	return ops, nil
+}
+
+// applyJSONPatch applies ops to the JSON representation of s and converts
+// the result into the UpdateStudentRequest that produces it. Operations
+// are applied to a copy, so either all of them succeed or s is left as it
+// was. A failed "test" operation yields errPatchTestFailed.
+func applyJSONPatch(s *student.Student, ops []jsonPatchOperation) (*student.UpdateStudentRequest, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var original map[string]any
+	if err := json.Unmarshal(raw, &original); err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if doc, err = op.apply(doc); err != nil {
+			if errors.Is(err, errPatchTestFailed) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("json patch: operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+
+	return patchedUpdateRequest(s, original, doc)
+}
+
+// apply performs op on doc and returns the resulting document.
+func (op jsonPatchOperation) apply(doc any) (any, error) {
+	if op.Path == nil {
+		return nil, errors.New("missing path")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return pointerAdd(doc, path, value)
+		case "replace":
+			if doc, _, err = pointerRemove(doc, path); err != nil {
+				return nil, err
+			}
+			return pointerAdd(doc, path, value)
+		default:
+			current, err := pointerGet(doc, path)
+			if err != nil || !reflect.DeepEqual(current, value) {
+				return nil, errPatchTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		doc, _, err = pointerRemove(doc, path)
+		return doc, err
+	case "move", "copy":
+		if op.From == nil {
+			return nil, errors.New("missing from")
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if op.Op == "move" {
+			if isPointerPrefix(from, path) && len(from) < len(path) {
+				return nil, errors.New("cannot move a value into one of its children")
+			}
+			if doc, value, err = pointerRemove(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			if value, err = pointerGet(doc, from); err != nil {
+				return nil, err
+			}
+			if value, err = deepCopyJSON(value); err != nil {
+				return nil, err
+			}
+		}
+		return pointerAdd(doc, path, value)
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+// value decodes the value member of op, which is required by add,
+// replace and test.
+func (op jsonPatchOperation) value() (any, error) {
+	if op.Value == nil {
+		return nil, errors.New("missing value")
+	}
+	var v any
+	if err := json.Unmarshal(op.Value, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// patchedUpdateRequest turns the patched document of s into an update.
+// Writable members that changed are set; a removed or null required
+// member becomes "" so that validation reports it as missing, and a
+// removed or null grade clears the grade. Unknown members are ignored.
+func patchedUpdateRequest(s *student.Student, original map[string]any, patched any) (*student.UpdateStudentRequest, error) {
+	doc, ok := patched.(map[string]any)
+	if !ok {
+		return nil, errNotAnObject
+	}
+	for _, key := range readOnlyMembers {
+		if !reflect.DeepEqual(original[key], doc[key]) {
+			return nil, fmt.Errorf("%w: %s", errReadOnlyMember, key)
+		}
+	}
+
+	req := &student.UpdateStudentRequest{}
+	fields := []struct {
+		key     string
+		current string
+		dst     **string
+	}{
+		{"student_number", s.StudentNumber, &req.StudentNumber},
+		{"name", s.Name, &req.Name},
+		{"email", s.Email, &req.Email},
+		{"class", s.Class, &req.Class},
+	}
+	for _, f := range fields {
+		var v string
+		switch raw := doc[f.key].(type) {
+		case nil:
+		case string:
+			v = raw
+		default:
+			return nil, fmt.Errorf("json patch: %s must be a string", f.key)
+		}
+		if v != f.current {
+			*f.dst = &v
+		}
+	}
+
+	switch raw := doc["grade"].(type) {
+	case nil:
+		req.ClearGrade = s.Grade != nil
+	case float64:
+		if raw != math.Trunc(raw) || raw < math.MinInt32 || raw > math.MaxInt32 {
+			return nil, errors.New("json patch: grade must be an integer")
+		}
+		grade := int(raw)
+		if s.Grade == nil || *s.Grade != grade {
+			req.Grade = &grade
+		}
+	default:
+		return nil, errors.New("json patch: grade must be an integer")
+	}
+
+	return req, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped
+// reference tokens. The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// isPointerPrefix reports whether prefix refers to path or one of its
+// ancestors.
+func isPointerPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pointerGet returns the value at path.
+func pointerGet(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			v, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q not found", token)
+			}
+			doc = v
+		case []any:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("path %q not found", token)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds value at path and returns the resulting document.
+// Adding to an object member replaces it; adding to an array inserts.
+func pointerAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := pointerGet(doc, parentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+		return doc, nil
+	case []any:
+		i := len(node)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(node)); err != nil {
+				return nil, err
+			}
+		}
+		grown := make([]any, 0, len(node)+1)
+		grown = append(grown, node[:i]...)
+		grown = append(grown, value)
+		grown = append(grown, node[i:]...)
+		return pointerReplace(doc, parentPath, grown)
+	default:
+		return nil, fmt.Errorf("cannot add %q to a scalar", last)
+	}
+}
+
+// pointerRemove removes the value at path and returns the resulting
+// document together with the removed value.
+func pointerRemove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := pointerGet(doc, parentPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch node := parent.(type) {
+	case map[string]any:
+		v, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("path %q not found", last)
+		}
+		delete(node, last)
+		return doc, v, nil
+	case []any:
+		i, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := node[i]
+		shrunk := make([]any, 0, len(node)-1)
+		shrunk = append(shrunk, node[:i]...)
+		shrunk = append(shrunk, node[i+1:]...)
+		doc, err = pointerReplace(doc, parentPath, shrunk)
+		return doc, v, err
+	default:
+		return nil, nil, fmt.Errorf("path %q not found", last)
+	}
+}
+
+// pointerReplace stores value at the existing location path. It is used
+// to write back arrays, which cannot be modified in place.
+func pointerReplace(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := pointerGet(doc, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = value
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array reference token no greater than max.
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+// deepCopyJSON copies a decoded JSON value so that copies do not share
+// objects or arrays.
+func deepCopyJSON(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c any
+	err = json.Unmarshal(raw, &c)
+	return c, err
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+
+	"todo/internal/domain/student"
+)
+
+// languageMatcher picks the best supported language for Accept-Language.
+// The first tag is the fallback when nothing matches.
+var languageMatcher = func() language.Matcher {
+	tags := make([]language.Tag, len(student.SupportedLanguages))
+	for i, lang := range student.SupportedLanguages {
+		tags[i] = language.MustParse(string(lang))
+	}
+	return language.NewMatcher(tags)
+}()
+
+// negotiateLanguage returns the message language preferred by the client.
+func negotiateLanguage(c *gin.Context) student.Language {
+	header := c.GetHeader("Accept-Language")
+	if header == "" {
+		return student.DefaultLanguage
+	}
+
+	prefs, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(prefs) == 0 {
+		return student.DefaultLanguage
+	}
+
+	_, index, confidence := languageMatcher.Match(prefs...)
+	if confidence == language.No {
+		return student.DefaultLanguage
+	}
+	return student.SupportedLanguages[index]
+}
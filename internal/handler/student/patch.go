@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"todo/internal/domain/student"
+)
+
+// mediaTypeMergePatch is the media type of an RFC 7396 JSON Merge Patch.
+const mediaTypeMergePatch = "application/merge-patch+json"
+
+// acceptPatch lists the PATCH media types, advertised via Accept-Patch.
+const acceptPatch = mediaTypeMergePatch + ", " + mediaTypeJSONPatch + ", " + mediaTypeJSON
+
+// PatchStudent handles PATCH /api/students/:studentNumber
+// The body is either an RFC 7396 JSON Merge Patch, where absent members
+// are left unchanged and null clears an optional field such as grade, or
+// an RFC 6902 JSON Patch, whose "test" operations act as preconditions.
+func (h *Handler) PatchStudent(c *gin.Context) {
+	ctx := c.Request.Context()
+	studentNumber := c.Param("studentNumber")
+
+	h.patch(c,
+		func() (*student.Student, error) { return h.useCase.GetStudent(ctx, studentNumber) },
+		func(req *student.UpdateStudentRequest) (*student.Student, error) {
+			return h.useCase.UpdateStudent(ctx, studentNumber, req)
+		},
+	)
+}
+
+// PatchStudentByID handles PATCH /api/students/by-id/:id
+func (h *Handler) PatchStudentByID(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	h.patch(c,
+		func() (*student.Student, error) { return h.useCase.GetStudentByID(ctx, id) },
+		func(req *student.UpdateStudentRequest) (*student.Student, error) {
+			return h.useCase.UpdateStudentByID(ctx, id, req)
+		},
+	)
+}
+
+// patch decodes the request body according to its media type and applies
+// it through update, honouring If-Match.
+func (h *Handler) patch(
+	c *gin.Context,
+	current func() (*student.Student, error),
+	update func(*student.UpdateStudentRequest) (*student.Student, error),
+) {
+	contentType := c.ContentType()
+	switch contentType {
+	case mediaTypeMergePatch, mediaTypeJSON, mediaTypeJSONPatch:
+	default:
+		c.Header("Accept-Patch", acceptPatch)
+		h.handleUnsupportedMediaType(c)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.handleInvalidRequest(c)
+		return
+	}
+
+	var req *student.UpdateStudentRequest
+	if contentType == mediaTypeJSONPatch {
+		req, err = h.jsonPatchRequest(c, body, current)
+	} else {
+		req, err = h.mergePatchRequest(c, body, current)
+	}
+	if err != nil {
+		return
+	}
+
+	s, err := update(req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	setETag(c, s)
+	c.JSON(http.StatusOK, s)
+}
+
+// mergePatchRequest decodes a JSON Merge Patch body into an update that is
+// conditional on If-Match. On error the response has been written.
+func (h *Handler) mergePatchRequest(
+	c *gin.Context,
+	body []byte,
+	current func() (*student.Student, error),
+) (*student.UpdateStudentRequest, error) {
+	req, err := parseMergePatch(body)
+	if err != nil {
+		h.handleInvalidRequest(c)
+		return nil, err
+	}
+
+	version, err := expectedVersion(c, current)
+	if err != nil {
+		h.handleError(c, err)
+		return nil, err
+	}
+	req.ExpectedVersion = version
+	return req, nil
+}
+
+// jsonPatchRequest applies a JSON Patch body to the current student and
+// returns the resulting update. The update is conditional on the version
+// the operations were evaluated against, so "test" operations cannot be
+// invalidated by a concurrent write. On error the response has been
+// written.
+func (h *Handler) jsonPatchRequest(
+	c *gin.Context,
+	body []byte,
+	current func() (*student.Student, error),
+) (*student.UpdateStudentRequest, error) {
+	ops, err := parseJSONPatch(body)
+	if err != nil {
+		h.handleInvalidRequest(c)
+		return nil, err
+	}
+
+	s, err := current()
+	if err != nil {
+		h.handleError(c, err)
+		return nil, err
+	}
+	version, err := expectedVersion(c, func() (*student.Student, error) { return s, nil })
+	if err == nil && version != student.AnyVersion && version != s.Version {
+		err = student.NewVersionConflictError()
+	}
+	if err != nil {
+		h.handleError(c, err)
+		return nil, err
+	}
+
+	req, err := applyJSONPatch(s, ops)
+	switch {
+	case errors.Is(err, errPatchTestFailed):
+		h.handlePatchTestFailed(c)
+		return nil, err
+	case err != nil:
+		h.handleInvalidRequest(c)
+		return nil, err
+	}
+	req.ExpectedVersion = s.Version
+	return req, nil
+}
+
+// parseMergePatch converts an RFC 7396 merge patch document into an
+// UpdateStudentRequest. null on a required field sets it to "" so that
+// validation reports it as missing; null on grade clears the grade.
+// Members that are not writable student fields are ignored.
+func parseMergePatch(body []byte) (*student.UpdateStudentRequest, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	// A non-object patch would replace the whole student, which is
+	// what PUT is for.
+	if doc == nil {
+		return nil, errNotAnObject
+	}
+
+	req := &student.UpdateStudentRequest{}
+	fields := map[string]**string{
+		"student_number": &req.StudentNumber,
+		"name":           &req.Name,
+		"email":          &req.Email,
+		"class":          &req.Class,
+	}
+	for key, dst := range fields {
+		raw, ok := doc[key]
+		if !ok {
+			continue
+		}
+		v := ""
+		if !isJSONNull(raw) {
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, err
+			}
+		}
+		*dst = &v
+	}
+
+	if raw, ok := doc["grade"]; ok {
+		if isJSONNull(raw) {
+			req.ClearGrade = true
+		} else {
+			var grade int
+			if err := json.Unmarshal(raw, &grade); err != nil {
+				return nil, err
+			}
+			req.Grade = &grade
+		}
+	}
+
+	return req, nil
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
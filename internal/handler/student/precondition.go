@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"todo/internal/domain/student"
+)
+
+// setETag advertises the version of s as a strong entity tag.
+func setETag(c *gin.Context, s *student.Student) {
+	c.Header("ETag", formatETag(s.Version))
+}
+
+// formatETag renders a student version as an entity tag.
+func formatETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// expectedVersion resolves the If-Match header of a write into the version
+// the stored student must have. It returns student.AnyVersion when the
+// header is absent or "*". When several entity tags are listed, current is
+// used to pick the one matching the stored student. A header that cannot
+// match yields VersionConflict, i.e. 412 Precondition Failed.
+func expectedVersion(c *gin.Context, current func() (*student.Student, error)) (int64, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return student.AnyVersion, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-Match uses strong comparison, so weak tags never match.
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		v, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil || v < student.InitialVersion {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, student.NewVersionConflictError()
+	case 1:
+		return versions[0], nil
+	}
+
+	s, err := current()
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range versions {
+		if v == s.Version {
+			return v, nil
+		}
+	}
+	return 0, student.NewVersionConflictError()
+}
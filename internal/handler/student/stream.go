@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"todo/internal/domain/student"
+	studentevent "todo/internal/event/student"
+)
+
+// LastEventIDHeader is sent by EventSource clients when they reconnect.
+const LastEventIDHeader = "Last-Event-ID"
+
+// Stream event sent when a stream cannot be resumed from Last-Event-ID:
+// events may have been missed and the client should reload the roster.
+const streamEventReset = "reset"
+
+// streamHeartbeat is how often an idle stream sends a comment, so that
+// proxies keep the connection open.
+const streamHeartbeat = 15 * time.Second
+
+// StreamHandler serves the live feed of student changes. It renders
+// errors like Handler.
+type StreamHandler struct {
+	*Handler
+	feed *studentevent.Feed
+}
+
+// NewStreamHandler creates a new stream HTTP handler following feed.
+func NewStreamHandler(h *Handler, feed *studentevent.Feed) *StreamHandler {
+	return &StreamHandler{
+		Handler: h,
+		feed:    feed,
+	}
+}
+
+// StreamStudents handles GET /api/students/stream
+// Streams student changes as server-sent events named after the event
+// type, with the event envelope as data and the event ID as id. With
+// ?class= (repeatable) only changes of students in, or moved out of, the
+// given classes are sent. A client reconnecting with Last-Event-ID first
+// receives the changes it missed, or a reset event if they are no longer
+// known. Browsers, whose EventSource cannot send an Authorization
+// header, may pass their bearer token as ?access_token=.
+func (h *StreamHandler) StreamStudents(c *gin.Context) {
+	classes := c.QueryArray("class")
+	backlog, follower, resumed := h.feed.Follow(c.GetHeader(LastEventIDHeader))
+	defer follower.Close()
+
+	// A stream outlives the server's write timeout.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	send := func(env *student.EventEnvelope) error {
+		if len(classes) > 0 && !env.InClass(classes...) {
+			return nil
+		}
+		return sse.Encode(c.Writer, sse.Event{Id: env.ID, Event: string(env.Type), Data: env})
+	}
+
+	if !resumed {
+		if err := sse.Encode(c.Writer, sse.Event{Event: streamEventReset, Data: "{}"}); err != nil {
+			return
+		}
+	}
+	for _, env := range backlog {
+		if err := send(env); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case env, ok := <-follower.C:
+			if !ok {
+				// Fell behind, or the server is shutting down; the
+				// client reconnects and resumes.
+				return
+			}
+			err = send(env)
+		case <-heartbeat.C:
+			_, err = c.Writer.WriteString(": heartbeat\n\n")
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// RegisterStreamRoutes registers the live feed route. Streams only end
+// when the client leaves or the feed is closed, so the server must close
+// the feed on shutdown, e.g. with http.Server.RegisterOnShutdown.
+func RegisterStreamRoutes(router *gin.Engine, handler *StreamHandler) {
+	router.GET("/api/students/stream", accessTokenFromQuery, handler.requestContext, handler.StreamStudents)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"todo/internal/domain/student"
+	studentusecase "todo/internal/usecase/student"
+)
+
+// WebhookHandler handles HTTP requests for webhook subscriptions. It
+// renders errors like Handler.
+type WebhookHandler struct {
+	*Handler
+	webhooks *studentusecase.WebhookUseCase
+}
+
+// NewWebhookHandler creates a new webhook HTTP handler sharing the error
+// responses of h.
+func NewWebhookHandler(h *Handler, webhooks *studentusecase.WebhookUseCase) *WebhookHandler {
+	return &WebhookHandler{
+		Handler:  h,
+		webhooks: webhooks,
+	}
+}
+
+// CreateWebhookResponse is a new subscription with its signing secret,
+// which is not returned by any other endpoint.
+type CreateWebhookResponse struct {
+	*student.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// CreateWebhook handles POST /api/webhooks
+// Registers a subscription to the student events matching event_types
+// and classes; empty filters match every event.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req student.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleInvalidRequest(c)
+		return
+	}
+
+	s, err := h.webhooks.CreateWebhook(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateWebhookResponse{WebhookSubscription: s, Secret: s.Secret})
+}
+
+// GetWebhooks handles GET /api/webhooks
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	webhooks, err := h.webhooks.ListWebhooks(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook handles GET /api/webhooks/:id
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	s, err := h.webhooks.GetWebhook(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if err := h.webhooks.DeleteWebhook(c.Request.Context(), c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries handles GET /api/webhooks/:id/deliveries
+// Returns the delivery log of the subscription, newest first: every
+// attempted event with its status, attempts, last response status and
+// error. ?status=dead lists the dead-lettered deliveries.
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	status := student.WebhookDeliveryStatus(c.Query("status"))
+	deliveries, err := h.webhooks.ListWebhookDeliveries(c.Request.Context(), c.Param("id"), status)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RegisterWebhookRoutes registers the webhook subscription routes. With
+// an Authenticator they are restricted to principals with the admin role,
+// since a subscription receives every change to student records.
+func RegisterWebhookRoutes(router *gin.Engine, handler *WebhookHandler) {
+	group := router.Group("/api/webhooks", handler.requestContext, handler.requireRole(student.RoleAdmin))
+	{
+		group.POST("", handler.CreateWebhook)
+		group.GET("", handler.GetWebhooks)
+		group.GET("/:id", handler.GetWebhook)
+		group.DELETE("/:id", handler.DeleteWebhook)
+		group.GET("/:id/deliveries", handler.GetWebhookDeliveries)
+	}
+}
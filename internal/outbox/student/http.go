@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"todo/internal/domain/student"
+)
+
+// Headers sent with every message delivered over HTTP.
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	EventTypeHeader      = "X-Event-Type"
+)
+
+// HTTPSink delivers messages by POSTing their JSON payload to a URL. Any
+// 2xx response accepts the message.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink creates a sink posting to url.
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the URL of the sink.
+func (s *HTTPSink) Name() string { return s.url }
+
+// Deliver posts m, with its ID as the idempotency key.
+func (s *HTTPSink) Deliver(ctx context.Context, m *student.OutboxMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(m.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, m.ID)
+	req.Header.Set(EventTypeHeader, string(m.EventType))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package outbox relays the student outbox to downstream systems.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"todo/internal/domain/student"
+)
+
+// Sink is a downstream system that receives outbox messages. Delivery is
+// at least once: a message may be delivered to a sink again if the relay
+// stops before recording that the sink accepted it, and receivers should
+// use the message ID as an idempotency key.
+type Sink interface {
+	// Name identifies the sink in the delivery state of a message; it
+	// must be unique among the sinks of a relay and stable across runs.
+	Name() string
+	Deliver(ctx context.Context, m *student.OutboxMessage) error
+}
+
+// Store is the part of the student repository used by the relay.
+type Store interface {
+	FindPendingOutbox(ctx context.Context, now time.Time, limit int) ([]*student.OutboxMessage, error)
+	UpdateOutbox(ctx context.Context, m *student.OutboxMessage) error
+}
+
+// Relay delivers pending outbox messages to every sink. Each sink that
+// accepts a message is recorded in its DeliveredTo, and retries go only to
+// the sinks that have not, so a failing sink neither holds back nor
+// duplicates delivery to the others. A message is delivered once all sinks
+// accept it; a failed attempt is retried with exponential backoff until
+// MaxAttempts, after which the message is marked dead for the sinks still
+// missing it. Messages are picked in append order, but a retried message may
+// reach a sink after later ones; receivers can order changes of a student
+// by its version.
+type Relay struct {
+	store Store
+	sinks []Sink
+
+	BatchSize   int           // messages read per store call
+	MaxAttempts int           // attempts before a message is marked dead
+	MinBackoff  time.Duration // delay after the first failed attempt
+	MaxBackoff  time.Duration // upper bound of the delay between attempts
+
+	now func() time.Time
+}
+
+// NewRelay creates a relay from store to sinks with default settings.
+func NewRelay(store Store, sinks ...Sink) *Relay {
+	return &Relay{
+		store:       store,
+		sinks:       sinks,
+		BatchSize:   100,
+		MaxAttempts: 10,
+		MinBackoff:  time.Second,
+		MaxBackoff:  10 * time.Minute,
+		now:         time.Now,
+	}
+}
+
+// Run relays the outbox, draining it every interval, until ctx is done.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.RunOnce(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("outbox: relay: %v", err)
+				}
+				break
+			}
+			if n < r.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce makes one delivery attempt for up to BatchSize due messages and
+// returns how many it attempted.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	messages, err := r.store.FindPendingOutbox(ctx, r.now(), r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range messages {
+		deliverErr := r.deliver(ctx, m)
+		if ctx.Err() != nil {
+			// An interrupted attempt does not count.
+			return 0, ctx.Err()
+		}
+
+		now := r.now()
+		m.Attempts++
+		switch {
+		case deliverErr == nil:
+			m.Status = student.OutboxDelivered
+			m.LastError = ""
+			m.DeliveredAt = &now
+		case m.Attempts >= r.MaxAttempts:
+			m.Status = student.OutboxDead
+			m.LastError = deliverErr.Error()
+			log.Printf("outbox: giving up on %s %s after %d attempts: %v", m.EventType, m.ID, m.Attempts, deliverErr)
+		default:
+			m.LastError = deliverErr.Error()
+			m.NextAttemptAt = now.Add(r.backoff(m.Attempts))
+		}
+		if err := r.store.UpdateOutbox(ctx, m); err != nil {
+			return 0, err
+		}
+	}
+	return len(messages), nil
+}
+
+// deliver hands m to every sink not in m.DeliveredTo, adding those that
+// accept it, and returns the failures of the others.
+func (r *Relay) deliver(ctx context.Context, m *student.OutboxMessage) error {
+	var errs []error
+	for _, sink := range r.sinks {
+		name := sink.Name()
+		if slices.Contains(m.DeliveredTo, name) {
+			continue
+		}
+		if err := sink.Deliver(ctx, m); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		m.DeliveredTo = append(m.DeliveredTo, name)
+	}
+	return errors.Join(errs...)
+}
+
+// backoff returns the delay after the given number of failed attempts:
+// MinBackoff doubled for each attempt after the first, up to MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.MinBackoff
+	for i := 1; i < attempts && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.MaxBackoff)
+}
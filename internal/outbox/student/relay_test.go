@@ -0,0 +1,194 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/internal/domain/student"
+	studentrepo "todo/internal/repository/student"
+	"todo/internal/repository/student/repositorytest"
+)
+
+// fakeSink records delivered message IDs and fails while fail is set.
+type fakeSink struct {
+	name      string
+	fail      error
+	delivered []string
+}
+
+func (s *fakeSink) Name() string { return s.name }
+
+func (s *fakeSink) Deliver(_ context.Context, m *student.OutboxMessage) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	s.delivered = append(s.delivered, m.ID)
+	return nil
+}
+
+// newTestRelay returns a relay over a memory repository whose clock is
+// controlled by the returned pointer.
+func newTestRelay(t *testing.T, sinks ...Sink) (*Relay, *studentrepo.MemoryRepository, *time.Time) {
+	t.Helper()
+	repo := studentrepo.NewMemoryRepository()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	relay := NewRelay(repo, sinks...)
+	relay.now = func() time.Time { return now }
+	return relay, repo, &now
+}
+
+func TestRelay_DeliversPendingMessages(t *testing.T) {
+	// Given: 兩筆待送出的訊息與兩個下游系統
+	library, cafeteria := &fakeSink{name: "library"}, &fakeSink{name: "cafeteria"}
+	relay, repo, now := newTestRelay(t, library, cafeteria)
+	ctx := context.Background()
+
+	first := repositorytest.NewOutboxMessage(repositorytest.NewStudent("2024001"), *now)
+	second := repositorytest.NewOutboxMessage(repositorytest.NewStudent("2024002"), *now)
+	require.NoError(t, repo.AppendOutbox(ctx, first))
+	require.NoError(t, repo.AppendOutbox(ctx, second))
+
+	// When: 轉送工作執行一次
+	n, err := relay.RunOnce(ctx)
+	require.NoError(t, err)
+
+	// Then: 每個下游系統依序收到每筆訊息，且訊息不再待送
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{first.ID, second.ID}, library.delivered)
+	assert.Equal(t, []string{first.ID, second.ID}, cafeteria.delivered)
+
+	pending, err := repo.FindPendingOutbox(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_RetriesWithBackoffThenGivesUp(t *testing.T) {
+	// Given: 一個暫時無法使用的下游系統
+	sink := &fakeSink{name: "library", fail: errors.New("connection refused")}
+	relay, repo, now := newTestRelay(t, sink)
+	relay.MaxAttempts = 3
+	ctx := context.Background()
+
+	m := repositorytest.NewOutboxMessage(repositorytest.NewStudent("2024001"), *now)
+	require.NoError(t, repo.AppendOutbox(ctx, m))
+
+	// When: 第一次送出失敗
+	_, err := relay.RunOnce(ctx)
+	require.NoError(t, err)
+
+	// Then: 訊息在退避時間後才會重試
+	pending, err := repo.FindPendingOutbox(ctx, *now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	pending, err = repo.FindPendingOutbox(ctx, now.Add(relay.MinBackoff), 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "library: connection refused", pending[0].LastError)
+
+	// When: 之後的重試持續失敗直到上限
+	*now = now.Add(time.Hour)
+	_, err = relay.RunOnce(ctx)
+	require.NoError(t, err)
+	*now = now.Add(time.Hour)
+	_, err = relay.RunOnce(ctx)
+	require.NoError(t, err)
+
+	// Then: 訊息被標記為放棄，不再重試
+	pending, err = repo.FindPendingOutbox(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// And: 恢復後的下游系統不會收到已放棄的訊息
+	sink.fail = nil
+	n, err := relay.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Empty(t, sink.delivered)
+}
+
+func TestRelay_SinksFailIndependently(t *testing.T) {
+	// Given: 一個正常與一個無法使用的下游系統
+	library := &fakeSink{name: "library"}
+	cafeteria := &fakeSink{name: "cafeteria", fail: errors.New("connection refused")}
+	relay, repo, now := newTestRelay(t, library, cafeteria)
+	relay.MaxAttempts = 3
+	ctx := context.Background()
+
+	m := repositorytest.NewOutboxMessage(repositorytest.NewStudent("2024001"), *now)
+	require.NoError(t, repo.AppendOutbox(ctx, m))
+
+	// When: 轉送工作重試兩次
+	for range 2 {
+		_, err := relay.RunOnce(ctx)
+		require.NoError(t, err)
+		*now = now.Add(time.Hour)
+	}
+
+	// Then: 正常的下游系統只收到一次，訊息仍待送給失敗的下游系統
+	assert.Equal(t, []string{m.ID}, library.delivered)
+	pending, err := repo.FindPendingOutbox(ctx, *now, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, []string{"library"}, pending[0].DeliveredTo)
+	assert.Equal(t, "cafeteria: connection refused", pending[0].LastError)
+
+	// When: 失敗的下游系統恢復
+	cafeteria.fail = nil
+	_, err = relay.RunOnce(ctx)
+	require.NoError(t, err)
+
+	// Then: 只有它收到訊息，且訊息完成送出
+	assert.Equal(t, []string{m.ID}, library.delivered)
+	assert.Equal(t, []string{m.ID}, cafeteria.delivered)
+	pending, err = repo.FindPendingOutbox(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	relay := NewRelay(nil)
+	relay.MinBackoff = time.Second
+	relay.MaxBackoff = 5 * time.Second
+
+	assert.Equal(t, time.Second, relay.backoff(1))
+	assert.Equal(t, 2*time.Second, relay.backoff(2))
+	assert.Equal(t, 4*time.Second, relay.backoff(3))
+	assert.Equal(t, 5*time.Second, relay.backoff(4))
+	assert.Equal(t, 5*time.Second, relay.backoff(100))
+}
+
+func TestHTTPSink_SendsIdempotencyKey(t *testing.T) {
+	// Given: 一個記錄請求的下游 HTTP 服務
+	var got *http.Request
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL)
+	m := repositorytest.NewOutboxMessage(repositorytest.NewStudent("2024001"), time.Now())
+
+	// When: 送出訊息
+	require.NoError(t, sink.Deliver(context.Background(), m))
+
+	// Then: 請求帶有冪等鍵與事件類型
+	require.NotNil(t, got)
+	assert.Equal(t, http.MethodPost, got.Method)
+	assert.Equal(t, m.ID, got.Header.Get(IdempotencyKeyHeader))
+	assert.Equal(t, string(student.EventStudentCreated), got.Header.Get(EventTypeHeader))
+
+	// And: 非 2xx 回應視為失敗
+	status = http.StatusServiceUnavailable
+	assert.Error(t, sink.Deliver(context.Background(), m))
+}
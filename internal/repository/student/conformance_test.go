@@ -0,0 +1,20 @@
+package repository_test
+
+import (
+	"testing"
+
+	studentrepo "todo/internal/repository/student"
+	"todo/internal/repository/student/repositorytest"
+)
+
+func TestMemoryRepository_Conformance(t *testing.T) {
+	repositorytest.RunConformance(t, func(t *testing.T) studentrepo.Repository {
+		return studentrepo.NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepository_WebhookConformance(t *testing.T) {
+	repositorytest.RunWebhookConformance(t, func(t *testing.T) studentrepo.WebhookRepository {
+		return studentrepo.NewMemoryRepository()
+	})
+}
@@ -2,33 +2,148 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"todo/internal/domain/student"
 )
 
 // Repository defines the interface for student data persistence.
+// Every state a student is saved or updated to is kept as an immutable
+// version, identified by the student's ID and Version and valid from its
+// UpdatedAt, until the student is purged. A write that keeps the version
+// replaces the version it was made at.
+// Students whose DeletedAt is set are in the trash: lookups, listings and
+// ExistsByStudentNumber only see active students unless stated otherwise,
+// and a trashed student's number may be taken by a new student.
+// Implementations must be safe for concurrent use and return ctx.Err()
+// without side effects when the context is already done. The contract is
+// verified by repositorytest.RunConformance.
 type Repository interface {
 	// Save saves a new student record.
 	// Source: "系統應該成功建立學生記錄" (第 8 行)
 	Save(ctx context.Context, s *student.Student) error
 
-	// FindByStudentNumber retrieves a student by student number.
+	// FindByStudentNumber retrieves an active student by student number.
 	// Source: "我使用學號查詢學生" (第 14 行)
 	FindByStudentNumber(ctx context.Context, studentNumber string) (*student.Student, error)
 
-	// FindAll retrieves all student records.
+	// FindByID retrieves an active student by its stable internal ID.
+	FindByID(ctx context.Context, id string) (*student.Student, error)
+
+	// FindDeleted retrieves the most recently trashed student with the
+	// given student number.
+	FindDeleted(ctx context.Context, studentNumber string) (*student.Student, error)
+
+	// FindAsOf retrieves the version of the student that was active with
+	// the given student number at asOf.
+	FindAsOf(ctx context.Context, studentNumber string, asOf time.Time) (*student.Student, error)
+
+	// FindVersion retrieves one version of the student with the given
+	// internal ID, active or trashed.
+	FindVersion(ctx context.Context, id string, version int64) (*student.Student, error)
+
+	// FindAll retrieves all active student records.
 	// Source: "我請求查詢所有學生" (第 20 行)
 	FindAll(ctx context.Context) ([]*student.Student, error)
 
-	// Update updates an existing student record.
+	// List retrieves one page of students matching q, in a stable order.
+	// q.Deleted selects the trash instead of the active students.
+	// Returns InvalidQueryParameter for a page token that does not match q.
+	List(ctx context.Context, q *student.ListQuery) (*student.StudentPage, error)
+
+	// Update replaces the student record identified by s.ID, active or
+	// trashed, provided its stored version still equals expectedVersion
+	// (compare-and-swap); otherwise VersionConflict is returned. The
+	// caller sets s.Version. Setting s.DeletedAt moves the student to the
+	// trash and clearing it restores the student. If an active student's
+	// number changes, or a student is restored, the record is re-keyed
+	// atomically and StudentNumberAlreadyExists is returned when the
+	// number is taken by another active student.
 	// Source: "系統應該成功更新學生記錄" (第 27 行)
-	Update(ctx context.Context, s *student.Student) error
+	Update(ctx context.Context, s *student.Student, expectedVersion int64) error
 
-	// Delete deletes a student record by student number.
-	// Source: "系統應該成功刪除該學生" (第 33 行)
-	Delete(ctx context.Context, studentNumber string) error
+	// Purge permanently removes the trashed students deleted before the
+	// given time, with all their versions, and returns how many were
+	// removed.
+	Purge(ctx context.Context, deletedBefore time.Time) (int, error)
 
-	// ExistsByStudentNumber checks if a student number exists.
-	// Used for uniqueness validation.
+	// ExistsByStudentNumber checks if an active student has the student
+	// number. Used for uniqueness validation.
 	ExistsByStudentNumber(ctx context.Context, studentNumber string) (bool, error)
+
+	// AppendAuditEntry records a change of a student. Entries are never
+	// modified, and are kept when the student is purged.
+	AppendAuditEntry(ctx context.Context, e *student.AuditEntry) error
+
+	// FindAuditEntries retrieves the audit trail of the student with the
+	// given internal ID, oldest first, or an empty slice.
+	FindAuditEntries(ctx context.Context, studentID string) ([]*student.AuditEntry, error)
+
+	// AppendOutbox adds a message to the outbox. Writing it in the unit
+	// of work of the change it announces makes the announcement reliable.
+	AppendOutbox(ctx context.Context, m *student.OutboxMessage) error
+
+	// FindPendingOutbox retrieves up to limit pending outbox messages due
+	// at now, in the order they were appended.
+	FindPendingOutbox(ctx context.Context, now time.Time, limit int) ([]*student.OutboxMessage, error)
+
+	// UpdateOutbox records the outcome of a delivery attempt: it replaces
+	// the delivery state (Status, Attempts, NextAttemptAt, LastError and
+	// DeliveredAt) of the message with m.ID.
+	UpdateOutbox(ctx context.Context, m *student.OutboxMessage) error
+
+	// FindChanges retrieves up to limit entries of the change log with a
+	// sequence above since, in sequence order. Save and Update record
+	// every write in the log, in the same unit of work, with a sequence
+	// higher than any before; the log keeps only the latest change of
+	// each student. Trashed students are tombstones, which are kept when
+	// the student is purged.
+	FindChanges(ctx context.Context, since int64, limit int) ([]*student.Change, error)
+
+	// WithinTx runs fn as a unit of work. The writes made through tx
+	// become visible together when fn returns nil and are discarded when
+	// it returns an error, which WithinTx then returns. Reads through tx
+	// see the unit's own writes. fn must only use tx, not the receiver,
+	// which may be locked until the unit ends. Calling WithinTx on tx
+	// joins the enclosing unit.
+	WithinTx(ctx context.Context, fn func(tx Repository) error) error
+}
+
+// WebhookRepository defines the persistence of webhook subscriptions and
+// their deliveries. Deleting a subscription deletes its deliveries.
+// Implementations must be safe for concurrent use and return ctx.Err()
+// without side effects when the context is already done. The contract is
+// verified by repositorytest.RunWebhookConformance.
+type WebhookRepository interface {
+	// SaveWebhook saves a new subscription.
+	SaveWebhook(ctx context.Context, s *student.WebhookSubscription) error
+
+	// FindWebhook retrieves a subscription by ID, or returns
+	// WebhookNotFound.
+	FindWebhook(ctx context.Context, id string) (*student.WebhookSubscription, error)
+
+	// FindAllWebhooks retrieves every subscription, oldest first.
+	FindAllWebhooks(ctx context.Context) ([]*student.WebhookSubscription, error)
+
+	// DeleteWebhook removes a subscription and its deliveries, or returns
+	// WebhookNotFound.
+	DeleteWebhook(ctx context.Context, id string) error
+
+	// EnqueueWebhookDelivery adds a delivery unless the subscription
+	// already has one for d.EventID, so announcing an event twice is
+	// harmless. Returns WebhookNotFound if the subscription is gone.
+	EnqueueWebhookDelivery(ctx context.Context, d *student.WebhookDelivery) error
+
+	// FindDueWebhookDeliveries retrieves up to limit pending deliveries
+	// due at now, in the order they were enqueued.
+	FindDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*student.WebhookDelivery, error)
+
+	// UpdateWebhookDelivery records the outcome of a delivery attempt: it
+	// replaces the delivery state (Status, Attempts, NextAttemptAt,
+	// LastError, ResponseStatus and DeliveredAt) of the delivery with d.ID.
+	UpdateWebhookDelivery(ctx context.Context, d *student.WebhookDelivery) error
+
+	// FindWebhookDeliveries retrieves the deliveries of a subscription,
+	// newest first, or an empty slice.
+	FindWebhookDeliveries(ctx context.Context, subscriptionID string) ([]*student.WebhookDelivery, error)
 }
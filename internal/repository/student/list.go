@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"todo/internal/domain/student"
+)
+
+// sortKeyTimeLayout renders timestamps as fixed-width UTC strings so that
+// lexical and chronological order agree.
+const sortKeyTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// pageCursor is the decoded form of a page token. It records the sort key
+// of the last student on the previous page together with the sort spec
+// the token was issued for.
+type pageCursor struct {
+	Sort string `json:"s"`
+	Keys []any  `json:"k"`
+	ID   string `json:"id"`
+}
+
+// effectiveSort returns the requested sort or the default one.
+func effectiveSort(q *student.ListQuery) []student.SortOrder {
+	if len(q.Sort) == 0 {
+		return student.DefaultSort
+	}
+	return q.Sort
+}
+
+// sortSpec renders orders as a canonical string.
+func sortSpec(orders []student.SortOrder) string {
+	terms := make([]string, len(orders))
+	for i, o := range orders {
+		terms[i] = o.String()
+	}
+	return strings.Join(terms, ",")
+}
+
+// sortKey returns the comparable value of field for s. Missing grades
+// sort as 0, i.e. before every valid grade.
+func sortKey(s *student.Student, field student.SortField) any {
+	switch field {
+	case student.SortByName:
+		return s.Name
+	case student.SortByClass:
+		return s.Class
+	case student.SortByGrade:
+		if s.Grade == nil {
+			return int64(0)
+		}
+		return int64(*s.Grade)
+	case student.SortByCreatedAt:
+		return s.CreatedAt.UTC().Format(sortKeyTimeLayout)
+	case student.SortByUpdatedAt:
+		return s.UpdatedAt.UTC().Format(sortKeyTimeLayout)
+	default:
+		return s.StudentNumber
+	}
+}
+
+// compareKeys compares two values produced by sortKey.
+func compareKeys(a, b any) int {
+	switch av := a.(type) {
+	case int64:
+		bv := b.(int64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	default:
+		return strings.Compare(a.(string), b.(string))
+	}
+}
+
+// compareStudents orders a and b by orders followed by ID.
+func compareStudents(a, b *student.Student, orders []student.SortOrder) int {
+	for _, o := range orders {
+		c := compareKeys(sortKey(a, o.Field), sortKey(b, o.Field))
+		if o.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// afterCursor reports whether s sorts strictly after the cursor position.
+func afterCursor(s *student.Student, cur *pageCursor, orders []student.SortOrder) bool {
+	for i, o := range orders {
+		c := compareKeys(sortKey(s, o.Field), cur.Keys[i])
+		if o.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return s.ID > cur.ID
+}
+
+// encodePageToken builds the token pointing just after last.
+func encodePageToken(last *student.Student, orders []student.SortOrder) string {
+	cur := pageCursor{Sort: sortSpec(orders), ID: last.ID}
+	for _, o := range orders {
+		cur.Keys = append(cur.Keys, sortKey(last, o.Field))
+	}
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodePageToken parses token and checks that it was issued for orders.
+// An empty token yields a nil cursor.
+func decodePageToken(token string, orders []student.SortOrder) (*pageCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	invalid := student.NewInvalidQueryParameterError("page_token", token)
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, invalid
+	}
+	var cur pageCursor
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return nil, invalid
+	}
+	if cur.Sort != sortSpec(orders) || len(cur.Keys) != len(orders) {
+		return nil, invalid
+	}
+
+	// JSON numbers decode as float64; restore the sortKey types.
+	for i, o := range orders {
+		switch v := cur.Keys[i].(type) {
+		case float64:
+			if o.Field != student.SortByGrade {
+				return nil, invalid
+			}
+			cur.Keys[i] = int64(v)
+		case string:
+			if o.Field == student.SortByGrade {
+				return nil, invalid
+			}
+			if o.Field == student.SortByCreatedAt || o.Field == student.SortByUpdatedAt {
+				if _, err := time.Parse(sortKeyTimeLayout, v); err != nil {
+					return nil, invalid
+				}
+			}
+		default:
+			return nil, invalid
+		}
+	}
+	return &cur, nil
+}
+
+// matchesQuery reports whether s passes the filters of q.
+func matchesQuery(s *student.Student, q *student.ListQuery) bool {
+	if s.IsDeleted() != q.Deleted {
+		return false
+	}
+	if q.Class != "" && s.Class != q.Class {
+		return false
+	}
+	if q.Grade != nil && (s.Grade == nil || *s.Grade != *q.Grade) {
+		return false
+	}
+	if q.Search != "" {
+		needle := foldCase(q.Search)
+		if !strings.Contains(foldCase(s.Name), needle) &&
+			!strings.Contains(foldCase(s.StudentNumber), needle) &&
+			!strings.Contains(foldCase(s.Email), needle) {
+			return false
+		}
+	}
+	return true
+}
+
+// foldCase lower-cases s with Unicode case mapping for case-insensitive
+// search. Every backend must match with it, so SQLite calls it as the
+// fold_case function rather than relying on LIKE, which folds ASCII only.
+func foldCase(s string) string {
+	return strings.ToLower(s)
+}
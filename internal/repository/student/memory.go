@@ -1,95 +1,663 @@
 package repository
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"todo/internal/domain/student"
 )
 
 // MemoryRepository is an in-memory implementation of Repository for testing.
+// It stores and returns deep copies so callers never alias stored state.
+// Stored students are immutable: the current state is shared with the
+// list of versions.
 type MemoryRepository struct {
 	mu       sync.RWMutex
-	students map[string]*student.Student
+	students map[string]*student.Student      // keyed by ID, including the trash
+	ids      map[string]string                // student number -> ID of active students
+	versions map[string][]*student.Student    // student ID -> every version, oldest first
+	audit    map[string][]*student.AuditEntry // student ID -> audit trail, oldest first
+	outbox   []*student.OutboxMessage         // in append order
+	changes  map[string]*student.Change       // student ID -> latest change, without Student
+	sequence int64                            // of the latest change
+
+	webhooks   []*student.WebhookSubscription // in creation order
+	deliveries []*student.WebhookDelivery     // in enqueue order
 }
 
 // NewMemoryRepository creates a new in-memory repository.
 func NewMemoryRepository() *MemoryRepository {
 	return &MemoryRepository{
 		students: make(map[string]*student.Student),
+		ids:      make(map[string]string),
+		versions: make(map[string][]*student.Student),
+		audit:    make(map[string][]*student.AuditEntry),
+		changes:  make(map[string]*student.Change),
 	}
 }
 
 // Save saves a new student record.
 func (r *MemoryRepository) Save(ctx context.Context, s *student.Student) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.students[s.StudentNumber]; exists {
-		return student.NewStudentNumberAlreadyExistsError()
+	if !s.IsDeleted() {
+		if _, exists := r.ids[s.StudentNumber]; exists {
+			return student.NewStudentNumberAlreadyExistsError()
+		}
+		r.ids[s.StudentNumber] = s.ID
 	}
 
-	r.students[s.StudentNumber] = s
+	r.students[s.ID] = s.Clone()
+	r.addVersion(r.students[s.ID])
+	r.recordChange(s)
 	return nil
 }
 
 // FindByStudentNumber retrieves a student by student number.
 func (r *MemoryRepository) FindByStudentNumber(ctx context.Context, studentNumber string) (*student.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	s, exists := r.students[studentNumber]
+	id, exists := r.ids[studentNumber]
 	if !exists {
 		return nil, student.NewStudentNotFoundError()
 	}
 
-	return s, nil
+	return r.students[id].Clone(), nil
+}
+
+// FindByID retrieves a student by ID.
+func (r *MemoryRepository) FindByID(ctx context.Context, id string) (*student.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, exists := r.students[id]
+	if !exists || s.IsDeleted() {
+		return nil, student.NewStudentNotFoundError()
+	}
+
+	return s.Clone(), nil
+}
+
+// FindDeleted retrieves the most recently trashed student with the given
+// student number.
+func (r *MemoryRepository) FindDeleted(ctx context.Context, studentNumber string) (*student.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *student.Student
+	for _, s := range r.students {
+		if !s.IsDeleted() || s.StudentNumber != studentNumber {
+			continue
+		}
+		if latest == nil || s.DeletedAt.After(*latest.DeletedAt) ||
+			(s.DeletedAt.Equal(*latest.DeletedAt) && s.ID > latest.ID) {
+			latest = s
+		}
+	}
+	if latest == nil {
+		return nil, student.NewStudentNotFoundError()
+	}
+
+	return latest.Clone(), nil
+}
+
+// FindAsOf retrieves the version of the student that was active with the
+// given student number at asOf.
+func (r *MemoryRepository) FindAsOf(ctx context.Context, studentNumber string, asOf time.Time) (*student.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, versions := range r.versions {
+		// The version current at asOf is the last one valid from before it.
+		i := sort.Search(len(versions), func(i int) bool {
+			return versions[i].UpdatedAt.After(asOf)
+		})
+		if i == 0 {
+			continue
+		}
+		if s := versions[i-1]; !s.IsDeleted() && s.StudentNumber == studentNumber {
+			return s.Clone(), nil
+		}
+	}
+	return nil, student.NewStudentNotFoundError()
+}
+
+// FindVersion retrieves one version of a student.
+func (r *MemoryRepository) FindVersion(ctx context.Context, id string, version int64) (*student.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.versions[id] {
+		if s.Version == version {
+			return s.Clone(), nil
+		}
+	}
+	return nil, student.NewStudentNotFoundError()
 }
 
 // FindAll retrieves all student records.
 func (r *MemoryRepository) FindAll(ctx context.Context) ([]*student.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	students := make([]*student.Student, 0, len(r.students))
-	for _, s := range r.students {
-		students = append(students, s)
+	students := make([]*student.Student, 0, len(r.ids))
+	for _, id := range r.ids {
+		students = append(students, r.students[id].Clone())
 	}
 
 	return students, nil
 }
 
-// Update updates an existing student record.
-func (r *MemoryRepository) Update(ctx context.Context, s *student.Student) error {
+// List retrieves one page of students matching q.
+func (r *MemoryRepository) List(ctx context.Context, q *student.ListQuery) (*student.StudentPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	orders := effectiveSort(q)
+	cur, err := decodePageToken(q.PageToken, orders)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	matched := make([]*student.Student, 0)
+	for _, s := range r.students {
+		if !matchesQuery(s, q) {
+			continue
+		}
+		if cur != nil && !afterCursor(s, cur, orders) {
+			continue
+		}
+		matched = append(matched, s.Clone())
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return compareStudents(matched[i], matched[j], orders) < 0
+	})
+
+	page := &student.StudentPage{Students: matched}
+	if q.PageSize > 0 && len(matched) > q.PageSize {
+		page.Students = matched[:q.PageSize]
+		page.NextPageToken = encodePageToken(page.Students[q.PageSize-1], orders)
+	}
+	return page, nil
+}
+
+// Update updates an existing student record identified by ID if its
+// version matches. Moving the record in or out of the trash or changing
+// its student number re-keys it under the same lock.
+func (r *MemoryRepository) Update(ctx context.Context, s *student.Student, expectedVersion int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.students[s.StudentNumber]; !exists {
+	stored, exists := r.students[s.ID]
+	if !exists {
 		return student.NewStudentNotFoundError()
 	}
+	if stored.Version != expectedVersion {
+		return student.NewVersionConflictError()
+	}
 
-	r.students[s.StudentNumber] = s
+	if !s.IsDeleted() {
+		if id, taken := r.ids[s.StudentNumber]; taken && id != s.ID {
+			return student.NewStudentNumberAlreadyExistsError()
+		}
+	}
+	if !stored.IsDeleted() {
+		delete(r.ids, stored.StudentNumber)
+	}
+	if !s.IsDeleted() {
+		r.ids[s.StudentNumber] = s.ID
+	}
+
+	r.students[s.ID] = s.Clone()
+	r.addVersion(r.students[s.ID])
+	r.recordChange(s)
 	return nil
 }
 
-// Delete deletes a student record by student number.
-func (r *MemoryRepository) Delete(ctx context.Context, studentNumber string) error {
+// addVersion appends s to the versions of its student. A write that did
+// not bump the version replaces the version it was made at.
+func (r *MemoryRepository) addVersion(s *student.Student) {
+	versions := r.versions[s.ID]
+	if n := len(versions); n > 0 && versions[n-1].Version == s.Version {
+		versions = versions[: n-1 : n-1]
+	}
+	r.versions[s.ID] = append(versions, s)
+}
+
+// recordChange makes s the latest change of its student, with the next
+// sequence.
+func (r *MemoryRepository) recordChange(s *student.Student) {
+	r.sequence++
+	change := &student.Change{
+		Sequence:      r.sequence,
+		Type:          student.ChangeUpsert,
+		StudentID:     s.ID,
+		StudentNumber: s.StudentNumber,
+	}
+	if s.IsDeleted() {
+		change.Type = student.ChangeDelete
+	}
+	r.changes[s.ID] = change
+}
+
+// FindChanges retrieves up to limit changes with a sequence above since.
+func (r *MemoryRepository) FindChanges(ctx context.Context, since int64, limit int) ([]*student.Change, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	changes := make([]*student.Change, 0)
+	for _, c := range r.changes {
+		if c.Sequence > since {
+			changes = append(changes, c)
+		}
+	}
+	slices.SortFunc(changes, func(a, b *student.Change) int { return cmp.Compare(a.Sequence, b.Sequence) })
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+
+	for i, c := range changes {
+		change := *c
+		if change.Type == student.ChangeUpsert {
+			change.Student = r.students[c.StudentID].Clone()
+		}
+		changes[i] = &change
+	}
+	return changes, nil
+}
+
+// Purge permanently removes the trashed students deleted before the given
+// time.
+func (r *MemoryRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.students[studentNumber]; !exists {
-		return student.NewStudentNotFoundError()
+	purged := 0
+	for id, s := range r.students {
+		if s.IsDeleted() && s.DeletedAt.Before(deletedBefore) {
+			delete(r.students, id)
+			delete(r.versions, id)
+			purged++
+		}
 	}
-
-	delete(r.students, studentNumber)
-	return nil
+	return purged, nil
 }
 
 // ExistsByStudentNumber checks if a student number exists.
 func (r *MemoryRepository) ExistsByStudentNumber(ctx context.Context, studentNumber string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	_, exists := r.students[studentNumber]
+	_, exists := r.ids[studentNumber]
 	return exists, nil
 }
+
+// AppendAuditEntry records a change of a student.
+func (r *MemoryRepository) AppendAuditEntry(ctx context.Context, e *student.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.audit[e.StudentID] = append(r.audit[e.StudentID], cloneAuditEntry(e))
+	return nil
+}
+
+// FindAuditEntries retrieves the audit trail of a student, oldest first.
+func (r *MemoryRepository) FindAuditEntries(ctx context.Context, studentID string) ([]*student.AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]*student.AuditEntry, len(r.audit[studentID]))
+	for i, e := range r.audit[studentID] {
+		entries[i] = cloneAuditEntry(e)
+	}
+	return entries, nil
+}
+
+// cloneAuditEntry returns a copy of e that shares no slices with it.
+func cloneAuditEntry(e *student.AuditEntry) *student.AuditEntry {
+	c := *e
+	c.Changes = make([]student.FieldChange, len(e.Changes))
+	for i, change := range e.Changes {
+		c.Changes[i] = student.FieldChange{
+			Field:  change.Field,
+			Before: append(json.RawMessage(nil), change.Before...),
+			After:  append(json.RawMessage(nil), change.After...),
+		}
+	}
+	return &c
+}
+
+// AppendOutbox adds a message to the outbox.
+func (r *MemoryRepository) AppendOutbox(ctx context.Context, m *student.OutboxMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.outbox {
+		if stored.ID == m.ID {
+			return fmt.Errorf("outbox message %s already exists", m.ID)
+		}
+	}
+	r.outbox = append(r.outbox, m.Clone())
+	return nil
+}
+
+// FindPendingOutbox retrieves up to limit pending messages due at now.
+func (r *MemoryRepository) FindPendingOutbox(ctx context.Context, now time.Time, limit int) ([]*student.OutboxMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pending := make([]*student.OutboxMessage, 0)
+	for _, m := range r.outbox {
+		if len(pending) == limit {
+			break
+		}
+		if m.Status == student.OutboxPending && !m.NextAttemptAt.After(now) {
+			pending = append(pending, m.Clone())
+		}
+	}
+	return pending, nil
+}
+
+// UpdateOutbox replaces the delivery state of a message.
+func (r *MemoryRepository) UpdateOutbox(ctx context.Context, m *student.OutboxMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, stored := range r.outbox {
+		if stored.ID != m.ID {
+			continue
+		}
+		// The delivery state comes from m, everything else from stored.
+		updated := m.Clone()
+		updated.EventType = stored.EventType
+		updated.StudentID = stored.StudentID
+		updated.Payload = stored.Payload
+		updated.CreatedAt = stored.CreatedAt
+		r.outbox[i] = updated
+		return nil
+	}
+	return fmt.Errorf("outbox message %s not found", m.ID)
+}
+
+// WithinTx runs fn against a snapshot of the repository and publishes the
+// snapshot if fn succeeds. The repository stays locked meanwhile, so units
+// of work are serializable.
+func (r *MemoryRepository) WithinTx(ctx context.Context, fn func(tx Repository) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Stored students are replaced, never modified, so the snapshot can
+	// share them with the repository.
+	tx := NewMemoryRepository()
+	for id, s := range r.students {
+		tx.students[id] = s
+	}
+	for number, id := range r.ids {
+		tx.ids[number] = id
+	}
+	// Clipping makes the unit's appends copy the slices instead of
+	// writing into the repository's backing arrays.
+	for id, versions := range r.versions {
+		tx.versions[id] = versions[:len(versions):len(versions)]
+	}
+	for id, entries := range r.audit {
+		tx.audit[id] = entries[:len(entries):len(entries)]
+	}
+	// Outbox messages are replaced by UpdateOutbox, so the unit needs
+	// its own slice.
+	tx.outbox = append([]*student.OutboxMessage(nil), r.outbox...)
+	for id, c := range r.changes {
+		tx.changes[id] = c
+	}
+	tx.sequence = r.sequence
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	r.students, r.ids, r.versions, r.audit, r.outbox = tx.students, tx.ids, tx.versions, tx.audit, tx.outbox
+	r.changes, r.sequence = tx.changes, tx.sequence
+	return nil
+}
+
+// SaveWebhook saves a new subscription.
+func (r *MemoryRepository) SaveWebhook(ctx context.Context, s *student.WebhookSubscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.webhooks {
+		if stored.ID == s.ID {
+			return fmt.Errorf("webhook %s already exists", s.ID)
+		}
+	}
+	r.webhooks = append(r.webhooks, s.Clone())
+	return nil
+}
+
+// FindWebhook retrieves a subscription by ID.
+func (r *MemoryRepository) FindWebhook(ctx context.Context, id string) (*student.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.webhooks {
+		if s.ID == id {
+			return s.Clone(), nil
+		}
+	}
+	return nil, student.NewWebhookNotFoundError()
+}
+
+// FindAllWebhooks retrieves every subscription, oldest first.
+func (r *MemoryRepository) FindAllWebhooks(ctx context.Context) ([]*student.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhooks := make([]*student.WebhookSubscription, len(r.webhooks))
+	for i, s := range r.webhooks {
+		webhooks[i] = s.Clone()
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a subscription and its deliveries.
+func (r *MemoryRepository) DeleteWebhook(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.webhooks, func(s *student.WebhookSubscription) bool { return s.ID == id })
+	if i < 0 {
+		return student.NewWebhookNotFoundError()
+	}
+	r.webhooks = slices.Delete(r.webhooks, i, i+1)
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(d *student.WebhookDelivery) bool {
+		return d.SubscriptionID == id
+	})
+	return nil
+}
+
+// EnqueueWebhookDelivery adds a delivery unless one exists for the
+// subscription and event.
+func (r *MemoryRepository) EnqueueWebhookDelivery(ctx context.Context, d *student.WebhookDelivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !slices.ContainsFunc(r.webhooks, func(s *student.WebhookSubscription) bool { return s.ID == d.SubscriptionID }) {
+		return student.NewWebhookNotFoundError()
+	}
+	for _, stored := range r.deliveries {
+		if stored.SubscriptionID == d.SubscriptionID && stored.EventID == d.EventID {
+			return nil
+		}
+		if stored.ID == d.ID {
+			return fmt.Errorf("webhook delivery %s already exists", d.ID)
+		}
+	}
+	r.deliveries = append(r.deliveries, d.Clone())
+	return nil
+}
+
+// FindDueWebhookDeliveries retrieves up to limit pending deliveries due at
+// now.
+func (r *MemoryRepository) FindDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*student.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	due := make([]*student.WebhookDelivery, 0)
+	for _, d := range r.deliveries {
+		if len(due) == limit {
+			break
+		}
+		if d.Status == student.WebhookDeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d.Clone())
+		}
+	}
+	return due, nil
+}
+
+// UpdateWebhookDelivery replaces the delivery state of a delivery.
+func (r *MemoryRepository) UpdateWebhookDelivery(ctx context.Context, d *student.WebhookDelivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, stored := range r.deliveries {
+		if stored.ID != d.ID {
+			continue
+		}
+		// The delivery state comes from d, everything else from stored.
+		updated := d.Clone()
+		updated.SubscriptionID = stored.SubscriptionID
+		updated.EventID = stored.EventID
+		updated.EventType = stored.EventType
+		updated.Payload = stored.Payload
+		updated.CreatedAt = stored.CreatedAt
+		r.deliveries[i] = updated
+		return nil
+	}
+	return fmt.Errorf("webhook delivery %s not found", d.ID)
+}
+
+// FindWebhookDeliveries retrieves the deliveries of a subscription, newest
+// first.
+func (r *MemoryRepository) FindWebhookDeliveries(ctx context.Context, subscriptionID string) ([]*student.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := make([]*student.WebhookDelivery, 0)
+	for _, d := range slices.Backward(r.deliveries) {
+		if d.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, d.Clone())
+		}
+	}
+	return deliveries, nil
+}
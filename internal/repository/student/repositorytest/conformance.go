@@ -0,0 +1,1341 @@
+// Package repositorytest provides a conformance suite that every
+// implementation of the student Repository interface must pass.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/internal/domain/student"
+	studentrepo "todo/internal/repository/student"
+)
+
+// Factory returns a new, empty Repository for a single test case.
+// Implementations should register any cleanup with t.Cleanup.
+type Factory func(t *testing.T) studentrepo.Repository
+
+// RunConformance runs the Repository contract against repositories built
+// by factory. Each case receives a fresh repository.
+func RunConformance(t *testing.T, factory Factory) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, repo studentrepo.Repository)
+	}{
+		{"SaveAndFind", testSaveAndFind},
+		{"SaveDuplicateStudentNumber", testSaveDuplicate},
+		{"FindMissing", testFindMissing},
+		{"NoAliasing", testNoAliasing},
+		{"FindByID", testFindByID},
+		{"FindByIDMissing", testFindByIDMissing},
+		{"FindAllEmpty", testFindAllEmpty},
+		{"FindAll", testFindAll},
+		{"ListFilters", testListFilters},
+		{"ListSearchFoldsUnicodeCase", testListSearchUnicode},
+		{"ListSort", testListSort},
+		{"ListPagination", testListPagination},
+		{"ListInvalidPageToken", testListInvalidPageToken},
+		{"Update", testUpdate},
+		{"UpdateMissing", testUpdateMissing},
+		{"UpdateChangesStudentNumber", testUpdateRekey},
+		{"UpdateToTakenStudentNumber", testUpdateRekeyConflict},
+		{"UpdateStaleVersion", testUpdateStaleVersion},
+		{"Trash", testTrash},
+		{"TrashStaleVersion", testTrashStaleVersion},
+		{"TrashedNumberReusable", testTrashedNumberReusable},
+		{"FindDeletedMissing", testFindDeletedMissing},
+		{"ListTrash", testListTrash},
+		{"Restore", testRestore},
+		{"RestoreToTakenStudentNumber", testRestoreConflict},
+		{"Purge", testPurge},
+		{"ExistsByStudentNumber", testExists},
+		{"ConcurrentSaveSameStudentNumber", testConcurrentSaveSame},
+		{"ConcurrentSaveDistinct", testConcurrentSaveDistinct},
+		{"CancelledContext", testCancelledContext},
+		{"WithinTxCommits", testWithinTxCommits},
+		{"WithinTxRollsBack", testWithinTxRollsBack},
+		{"WithinTxNested", testWithinTxNested},
+		{"FindAsOf", testFindAsOf},
+		{"FindAsOfReusedStudentNumber", testFindAsOfReused},
+		{"FindVersion", testFindVersion},
+		{"VersionsWithinTxRollsBack", testVersionsRollback},
+		{"PurgeRemovesVersions", testPurgeVersions},
+		{"AuditTrail", testAuditTrail},
+		{"AuditTrailOutlivesPurge", testAuditTrailOutlivesPurge},
+		{"AuditTrailWithinTxRollsBack", testAuditTrailRollback},
+		{"OutboxPending", testOutboxPending},
+		{"OutboxUpdate", testOutboxUpdate},
+		{"OutboxWithinTxRollsBack", testOutboxRollback},
+		{"Changes", testChanges},
+		{"ChangesTombstonesOutlivePurge", testChangesTombstones},
+		{"ChangesWithinTxRollsBack", testChangesRollback},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, factory(t))
+		})
+	}
+}
+
+// NewStudent returns a valid student fixture with a fresh ID and the given
+// student number.
+func NewStudent(studentNumber string) *student.Student {
+	grade := 1
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	return &student.Student{
+		ID:            uuid.New().String(),
+		StudentNumber: studentNumber,
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+		Grade:         &grade,
+		Version:       student.InitialVersion,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// AssertStudentEqual compares two students field by field, treating
+// timestamps as equal when they denote the same instant.
+func AssertStudentEqual(t *testing.T, want, got *student.Student) {
+	t.Helper()
+	require.NotNil(t, got)
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.StudentNumber, got.StudentNumber)
+	assert.Equal(t, want.Name, got.Name)
+	assert.Equal(t, want.Email, got.Email)
+	assert.Equal(t, want.Class, got.Class)
+	assert.Equal(t, want.Grade, got.Grade)
+	assert.Equal(t, want.Version, got.Version)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "created_at: want %v, got %v", want.CreatedAt, got.CreatedAt)
+	assert.True(t, want.UpdatedAt.Equal(got.UpdatedAt), "updated_at: want %v, got %v", want.UpdatedAt, got.UpdatedAt)
+	if assert.Equal(t, want.IsDeleted(), got.IsDeleted(), "deleted_at") && want.IsDeleted() {
+		assert.True(t, want.DeletedAt.Equal(*got.DeletedAt), "deleted_at: want %v, got %v", want.DeletedAt, got.DeletedAt)
+	}
+}
+
+// Trashed returns a copy of s moved to the trash at deletedAt, with the
+// version bumped as the use case does.
+func Trashed(s *student.Student, deletedAt time.Time) *student.Student {
+	c := s.Clone()
+	deletedAt = deletedAt.UTC().Truncate(time.Microsecond)
+	c.DeletedAt = &deletedAt
+	c.Version++
+	return c
+}
+
+// Changed returns a copy of s updated by change at the given time, with
+// the version bumped as the use case does.
+func Changed(s *student.Student, at time.Time, change func(c *student.Student)) *student.Student {
+	c := s.Clone()
+	change(c)
+	c.UpdatedAt = at.UTC().Truncate(time.Microsecond)
+	c.Version++
+	return c
+}
+
+// NewAuditEntry returns an audit entry fixture recording action on s.
+func NewAuditEntry(s *student.Student, action student.AuditAction) *student.AuditEntry {
+	return &student.AuditEntry{
+		ID:        uuid.New().String(),
+		StudentID: s.ID,
+		Action:    action,
+		Actor:     "admin",
+		RequestID: uuid.New().String(),
+		Version:   s.Version,
+		Timestamp: s.UpdatedAt,
+		Changes:   student.Diff(nil, s),
+	}
+}
+
+// AssertAuditEntriesEqual compares two audit trails entry by entry,
+// treating timestamps as equal when they denote the same instant.
+func AssertAuditEntriesEqual(t *testing.T, want, got []*student.AuditEntry) {
+	t.Helper()
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, want[i].Timestamp.Equal(got[i].Timestamp), "timestamp: want %v, got %v", want[i].Timestamp, got[i].Timestamp)
+		w, g := *want[i], *got[i]
+		w.Timestamp, g.Timestamp = time.Time{}, time.Time{}
+		assert.Equal(t, w, g)
+	}
+}
+
+// NewOutboxMessage returns a pending outbox message fixture announcing the
+// creation of s, due at the given time.
+func NewOutboxMessage(s *student.Student, due time.Time) *student.OutboxMessage {
+	m, err := student.NewOutboxMessage(student.NewEvent(NewAuditEntry(s, student.AuditCreated), s))
+	if err != nil {
+		panic(err)
+	}
+	m.NextAttemptAt = due.UTC().Truncate(time.Microsecond)
+	return m
+}
+
+// AssertOutboxMessageEqual compares two outbox messages field by field,
+// treating timestamps as equal when they denote the same instant.
+func AssertOutboxMessageEqual(t *testing.T, want, got *student.OutboxMessage) {
+	t.Helper()
+	require.NotNil(t, got)
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.EventType, got.EventType)
+	assert.Equal(t, want.StudentID, got.StudentID)
+	assert.JSONEq(t, string(want.Payload), string(got.Payload))
+	assert.Equal(t, want.Status, got.Status)
+	assert.Equal(t, want.Attempts, got.Attempts)
+	assert.Equal(t, want.LastError, got.LastError)
+	assert.ElementsMatch(t, want.DeliveredTo, got.DeliveredTo, "delivered_to")
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "created_at: want %v, got %v", want.CreatedAt, got.CreatedAt)
+	assert.True(t, want.NextAttemptAt.Equal(got.NextAttemptAt), "next_attempt_at: want %v, got %v", want.NextAttemptAt, got.NextAttemptAt)
+	if assert.Equal(t, want.DeliveredAt != nil, got.DeliveredAt != nil, "delivered_at") && want.DeliveredAt != nil {
+		assert.True(t, want.DeliveredAt.Equal(*got.DeliveredAt), "delivered_at: want %v, got %v", want.DeliveredAt, got.DeliveredAt)
+	}
+}
+
+// AssertErrorType asserts that err is a StudentError of the given type.
+func AssertErrorType(t *testing.T, want student.ErrorType, err error) {
+	t.Helper()
+	var studentErr *student.StudentError
+	require.ErrorAs(t, err, &studentErr)
+	assert.Equal(t, want, studentErr.Type)
+}
+
+func testSaveAndFind(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	s := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, s))
+
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, s, found)
+}
+
+func testSaveDuplicate(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, NewStudent("2024001")))
+
+	dup := NewStudent("2024001")
+	dup.ID = "another-id"
+	dup.Name = "另一個學生"
+	AssertErrorType(t, student.ErrorTypeStudentNumberAlreadyExists, repo.Save(ctx, dup))
+
+	// The original record must be left untouched.
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.Equal(t, "王小明", found.Name)
+}
+
+func testNoAliasing(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	saved := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, saved))
+
+	// Mutating the value passed to Save must not reach the stored record.
+	saved.Name = "changed after save"
+	*saved.Grade = 6
+
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.Equal(t, "王小明", found.Name)
+	assert.Equal(t, 1, *found.Grade)
+
+	// Mutating a returned record must not reach the stored record either.
+	found.Name = "changed after find"
+	*found.Grade = 5
+	all, err := repo.FindAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	all[0].Class = "changed after find all"
+
+	again, err := repo.FindByID(ctx, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "王小明", again.Name)
+	assert.Equal(t, "一年一班", again.Class)
+	assert.Equal(t, 1, *again.Grade)
+}
+
+func testFindMissing(t *testing.T, repo studentrepo.Repository) {
+	_, err := repo.FindByStudentNumber(context.Background(), "9999999")
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+}
+
+func testFindByID(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	s := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, s))
+
+	found, err := repo.FindByID(ctx, s.ID)
+	require.NoError(t, err)
+	AssertStudentEqual(t, s, found)
+
+	// The ID keeps resolving after the student number changes.
+	renamed := *s
+	renamed.StudentNumber = "2024999"
+	require.NoError(t, repo.Update(ctx, &renamed, student.InitialVersion))
+
+	found, err = repo.FindByID(ctx, s.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "2024999", found.StudentNumber)
+}
+
+func testFindByIDMissing(t *testing.T, repo studentrepo.Repository) {
+	_, err := repo.FindByID(context.Background(), "missing-id")
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+}
+
+func testFindAllEmpty(t *testing.T, repo studentrepo.Repository) {
+	students, err := repo.FindAll(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, students)
+	assert.Empty(t, students)
+}
+
+func testFindAll(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, repo.Save(ctx, NewStudent(fmt.Sprintf("202400%d", i))))
+	}
+
+	students, err := repo.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, students, 5)
+}
+
+// seedRoster saves a small roster covering every filter and sort field.
+func seedRoster(t *testing.T, repo studentrepo.Repository) {
+	t.Helper()
+	roster := []struct {
+		number, name, email, class string
+		grade                      int
+	}{
+		{"2024001", "王小明", "wang@school.edu", "一年一班", 1},
+		{"2024002", "李小華", "lee@school.edu", "一年一班", 1},
+		{"2024003", "陳大文", "chen@school.edu", "一年二班", 1},
+		{"2024004", "Alice Wang", "alice@school.edu", "二年一班", 2},
+		{"2024005", "Bob", "bob@school.edu", "二年一班", 0},
+	}
+
+	base := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i, r := range roster {
+		s := NewStudent(r.number)
+		s.Name, s.Email, s.Class = r.name, r.email, r.class
+		if r.grade == 0 {
+			s.Grade = nil
+		} else {
+			grade := r.grade
+			s.Grade = &grade
+		}
+		s.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		s.UpdatedAt = s.CreatedAt
+		require.NoError(t, repo.Save(context.Background(), s))
+	}
+}
+
+func studentNumbers(students []*student.Student) []string {
+	numbers := make([]string, len(students))
+	for i, s := range students {
+		numbers[i] = s.StudentNumber
+	}
+	return numbers
+}
+
+func testListFilters(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	seedRoster(t, repo)
+	grade := 1
+
+	cases := []struct {
+		name  string
+		query student.ListQuery
+		want  []string
+	}{
+		{"all", student.ListQuery{}, []string{"2024001", "2024002", "2024003", "2024004", "2024005"}},
+		{"class", student.ListQuery{Class: "一年一班"}, []string{"2024001", "2024002"}},
+		{"grade", student.ListQuery{Grade: &grade}, []string{"2024001", "2024002", "2024003"}},
+		{"class and grade", student.ListQuery{Class: "二年一班", Grade: &grade}, []string{}},
+		{"search name", student.ListQuery{Search: "小"}, []string{"2024001", "2024002"}},
+		{"search case-insensitive", student.ListQuery{Search: "WANG"}, []string{"2024001", "2024004"}},
+		{"search student number", student.ListQuery{Search: "005"}, []string{"2024005"}},
+		{"search literal wildcard", student.ListQuery{Search: "%"}, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := tc.query
+			page, err := repo.List(ctx, &q)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, studentNumbers(page.Students))
+			assert.Empty(t, page.NextPageToken)
+		})
+	}
+}
+
+func testListSearchUnicode(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	for number, name := range map[string]string{
+		"2024001": "ＷＡＮＧ Xiaoming", // full-width Latin letters
+		"2024002": "ÉMILE Dupont",
+		"2024003": "Emile Martin",
+	} {
+		s := NewStudent(number)
+		s.Name = name
+		require.NoError(t, repo.Save(ctx, s))
+	}
+
+	cases := map[string][]string{
+		"ｗａｎｇ":  {"2024001"},
+		"émile": {"2024002"},
+		"EMILE": {"2024003"},
+		"%":     {},
+	}
+	for search, want := range cases {
+		page, err := repo.List(ctx, &student.ListQuery{Search: search})
+		require.NoError(t, err)
+		assert.Equal(t, want, studentNumbers(page.Students), search)
+	}
+}
+
+func testListSort(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	seedRoster(t, repo)
+
+	cases := []struct {
+		spec string
+		want []string
+	}{
+		{"-created_at", []string{"2024005", "2024004", "2024003", "2024002", "2024001"}},
+		{"class,-student_number", []string{"2024002", "2024001", "2024003", "2024005", "2024004"}},
+		{"-grade,student_number", []string{"2024004", "2024001", "2024002", "2024003", "2024005"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.spec, func(t *testing.T) {
+			orders, err := student.ParseSort(tc.spec)
+			require.NoError(t, err)
+			page, err := repo.List(ctx, &student.ListQuery{Sort: orders})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, studentNumbers(page.Students))
+		})
+	}
+}
+
+func testListPagination(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	seedRoster(t, repo)
+
+	for _, spec := range []string{"", "-grade", "class,-created_at", "name"} {
+		t.Run("sort="+spec, func(t *testing.T) {
+			orders, err := student.ParseSort(spec)
+			require.NoError(t, err)
+
+			full, err := repo.List(ctx, &student.ListQuery{Sort: orders})
+			require.NoError(t, err)
+
+			var paged []*student.Student
+			q := &student.ListQuery{Sort: orders, PageSize: 2}
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 10, "pagination did not terminate")
+				page, err := repo.List(ctx, q)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Students), 2)
+				paged = append(paged, page.Students...)
+				if page.NextPageToken == "" {
+					break
+				}
+				q.PageToken = page.NextPageToken
+			}
+			assert.Equal(t, studentNumbers(full.Students), studentNumbers(paged))
+		})
+	}
+}
+
+func testListInvalidPageToken(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	seedRoster(t, repo)
+
+	_, err := repo.List(ctx, &student.ListQuery{PageToken: "not-a-token"})
+	AssertErrorType(t, student.ErrorTypeInvalidQueryParameter, err)
+
+	// A token issued for one sort order cannot be replayed with another.
+	page, err := repo.List(ctx, &student.ListQuery{PageSize: 2})
+	require.NoError(t, err)
+	require.NotEmpty(t, page.NextPageToken)
+
+	orders, err := student.ParseSort("-name")
+	require.NoError(t, err)
+	_, err = repo.List(ctx, &student.ListQuery{Sort: orders, PageToken: page.NextPageToken})
+	AssertErrorType(t, student.ErrorTypeInvalidQueryParameter, err)
+}
+
+func testUpdate(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, original))
+
+	grade := 2
+	changed := *original
+	changed.Email = "wang.new@school.edu"
+	changed.Class = "二年一班"
+	changed.Grade = &grade
+	changed.Version = student.InitialVersion + 1
+	changed.UpdatedAt = changed.UpdatedAt.Add(time.Minute)
+	require.NoError(t, repo.Update(ctx, &changed, student.InitialVersion))
+
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, &changed, found)
+}
+
+func testUpdateMissing(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, repo.Update(ctx, NewStudent("9999999"), student.InitialVersion))
+
+	// Update must not create the record.
+	exists, err := repo.ExistsByStudentNumber(ctx, "9999999")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testUpdateRekey(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, original))
+
+	renamed := *original
+	renamed.StudentNumber = "2024999"
+	require.NoError(t, repo.Update(ctx, &renamed, student.InitialVersion))
+
+	_, err := repo.FindByStudentNumber(ctx, "2024001")
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+
+	found, err := repo.FindByStudentNumber(ctx, "2024999")
+	require.NoError(t, err)
+	AssertStudentEqual(t, &renamed, found)
+
+	// The old student number is free again.
+	require.NoError(t, repo.Save(ctx, NewStudent("2024001")))
+}
+
+func testUpdateRekeyConflict(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	first := NewStudent("2024001")
+	second := NewStudent("2024002")
+	require.NoError(t, repo.Save(ctx, first))
+	require.NoError(t, repo.Save(ctx, second))
+
+	renamed := *first
+	renamed.StudentNumber = "2024002"
+	renamed.Name = "改名"
+	AssertErrorType(t, student.ErrorTypeStudentNumberAlreadyExists, repo.Update(ctx, &renamed, student.InitialVersion))
+
+	// Both records must be left untouched.
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, first, found)
+	found, err = repo.FindByStudentNumber(ctx, "2024002")
+	require.NoError(t, err)
+	AssertStudentEqual(t, second, found)
+}
+
+func testUpdateStaleVersion(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, original))
+
+	// The first writer wins and bumps the version.
+	first := *original
+	first.Name = "第一位"
+	first.Version = original.Version + 1
+	require.NoError(t, repo.Update(ctx, &first, original.Version))
+
+	// A second writer based on the same version is rejected.
+	second := *original
+	second.Name = "第二位"
+	second.Version = original.Version + 1
+	AssertErrorType(t, student.ErrorTypeVersionConflict, repo.Update(ctx, &second, original.Version))
+
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.Equal(t, "第一位", found.Name)
+	assert.Equal(t, original.Version+1, found.Version)
+}
+
+func testExists(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	exists, err := repo.ExistsByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, repo.Save(ctx, NewStudent("2024001")))
+
+	exists, err = repo.ExistsByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func testConcurrentSaveSame(t *testing.T, repo studentrepo.Repository) {
+	const workers = 16
+	ctx := context.Background()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := NewStudent("2024001")
+			s.ID = fmt.Sprintf("id-%d", i)
+			err := repo.Save(ctx, s)
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+				return
+			}
+			AssertErrorType(t, student.ErrorTypeStudentNumberAlreadyExists, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, succeeded, "exactly one concurrent Save must win")
+}
+
+func testConcurrentSaveDistinct(t *testing.T, repo studentrepo.Repository) {
+	const workers = 16
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, repo.Save(ctx, NewStudent(fmt.Sprintf("2024%03d", i))))
+		}(i)
+	}
+	wg.Wait()
+
+	students, err := repo.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, students, workers)
+}
+
+func testCancelledContext(t *testing.T, repo studentrepo.Repository) {
+	require.NoError(t, repo.Save(context.Background(), NewStudent("2024001")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, repo.Save(ctx, NewStudent("2024002")), context.Canceled)
+	_, err := repo.FindByStudentNumber(ctx, "2024001")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.FindByID(ctx, "missing-id")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.FindAll(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.List(ctx, &student.ListQuery{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.Update(ctx, NewStudent("2024001"), student.InitialVersion), context.Canceled)
+	_, err = repo.FindDeleted(ctx, "2024001")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.Purge(ctx, time.Now())
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.ExistsByStudentNumber(ctx, "2024001")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		return tx.Save(ctx, NewStudent("2024002"))
+	}), context.Canceled)
+	_, err = repo.FindAsOf(ctx, "2024001", time.Now())
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.FindVersion(ctx, "missing-id", student.InitialVersion)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.AppendAuditEntry(ctx, NewAuditEntry(NewStudent("2024001"), student.AuditCreated)), context.Canceled)
+	_, err = repo.FindAuditEntries(ctx, "missing-id")
+	assert.ErrorIs(t, err, context.Canceled)
+	message := NewOutboxMessage(NewStudent("2024001"), time.Now())
+	assert.ErrorIs(t, repo.AppendOutbox(ctx, message), context.Canceled)
+	_, err = repo.FindPendingOutbox(ctx, time.Now(), 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.UpdateOutbox(ctx, message), context.Canceled)
+	_, err = repo.FindChanges(ctx, 0, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Nothing may have been written with the cancelled context.
+	exists, err := repo.ExistsByStudentNumber(context.Background(), "2024002")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = repo.ExistsByStudentNumber(context.Background(), "2024001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func testWithinTxCommits(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	existing := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, Trashed(NewStudent("2024000"), time.Now().Add(-time.Hour))))
+	require.NoError(t, repo.Save(ctx, existing))
+
+	err := repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		if err := tx.Save(ctx, NewStudent("2024002")); err != nil {
+			return err
+		}
+
+		// Reads inside the unit see its own writes.
+		exists, err := tx.ExistsByStudentNumber(ctx, "2024002")
+		if err != nil {
+			return err
+		}
+		assert.True(t, exists)
+
+		changed := *existing
+		changed.Name = "改名"
+		changed.Version++
+		if err := tx.Update(ctx, &changed, existing.Version); err != nil {
+			return err
+		}
+		_, err = tx.Purge(ctx, time.Now())
+		return err
+	})
+	require.NoError(t, err)
+
+	students, err := repo.FindAll(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2024001", "2024002"}, studentNumbers(students))
+
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.Equal(t, "改名", found.Name)
+
+	_, err = repo.FindDeleted(ctx, "2024000")
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+}
+
+func testWithinTxRollsBack(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	existing := NewStudent("2024001")
+	other := NewStudent("2024000")
+	require.NoError(t, repo.Save(ctx, other))
+	require.NoError(t, repo.Save(ctx, existing))
+
+	err := repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		require.NoError(t, tx.Save(ctx, NewStudent("2024002")))
+
+		changed := *existing
+		changed.Name = "改名"
+		changed.Version++
+		require.NoError(t, tx.Update(ctx, &changed, existing.Version))
+		require.NoError(t, tx.Update(ctx, Trashed(other, time.Now()), other.Version))
+
+		// A failing write aborts the whole unit.
+		return tx.Save(ctx, NewStudent("2024001"))
+	})
+	AssertErrorType(t, student.ErrorTypeStudentNumberAlreadyExists, err)
+
+	students, err := repo.FindAll(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2024000", "2024001"}, studentNumbers(students))
+
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, existing, found)
+
+	// The repository remains usable after a rollback.
+	require.NoError(t, repo.Save(ctx, NewStudent("2024002")))
+}
+
+func testWithinTxNested(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	err := repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		require.NoError(t, tx.WithinTx(ctx, func(inner studentrepo.Repository) error {
+			return inner.Save(ctx, NewStudent("2024001"))
+		}))
+		exists, err := tx.ExistsByStudentNumber(ctx, "2024001")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	// Aborting the outer unit discards the writes of the inner one.
+	exists, err := repo.ExistsByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testTrash(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, original))
+
+	trashed := Trashed(original, time.Now())
+	require.NoError(t, repo.Update(ctx, trashed, original.Version))
+
+	// Trashed students are hidden from every lookup of active students.
+	_, err := repo.FindByStudentNumber(ctx, "2024001")
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+	_, err = repo.FindByID(ctx, original.ID)
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+	students, err := repo.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, students)
+	page, err := repo.List(ctx, &student.ListQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Students)
+	exists, err := repo.ExistsByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	found, err := repo.FindDeleted(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, trashed, found)
+}
+
+func testTrashStaleVersion(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	s := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, s))
+
+	AssertErrorType(t, student.ErrorTypeVersionConflict, repo.Update(ctx, Trashed(s, time.Now()), s.Version+1))
+
+	exists, err := repo.ExistsByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func testTrashedNumberReusable(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// The same student number is trashed twice.
+	first := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, first))
+	require.NoError(t, repo.Update(ctx, Trashed(first, now.Add(-time.Hour)), first.Version))
+
+	second := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, second))
+	secondTrashed := Trashed(second, now)
+	require.NoError(t, repo.Update(ctx, secondTrashed, second.Version))
+
+	// The number is free for a new student.
+	third := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, third))
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, third, found)
+
+	// FindDeleted returns the most recently trashed one.
+	found, err = repo.FindDeleted(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, secondTrashed, found)
+}
+
+func testFindDeletedMissing(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, NewStudent("2024001")))
+
+	// Active students are not in the trash.
+	_, err := repo.FindDeleted(ctx, "2024001")
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+}
+
+func testListTrash(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	seedRoster(t, repo)
+
+	for _, number := range []string{"2024002", "2024004"} {
+		s, err := repo.FindByStudentNumber(ctx, number)
+		require.NoError(t, err)
+		require.NoError(t, repo.Update(ctx, Trashed(s, time.Now()), s.Version))
+	}
+
+	page, err := repo.List(ctx, &student.ListQuery{Deleted: true, PageSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2024002"}, studentNumbers(page.Students))
+	require.NotEmpty(t, page.NextPageToken)
+
+	page, err = repo.List(ctx, &student.ListQuery{Deleted: true, PageSize: 1, PageToken: page.NextPageToken})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2024004"}, studentNumbers(page.Students))
+	assert.Empty(t, page.NextPageToken)
+
+	page, err = repo.List(ctx, &student.ListQuery{})
+	require.NoError(t, err)
+	assert.NotContains(t, studentNumbers(page.Students), "2024002")
+	assert.NotContains(t, studentNumbers(page.Students), "2024004")
+}
+
+func testRestore(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, original))
+	trashed := Trashed(original, time.Now())
+	require.NoError(t, repo.Update(ctx, trashed, original.Version))
+
+	restored := trashed.Clone()
+	restored.DeletedAt = nil
+	restored.Version++
+	require.NoError(t, repo.Update(ctx, restored, trashed.Version))
+
+	found, err := repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, restored, found)
+
+	_, err = repo.FindDeleted(ctx, "2024001")
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+}
+
+func testRestoreConflict(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, original))
+	trashed := Trashed(original, time.Now())
+	require.NoError(t, repo.Update(ctx, trashed, original.Version))
+
+	// The number was reused while the student was in the trash.
+	reused := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, reused))
+
+	restored := trashed.Clone()
+	restored.DeletedAt = nil
+	restored.Version++
+	AssertErrorType(t, student.ErrorTypeStudentNumberAlreadyExists, repo.Update(ctx, restored, trashed.Version))
+
+	found, err := repo.FindDeleted(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, trashed, found)
+	found, err = repo.FindByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	AssertStudentEqual(t, reused, found)
+}
+
+func testPurge(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	now := time.Now()
+
+	old := NewStudent("2024001")
+	recent := NewStudent("2024002")
+	active := NewStudent("2024003")
+	for _, s := range []*student.Student{old, recent, active} {
+		require.NoError(t, repo.Save(ctx, s))
+	}
+	require.NoError(t, repo.Update(ctx, Trashed(old, now.Add(-48*time.Hour)), old.Version))
+	require.NoError(t, repo.Update(ctx, Trashed(recent, now.Add(-time.Hour)), recent.Version))
+
+	purged, err := repo.Purge(ctx, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = repo.FindDeleted(ctx, "2024001")
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+	_, err = repo.FindDeleted(ctx, "2024002")
+	require.NoError(t, err)
+	_, err = repo.FindByStudentNumber(ctx, "2024003")
+	require.NoError(t, err)
+
+	// Purging removes the record itself, so updates no longer find it.
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, repo.Update(ctx, old, old.Version+1))
+}
+
+func testAuditTrail(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	other := NewStudent("2024002")
+
+	renamed := original.Clone()
+	renamed.Name = "改名"
+	renamed.Version++
+	renamed.UpdatedAt = renamed.UpdatedAt.Add(time.Second)
+	updated := NewAuditEntry(renamed, student.AuditUpdated)
+	updated.Changes = student.Diff(original, renamed)
+
+	want := []*student.AuditEntry{NewAuditEntry(original, student.AuditCreated), updated}
+	require.NoError(t, repo.AppendAuditEntry(ctx, want[0]))
+	require.NoError(t, repo.AppendAuditEntry(ctx, NewAuditEntry(other, student.AuditCreated)))
+	require.NoError(t, repo.AppendAuditEntry(ctx, want[1]))
+
+	entries, err := repo.FindAuditEntries(ctx, original.ID)
+	require.NoError(t, err)
+	AssertAuditEntriesEqual(t, want, entries)
+
+	// Returned entries are copies.
+	entries[1].Changes[0].After[0] = 'x'
+	entries, err = repo.FindAuditEntries(ctx, original.ID)
+	require.NoError(t, err)
+	AssertAuditEntriesEqual(t, want, entries)
+
+	entries, err = repo.FindAuditEntries(ctx, "missing-id")
+	require.NoError(t, err)
+	assert.NotNil(t, entries)
+	assert.Empty(t, entries)
+}
+
+func testAuditTrailOutlivesPurge(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	s := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, s))
+	entry := NewAuditEntry(s, student.AuditCreated)
+	require.NoError(t, repo.AppendAuditEntry(ctx, entry))
+
+	require.NoError(t, repo.Update(ctx, Trashed(s, time.Now().Add(-time.Hour)), s.Version))
+	purged, err := repo.Purge(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+
+	entries, err := repo.FindAuditEntries(ctx, s.ID)
+	require.NoError(t, err)
+	AssertAuditEntriesEqual(t, []*student.AuditEntry{entry}, entries)
+}
+
+func testAuditTrailRollback(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	s := NewStudent("2024001")
+	created := NewAuditEntry(s, student.AuditCreated)
+	require.NoError(t, repo.AppendAuditEntry(ctx, created))
+	errAbort := errors.New("abort")
+
+	err := repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		require.NoError(t, tx.AppendAuditEntry(ctx, NewAuditEntry(s, student.AuditUpdated)))
+		entries, err := tx.FindAuditEntries(ctx, s.ID)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	entries, err := repo.FindAuditEntries(ctx, s.ID)
+	require.NoError(t, err)
+	AssertAuditEntriesEqual(t, []*student.AuditEntry{created}, entries)
+
+	// Appending after a rollback must not resurrect the discarded entry.
+	deleted := NewAuditEntry(s, student.AuditDeleted)
+	deleted.Version++
+	require.NoError(t, repo.AppendAuditEntry(ctx, deleted))
+	entries, err = repo.FindAuditEntries(ctx, s.ID)
+	require.NoError(t, err)
+	AssertAuditEntriesEqual(t, []*student.AuditEntry{created, deleted}, entries)
+}
+
+func testFindAsOf(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	created := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, created))
+
+	t0 := created.UpdatedAt
+	moved := Changed(created, t0.Add(time.Hour), func(c *student.Student) { c.Class = "一年二班" })
+	require.NoError(t, repo.Update(ctx, moved, created.Version))
+	renamed := Changed(moved, t0.Add(2*time.Hour), func(c *student.Student) { c.StudentNumber = "2024999" })
+	require.NoError(t, repo.Update(ctx, renamed, moved.Version))
+	trashed := Changed(renamed, t0.Add(3*time.Hour), func(c *student.Student) {
+		deletedAt := t0.Add(3 * time.Hour)
+		c.DeletedAt = &deletedAt
+	})
+	require.NoError(t, repo.Update(ctx, trashed, renamed.Version))
+
+	cases := []struct {
+		name          string
+		studentNumber string
+		asOf          time.Time
+		want          *student.Student
+	}{
+		{"BeforeCreation", "2024001", t0.Add(-time.Second), nil},
+		{"AtCreation", "2024001", t0, created},
+		{"BeforeUpdate", "2024001", t0.Add(time.Hour - time.Second), created},
+		{"AfterUpdate", "2024001", t0.Add(time.Hour), moved},
+		{"OldNumberAfterRename", "2024001", t0.Add(2 * time.Hour), nil},
+		{"NewNumberAfterRename", "2024999", t0.Add(2 * time.Hour), renamed},
+		{"NewNumberBeforeRename", "2024999", t0.Add(time.Hour), nil},
+		{"AfterTrash", "2024999", t0.Add(3 * time.Hour), nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := repo.FindAsOf(ctx, tc.studentNumber, tc.asOf)
+			if tc.want == nil {
+				AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+				return
+			}
+			require.NoError(t, err)
+			AssertStudentEqual(t, tc.want, found)
+		})
+	}
+}
+
+func testFindAsOfReused(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	first := NewStudent("2024001")
+	first.UpdatedAt = first.UpdatedAt.Add(-2 * time.Hour)
+	require.NoError(t, repo.Save(ctx, first))
+	trashed := Changed(first, first.UpdatedAt.Add(time.Hour), func(c *student.Student) {
+		deletedAt := c.UpdatedAt.Add(time.Hour)
+		c.DeletedAt = &deletedAt
+	})
+	require.NoError(t, repo.Update(ctx, trashed, first.Version))
+	second := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, second))
+
+	// Each instant resolves to the student holding the number then.
+	found, err := repo.FindAsOf(ctx, "2024001", first.UpdatedAt.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, found.ID)
+
+	found, err = repo.FindAsOf(ctx, "2024001", second.UpdatedAt)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, found.ID)
+}
+
+func testFindVersion(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, original))
+	changed := Changed(original, time.Now(), func(c *student.Student) { c.Grade = nil })
+	require.NoError(t, repo.Update(ctx, changed, original.Version))
+	trashed := Trashed(changed, time.Now())
+	require.NoError(t, repo.Update(ctx, trashed, changed.Version))
+
+	for _, want := range []*student.Student{original, changed, trashed} {
+		found, err := repo.FindVersion(ctx, original.ID, want.Version)
+		require.NoError(t, err)
+		AssertStudentEqual(t, want, found)
+	}
+
+	_, err := repo.FindVersion(ctx, original.ID, trashed.Version+1)
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+	_, err = repo.FindVersion(ctx, "missing-id", student.InitialVersion)
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+}
+
+func testVersionsRollback(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	original := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, original))
+	errAbort := errors.New("abort")
+
+	err := repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		changed := Changed(original, time.Now(), func(c *student.Student) { c.Name = "改名" })
+		require.NoError(t, tx.Update(ctx, changed, original.Version))
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	_, err = repo.FindVersion(ctx, original.ID, original.Version+1)
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+
+	// The version discarded by the rollback can be written again.
+	changed := Changed(original, time.Now(), func(c *student.Student) { c.Name = "另一個名字" })
+	require.NoError(t, repo.Update(ctx, changed, original.Version))
+	found, err := repo.FindVersion(ctx, original.ID, changed.Version)
+	require.NoError(t, err)
+	AssertStudentEqual(t, changed, found)
+}
+
+func testPurgeVersions(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	s := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, s))
+	require.NoError(t, repo.Update(ctx, Trashed(s, time.Now().Add(-time.Hour)), s.Version))
+
+	_, err := repo.Purge(ctx, time.Now())
+	require.NoError(t, err)
+
+	_, err = repo.FindVersion(ctx, s.ID, s.Version)
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+	_, err = repo.FindAsOf(ctx, "2024001", s.UpdatedAt)
+	AssertErrorType(t, student.ErrorTypeStudentNotFound, err)
+}
+
+func testOutboxPending(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	first := NewOutboxMessage(NewStudent("2024001"), now.Add(-time.Minute))
+	later := NewOutboxMessage(NewStudent("2024002"), now.Add(time.Minute))
+	second := NewOutboxMessage(NewStudent("2024003"), now)
+	for _, m := range []*student.OutboxMessage{first, later, second} {
+		require.NoError(t, repo.AppendOutbox(ctx, m))
+	}
+	assert.Error(t, repo.AppendOutbox(ctx, first), "message IDs are unique")
+
+	// Due messages come in append order, up to the limit.
+	pending, err := repo.FindPendingOutbox(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	AssertOutboxMessageEqual(t, first, pending[0])
+	AssertOutboxMessageEqual(t, second, pending[1])
+
+	pending, err = repo.FindPendingOutbox(ctx, now, 1)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, first.ID, pending[0].ID)
+
+	pending, err = repo.FindPendingOutbox(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 3)
+}
+
+func testOutboxUpdate(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	failing := NewOutboxMessage(NewStudent("2024001"), now)
+	delivered := NewOutboxMessage(NewStudent("2024002"), now)
+	require.NoError(t, repo.AppendOutbox(ctx, failing))
+	require.NoError(t, repo.AppendOutbox(ctx, delivered))
+
+	retry := failing.Clone()
+	retry.Attempts = 1
+	retry.LastError = "sink unavailable"
+	retry.DeliveredTo = []string{"webhooks"}
+	retry.NextAttemptAt = now.Add(time.Minute)
+	require.NoError(t, repo.UpdateOutbox(ctx, retry))
+
+	done := delivered.Clone()
+	done.Status = student.OutboxDelivered
+	done.Attempts = 1
+	done.DeliveredAt = &now
+	require.NoError(t, repo.UpdateOutbox(ctx, done))
+
+	// Neither is due now: one waits for its retry, the other is done.
+	pending, err := repo.FindPendingOutbox(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	pending, err = repo.FindPendingOutbox(ctx, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	AssertOutboxMessageEqual(t, retry, pending[0])
+
+	missing := NewOutboxMessage(NewStudent("2024003"), now)
+	assert.Error(t, repo.UpdateOutbox(ctx, missing))
+}
+
+func testOutboxRollback(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	existing := NewOutboxMessage(NewStudent("2024001"), now)
+	require.NoError(t, repo.AppendOutbox(ctx, existing))
+	errAbort := errors.New("abort")
+
+	err := repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		require.NoError(t, tx.AppendOutbox(ctx, NewOutboxMessage(NewStudent("2024002"), now)))
+		dead := existing.Clone()
+		dead.Status = student.OutboxDead
+		require.NoError(t, tx.UpdateOutbox(ctx, dead))
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	pending, err := repo.FindPendingOutbox(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	AssertOutboxMessageEqual(t, existing, pending[0])
+}
+
+// changeSummaries describes changes as "type:student_number" for
+// comparison.
+func changeSummaries(changes []*student.Change) []string {
+	summaries := make([]string, len(changes))
+	for i, c := range changes {
+		summaries[i] = string(c.Type) + ":" + c.StudentNumber
+	}
+	return summaries
+}
+
+func testChanges(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	first := NewStudent("2024001")
+	second := NewStudent("2024002")
+	require.NoError(t, repo.Save(ctx, first))
+	require.NoError(t, repo.Save(ctx, second))
+
+	changes, err := repo.FindChanges(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"upsert:2024001", "upsert:2024002"}, changeSummaries(changes))
+	require.Less(t, changes[0].Sequence, changes[1].Sequence)
+	cursor := changes[1].Sequence
+
+	// Only the latest change of a student is kept, with a higher
+	// sequence, carrying the current state.
+	updated := Changed(first, time.Now(), func(c *student.Student) { c.StudentNumber = "2024009" })
+	require.NoError(t, repo.Update(ctx, updated, first.Version))
+
+	changes, err = repo.FindChanges(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"upsert:2024002", "upsert:2024009"}, changeSummaries(changes))
+
+	changes, err = repo.FindChanges(ctx, cursor, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Greater(t, changes[0].Sequence, cursor)
+	assert.Equal(t, first.ID, changes[0].StudentID)
+	AssertStudentEqual(t, updated, changes[0].Student)
+
+	changes, err = repo.FindChanges(ctx, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"upsert:2024002"}, changeSummaries(changes))
+
+	changes, err = repo.FindChanges(ctx, changes[0].Sequence+1000, 10)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func testChangesTombstones(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	s := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, s))
+	trashed := Trashed(s, time.Now().Add(-time.Hour))
+	require.NoError(t, repo.Update(ctx, trashed, s.Version))
+
+	changes, err := repo.FindChanges(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, student.ChangeDelete, changes[0].Type)
+	assert.Equal(t, s.ID, changes[0].StudentID)
+	assert.Nil(t, changes[0].Student)
+
+	// The tombstone survives the purge, so late readers still learn of
+	// the deletion.
+	n, err := repo.Purge(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	purged, err := repo.FindChanges(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	assert.Equal(t, *changes[0], *purged[0])
+
+	// A new student gets a higher sequence than every earlier change.
+	require.NoError(t, repo.Save(ctx, NewStudent("2024001")))
+	changes, err = repo.FindChanges(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"delete:2024001", "upsert:2024001"}, changeSummaries(changes))
+}
+
+func testChangesRollback(t *testing.T, repo studentrepo.Repository) {
+	ctx := context.Background()
+	existing := NewStudent("2024001")
+	require.NoError(t, repo.Save(ctx, existing))
+	errAbort := errors.New("abort")
+
+	err := repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		require.NoError(t, tx.Save(ctx, NewStudent("2024002")))
+		require.NoError(t, tx.Update(ctx, Trashed(existing, time.Now()), existing.Version))
+
+		// The unit sees its own changes.
+		changes, err := tx.FindChanges(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"upsert:2024002", "delete:2024001"}, changeSummaries(changes))
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	changes, err := repo.FindChanges(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"upsert:2024001"}, changeSummaries(changes))
+}
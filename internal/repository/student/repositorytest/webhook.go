@@ -0,0 +1,285 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/internal/domain/student"
+	studentrepo "todo/internal/repository/student"
+)
+
+// WebhookFactory returns a new, empty WebhookRepository for a single test
+// case. Implementations should register any cleanup with t.Cleanup.
+type WebhookFactory func(t *testing.T) studentrepo.WebhookRepository
+
+// RunWebhookConformance runs the WebhookRepository contract against
+// repositories built by factory. Each case receives a fresh repository.
+func RunWebhookConformance(t *testing.T, factory WebhookFactory) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, repo studentrepo.WebhookRepository)
+	}{
+		{"SaveAndFind", testWebhookSaveAndFind},
+		{"Delete", testWebhookDelete},
+		{"EnqueueIsIdempotent", testWebhookEnqueueIdempotent},
+		{"EnqueueForMissingWebhook", testWebhookEnqueueMissing},
+		{"DueDeliveries", testWebhookDueDeliveries},
+		{"UpdateDelivery", testWebhookUpdateDelivery},
+		{"CancelledContext", testWebhookCancelledContext},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, factory(t))
+		})
+	}
+}
+
+// NewWebhook returns a webhook subscription fixture for the given URL,
+// without filters.
+func NewWebhook(url string) *student.WebhookSubscription {
+	return &student.WebhookSubscription{
+		ID:         uuid.New().String(),
+		URL:        url,
+		Secret:     "secret-" + uuid.New().String(),
+		EventTypes: []student.EventType{},
+		Classes:    []string{},
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+// NewWebhookDelivery returns a pending delivery fixture of the outbox
+// message m to the subscription s, due at the given time.
+func NewWebhookDelivery(s *student.WebhookSubscription, m *student.OutboxMessage, due time.Time) *student.WebhookDelivery {
+	return &student.WebhookDelivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: s.ID,
+		EventID:        m.ID,
+		EventType:      m.EventType,
+		Payload:        m.Payload,
+		Status:         student.WebhookDeliveryPending,
+		NextAttemptAt:  due.UTC().Truncate(time.Microsecond),
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+// AssertWebhookEqual compares two subscriptions field by field, treating
+// timestamps as equal when they denote the same instant.
+func AssertWebhookEqual(t *testing.T, want, got *student.WebhookSubscription) {
+	t.Helper()
+	require.NotNil(t, got)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "created_at: want %v, got %v", want.CreatedAt, got.CreatedAt)
+	w, g := *want, *got
+	w.CreatedAt, g.CreatedAt = time.Time{}, time.Time{}
+	assert.Equal(t, w, g)
+}
+
+// AssertWebhookDeliveryEqual compares two deliveries field by field,
+// treating timestamps as equal when they denote the same instant.
+func AssertWebhookDeliveryEqual(t *testing.T, want, got *student.WebhookDelivery) {
+	t.Helper()
+	require.NotNil(t, got)
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.SubscriptionID, got.SubscriptionID)
+	assert.Equal(t, want.EventID, got.EventID)
+	assert.Equal(t, want.EventType, got.EventType)
+	assert.JSONEq(t, string(want.Payload), string(got.Payload))
+	assert.Equal(t, want.Status, got.Status)
+	assert.Equal(t, want.Attempts, got.Attempts)
+	assert.Equal(t, want.LastError, got.LastError)
+	assert.Equal(t, want.ResponseStatus, got.ResponseStatus)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "created_at: want %v, got %v", want.CreatedAt, got.CreatedAt)
+	assert.True(t, want.NextAttemptAt.Equal(got.NextAttemptAt), "next_attempt_at: want %v, got %v", want.NextAttemptAt, got.NextAttemptAt)
+	if assert.Equal(t, want.DeliveredAt != nil, got.DeliveredAt != nil, "delivered_at") && want.DeliveredAt != nil {
+		assert.True(t, want.DeliveredAt.Equal(*got.DeliveredAt), "delivered_at: want %v, got %v", want.DeliveredAt, got.DeliveredAt)
+	}
+}
+
+func testWebhookSaveAndFind(t *testing.T, repo studentrepo.WebhookRepository) {
+	ctx := context.Background()
+	first := NewWebhook("https://a.example.com/hook")
+	second := NewWebhook("https://b.example.com/hook")
+	second.EventTypes = []student.EventType{student.EventStudentCreated, student.EventStudentDeleted}
+	second.Classes = []string{"一年一班"}
+	require.NoError(t, repo.SaveWebhook(ctx, first))
+	require.NoError(t, repo.SaveWebhook(ctx, second))
+	assert.Error(t, repo.SaveWebhook(ctx, first), "webhook IDs are unique")
+
+	got, err := repo.FindWebhook(ctx, second.ID)
+	require.NoError(t, err)
+	AssertWebhookEqual(t, second, got)
+
+	// Callers must not alias stored state.
+	got.Classes[0] = "changed"
+	got, err = repo.FindWebhook(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"一年一班"}, got.Classes)
+
+	all, err := repo.FindAllWebhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	AssertWebhookEqual(t, first, all[0])
+	AssertWebhookEqual(t, second, all[1])
+
+	_, err = repo.FindWebhook(ctx, "missing-id")
+	AssertErrorType(t, student.ErrorTypeWebhookNotFound, err)
+}
+
+func testWebhookDelete(t *testing.T, repo studentrepo.WebhookRepository) {
+	ctx := context.Background()
+	now := time.Now()
+	deleted := NewWebhook("https://a.example.com/hook")
+	kept := NewWebhook("https://b.example.com/hook")
+	require.NoError(t, repo.SaveWebhook(ctx, deleted))
+	require.NoError(t, repo.SaveWebhook(ctx, kept))
+	m := NewOutboxMessage(NewStudent("2024001"), now)
+	require.NoError(t, repo.EnqueueWebhookDelivery(ctx, NewWebhookDelivery(deleted, m, now)))
+	require.NoError(t, repo.EnqueueWebhookDelivery(ctx, NewWebhookDelivery(kept, m, now)))
+
+	require.NoError(t, repo.DeleteWebhook(ctx, deleted.ID))
+	AssertErrorType(t, student.ErrorTypeWebhookNotFound, repo.DeleteWebhook(ctx, deleted.ID))
+
+	// The deliveries of the deleted subscription go with it.
+	_, err := repo.FindWebhook(ctx, deleted.ID)
+	AssertErrorType(t, student.ErrorTypeWebhookNotFound, err)
+	deliveries, err := repo.FindWebhookDeliveries(ctx, deleted.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+	due, err := repo.FindDueWebhookDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, kept.ID, due[0].SubscriptionID)
+}
+
+func testWebhookEnqueueIdempotent(t *testing.T, repo studentrepo.WebhookRepository) {
+	ctx := context.Background()
+	now := time.Now()
+	webhook := NewWebhook("https://a.example.com/hook")
+	require.NoError(t, repo.SaveWebhook(ctx, webhook))
+	m := NewOutboxMessage(NewStudent("2024001"), now)
+	first := NewWebhookDelivery(webhook, m, now)
+	require.NoError(t, repo.EnqueueWebhookDelivery(ctx, first))
+
+	// The same event announced again keeps the original delivery.
+	require.NoError(t, repo.EnqueueWebhookDelivery(ctx, NewWebhookDelivery(webhook, m, now)))
+
+	deliveries, err := repo.FindWebhookDeliveries(ctx, webhook.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	AssertWebhookDeliveryEqual(t, first, deliveries[0])
+}
+
+func testWebhookEnqueueMissing(t *testing.T, repo studentrepo.WebhookRepository) {
+	now := time.Now()
+	missing := NewWebhook("https://a.example.com/hook")
+	d := NewWebhookDelivery(missing, NewOutboxMessage(NewStudent("2024001"), now), now)
+
+	err := repo.EnqueueWebhookDelivery(context.Background(), d)
+	AssertErrorType(t, student.ErrorTypeWebhookNotFound, err)
+}
+
+func testWebhookDueDeliveries(t *testing.T, repo studentrepo.WebhookRepository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	webhook := NewWebhook("https://a.example.com/hook")
+	require.NoError(t, repo.SaveWebhook(ctx, webhook))
+	first := NewWebhookDelivery(webhook, NewOutboxMessage(NewStudent("2024001"), now), now.Add(-time.Minute))
+	later := NewWebhookDelivery(webhook, NewOutboxMessage(NewStudent("2024002"), now), now.Add(time.Minute))
+	second := NewWebhookDelivery(webhook, NewOutboxMessage(NewStudent("2024003"), now), now)
+	for _, d := range []*student.WebhookDelivery{first, later, second} {
+		require.NoError(t, repo.EnqueueWebhookDelivery(ctx, d))
+	}
+
+	// Due deliveries come in enqueue order, up to the limit.
+	due, err := repo.FindDueWebhookDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	AssertWebhookDeliveryEqual(t, first, due[0])
+	AssertWebhookDeliveryEqual(t, second, due[1])
+
+	due, err = repo.FindDueWebhookDeliveries(ctx, now, 1)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, first.ID, due[0].ID)
+
+	// The delivery log is newest first.
+	log, err := repo.FindWebhookDeliveries(ctx, webhook.ID)
+	require.NoError(t, err)
+	require.Len(t, log, 3)
+	assert.Equal(t, []string{second.ID, later.ID, first.ID}, []string{log[0].ID, log[1].ID, log[2].ID})
+}
+
+func testWebhookUpdateDelivery(t *testing.T, repo studentrepo.WebhookRepository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	webhook := NewWebhook("https://a.example.com/hook")
+	require.NoError(t, repo.SaveWebhook(ctx, webhook))
+	failing := NewWebhookDelivery(webhook, NewOutboxMessage(NewStudent("2024001"), now), now)
+	succeeded := NewWebhookDelivery(webhook, NewOutboxMessage(NewStudent("2024002"), now), now)
+	require.NoError(t, repo.EnqueueWebhookDelivery(ctx, failing))
+	require.NoError(t, repo.EnqueueWebhookDelivery(ctx, succeeded))
+
+	retry := failing.Clone()
+	retry.Attempts = 1
+	retry.LastError = "unexpected status 503"
+	retry.ResponseStatus = 503
+	retry.NextAttemptAt = now.Add(time.Minute)
+	require.NoError(t, repo.UpdateWebhookDelivery(ctx, retry))
+
+	done := succeeded.Clone()
+	done.Status = student.WebhookDeliverySucceeded
+	done.Attempts = 1
+	done.ResponseStatus = 204
+	done.DeliveredAt = &now
+	require.NoError(t, repo.UpdateWebhookDelivery(ctx, done))
+
+	// Neither is due now: one waits for its retry, the other is done.
+	due, err := repo.FindDueWebhookDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	log, err := repo.FindWebhookDeliveries(ctx, webhook.ID)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	AssertWebhookDeliveryEqual(t, done, log[0])
+	AssertWebhookDeliveryEqual(t, retry, log[1])
+
+	missing := NewWebhookDelivery(webhook, NewOutboxMessage(NewStudent("2024003"), now), now)
+	assert.Error(t, repo.UpdateWebhookDelivery(ctx, missing))
+}
+
+func testWebhookCancelledContext(t *testing.T, repo studentrepo.WebhookRepository) {
+	webhook := NewWebhook("https://a.example.com/hook")
+	require.NoError(t, repo.SaveWebhook(context.Background(), webhook))
+	now := time.Now()
+	d := NewWebhookDelivery(webhook, NewOutboxMessage(NewStudent("2024001"), now), now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, repo.SaveWebhook(ctx, NewWebhook("https://b.example.com/hook")), context.Canceled)
+	_, err := repo.FindWebhook(ctx, webhook.ID)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = repo.FindAllWebhooks(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.DeleteWebhook(ctx, webhook.ID), context.Canceled)
+	assert.ErrorIs(t, repo.EnqueueWebhookDelivery(ctx, d), context.Canceled)
+	_, err = repo.FindDueWebhookDeliveries(ctx, now, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.UpdateWebhookDelivery(ctx, d), context.Canceled)
+	_, err = repo.FindWebhookDeliveries(ctx, webhook.ID)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Nothing may have been written with the cancelled context.
+	all, err := repo.FindAllWebhooks(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+	deliveries, err := repo.FindWebhookDeliveries(context.Background(), webhook.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
@@ -0,0 +1,1023 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"todo/internal/domain/student"
+)
+
+// sqliteMigrations holds the schema changes applied in order by
+// NewSQLiteRepository. Append new statements; never edit existing ones.
+var sqliteMigrations = []string{
+	`CREATE TABLE students (
+		id             TEXT PRIMARY KEY,
+		student_number TEXT NOT NULL UNIQUE,
+		name           TEXT NOT NULL,
+		email          TEXT NOT NULL,
+		class          TEXT NOT NULL,
+		grade          INTEGER,
+		created_at     TEXT NOT NULL,
+		updated_at     TEXT NOT NULL
+	)`,
+	`ALTER TABLE students ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	// Soft delete: student numbers only need to be unique among active
+	// students, which requires rebuilding the table without the column
+	// constraint.
+	`CREATE TABLE students_new (
+		id             TEXT PRIMARY KEY,
+		student_number TEXT NOT NULL,
+		name           TEXT NOT NULL,
+		email          TEXT NOT NULL,
+		class          TEXT NOT NULL,
+		grade          INTEGER,
+		created_at     TEXT NOT NULL,
+		updated_at     TEXT NOT NULL,
+		version        INTEGER NOT NULL DEFAULT 1,
+		deleted_at     TEXT
+	);
+	INSERT INTO students_new (id, student_number, name, email, class, grade, created_at, updated_at, version)
+		SELECT id, student_number, name, email, class, grade, created_at, updated_at, version FROM students;
+	DROP TABLE students;
+	ALTER TABLE students_new RENAME TO students;
+	CREATE UNIQUE INDEX students_active_student_number ON students (student_number) WHERE deleted_at IS NULL;
+	CREATE INDEX students_deleted_at ON students (deleted_at) WHERE deleted_at IS NOT NULL`,
+	// The audit trail has no foreign key: it outlives purged students.
+	`CREATE TABLE student_audit (
+		id         TEXT PRIMARY KEY,
+		student_id TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		actor      TEXT NOT NULL,
+		request_id TEXT NOT NULL,
+		version    INTEGER NOT NULL,
+		timestamp  TEXT NOT NULL,
+		changes    TEXT NOT NULL
+	);
+	CREATE INDEX student_audit_student_id ON student_audit (student_id, version)`,
+	// Immutable versions of every student, seeded with the current state.
+	`CREATE TABLE student_versions (
+		id             TEXT NOT NULL,
+		version        INTEGER NOT NULL,
+		student_number TEXT NOT NULL,
+		name           TEXT NOT NULL,
+		email          TEXT NOT NULL,
+		class          TEXT NOT NULL,
+		grade          INTEGER,
+		created_at     TEXT NOT NULL,
+		updated_at     TEXT NOT NULL,
+		deleted_at     TEXT,
+		PRIMARY KEY (id, version)
+	);
+	INSERT INTO student_versions (id, version, student_number, name, email, class, grade, created_at, updated_at, deleted_at)
+		SELECT id, version, student_number, name, email, class, grade, created_at, updated_at, deleted_at FROM students;
+	CREATE INDEX student_versions_student_number ON student_versions (student_number, updated_at)`,
+	// seq preserves the append order of outbox messages.
+	`CREATE TABLE student_outbox (
+		seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+		id              TEXT NOT NULL UNIQUE,
+		event_type      TEXT NOT NULL,
+		student_id      TEXT NOT NULL,
+		payload         TEXT NOT NULL,
+		created_at      TEXT NOT NULL,
+		status          TEXT NOT NULL,
+		attempts        INTEGER NOT NULL,
+		next_attempt_at TEXT NOT NULL,
+		last_error      TEXT NOT NULL,
+		delivered_at    TEXT
+	);
+	CREATE INDEX student_outbox_pending ON student_outbox (status, next_attempt_at)`,
+	// Webhook filters are stored as JSON arrays; seq preserves the order
+	// subscriptions and deliveries were added in.
+	`CREATE TABLE webhooks (
+		seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+		id          TEXT NOT NULL UNIQUE,
+		url         TEXT NOT NULL,
+		secret      TEXT NOT NULL,
+		event_types TEXT NOT NULL,
+		classes     TEXT NOT NULL,
+		created_at  TEXT NOT NULL
+	);
+	CREATE TABLE webhook_deliveries (
+		seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+		id              TEXT NOT NULL UNIQUE,
+		webhook_id      TEXT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+		event_id        TEXT NOT NULL,
+		event_type      TEXT NOT NULL,
+		payload         TEXT NOT NULL,
+		status          TEXT NOT NULL,
+		attempts        INTEGER NOT NULL,
+		next_attempt_at TEXT NOT NULL,
+		last_error      TEXT NOT NULL,
+		response_status INTEGER NOT NULL,
+		created_at      TEXT NOT NULL,
+		delivered_at    TEXT,
+		UNIQUE (webhook_id, event_id)
+	);
+	CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+	// The change log keeps one row per student, replaced on every write;
+	// AUTOINCREMENT never reuses a sequence, even after a purge. Existing
+	// students are logged in the order they were last written.
+	`CREATE TABLE student_changes (
+		seq            INTEGER PRIMARY KEY AUTOINCREMENT,
+		student_id     TEXT NOT NULL UNIQUE,
+		student_number TEXT NOT NULL,
+		deleted        INTEGER NOT NULL
+	);
+	INSERT INTO student_changes (student_id, student_number, deleted)
+		SELECT id, student_number, deleted_at IS NOT NULL FROM students ORDER BY updated_at, id`,
+	// Students written before timestamps were fixed-width, and the
+	// versions seeded from them, hold variable-width RFC 3339 values that
+	// do not compare chronologically with sqliteTimeLayout.
+	`UPDATE students SET
+		created_at = ` + fixedWidthTime("created_at") + `,
+		updated_at = ` + fixedWidthTime("updated_at") + `,
+		deleted_at = ` + fixedWidthTime("deleted_at") + `;
+	UPDATE student_versions SET
+		created_at = ` + fixedWidthTime("created_at") + `,
+		updated_at = ` + fixedWidthTime("updated_at") + `,
+		deleted_at = ` + fixedWidthTime("deleted_at"),
+	// The names of the sinks that accepted an outbox message, as a JSON
+	// array, so that a retry skips them.
+	`ALTER TABLE student_outbox ADD COLUMN delivered_to TEXT NOT NULL DEFAULT '[]'`,
+}
+
+// fixedWidthTime returns the SQL expression padding the fraction of a
+// time.RFC3339Nano UTC timestamp in column to the nine digits of
+// sqliteTimeLayout. NULL and fixed-width values are left as they are.
+func fixedWidthTime(column string) string {
+	return fmt.Sprintf(`CASE
+		WHEN %[1]s IS NULL OR length(%[1]s) >= %[2]d THEN %[1]s
+		WHEN instr(%[1]s, '.') = 0 THEN substr(%[1]s, 1, length(%[1]s) - 1) || '.000000000Z'
+		ELSE substr(%[1]s, 1, length(%[1]s) - 1) || substr('000000000', 1, %[2]d - length(%[1]s)) || 'Z'
+	END`, column, len("2006-01-02T15:04:05.000000000Z"))
+}
+
+// sqliteTimeLayout is the layout used to store timestamps as TEXT. It is
+// fixed-width so that ORDER BY on the column is chronological; parsing
+// uses time.RFC3339Nano which also accepts rows written before that.
+const sqliteTimeLayout = sortKeyTimeLayout
+
+// sqliteSortColumns maps sort fields to the SQL expression that yields the
+// same value as sortKey.
+var sqliteSortColumns = map[student.SortField]string{
+	student.SortByStudentNumber: "student_number",
+	student.SortByName:          "name",
+	student.SortByClass:         "class",
+	student.SortByGrade:         "COALESCE(grade, 0)",
+	student.SortByCreatedAt:     "created_at",
+	student.SortByUpdatedAt:     "updated_at",
+}
+
+// sqliteStudentColumns is the column list read by scanStudent.
+const sqliteStudentColumns = `id, student_number, name, email, class, grade, version, created_at, updated_at, deleted_at`
+
+// SQLiteRepository is a SQLite implementation of Repository.
+type SQLiteRepository struct {
+	db *sql.DB    // nil for a repository bound to a transaction
+	q  sqlQuerier // db, or the transaction of WithinTx
+}
+
+// sqlQuerier is satisfied by both *sql.DB and *sql.Tx.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// fold_case exposes foldCase to SQL so that searches match exactly as in
+// the memory repository.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("fold_case", 1,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			s, _ := args[0].(string)
+			return foldCase(s), nil
+		})
+}
+
+// OpenSQLite opens the SQLite database at dsn using the pure-Go driver.
+// A single connection is kept so that ":memory:" databases are shared
+// and writers never contend for the database lock.
+func OpenSQLite(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewSQLiteRepository creates a repository backed by db and applies any
+// pending schema migrations.
+func NewSQLiteRepository(ctx context.Context, db *sql.DB) (*SQLiteRepository, error) {
+	r := &SQLiteRepository{db: db, q: db}
+	if err := r.migrate(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// migrate brings the schema up to date with sqliteMigrations.
+func (r *SQLiteRepository) migrate(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := current; i < len(sqliteMigrations); i++ {
+		version := i + 1
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save saves a new student record, its first version and its change.
+func (r *SQLiteRepository) Save(ctx context.Context, s *student.Student) error {
+	return r.inTx(ctx, func(tx sqlQuerier) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO students (id, student_number, name, email, class, grade, version, created_at, updated_at, deleted_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			s.ID, s.StudentNumber, s.Name, s.Email, s.Class, nullableGrade(s.Grade), s.Version,
+			formatTime(s.CreatedAt), formatTime(s.UpdatedAt), nullableTime(s.DeletedAt),
+		)
+		if err != nil {
+			return mapSQLiteError(err)
+		}
+		if err := insertVersion(ctx, tx, s); err != nil {
+			return err
+		}
+		return recordChange(ctx, tx, s)
+	})
+}
+
+// insertVersion keeps s as a version. A write that did not bump the
+// version replaces the version it was made at.
+func insertVersion(ctx context.Context, tx sqlQuerier, s *student.Student) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO student_versions (id, student_number, name, email, class, grade, version, created_at, updated_at, deleted_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ID, s.StudentNumber, s.Name, s.Email, s.Class, nullableGrade(s.Grade), s.Version,
+		formatTime(s.CreatedAt), formatTime(s.UpdatedAt), nullableTime(s.DeletedAt),
+	)
+	return err
+}
+
+// FindByStudentNumber retrieves an active student by student number.
+func (r *SQLiteRepository) FindByStudentNumber(ctx context.Context, studentNumber string) (*student.Student, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+sqliteStudentColumns+` FROM students WHERE student_number = ? AND deleted_at IS NULL`, studentNumber)
+	return scanStudent(row)
+}
+
+// FindByID retrieves an active student by ID.
+func (r *SQLiteRepository) FindByID(ctx context.Context, id string) (*student.Student, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+sqliteStudentColumns+` FROM students WHERE id = ? AND deleted_at IS NULL`, id)
+	return scanStudent(row)
+}
+
+// FindDeleted retrieves the most recently trashed student with the given
+// student number.
+func (r *SQLiteRepository) FindDeleted(ctx context.Context, studentNumber string) (*student.Student, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+sqliteStudentColumns+` FROM students
+		 WHERE student_number = ? AND deleted_at IS NOT NULL
+		 ORDER BY deleted_at DESC, id DESC LIMIT 1`, studentNumber)
+	return scanStudent(row)
+}
+
+// FindAsOf retrieves the version of the student that was active with the
+// given student number at asOf: among the versions valid from before
+// asOf, the latest of each student.
+func (r *SQLiteRepository) FindAsOf(ctx context.Context, studentNumber string, asOf time.Time) (*student.Student, error) {
+	at := formatTime(asOf)
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+sqliteStudentColumns+` FROM student_versions v
+		 WHERE student_number = ? AND deleted_at IS NULL AND updated_at <= ?
+		   AND version = (SELECT MAX(version) FROM student_versions WHERE id = v.id AND updated_at <= ?)
+		 ORDER BY updated_at DESC LIMIT 1`, studentNumber, at, at)
+	return scanStudent(row)
+}
+
+// FindVersion retrieves one version of a student.
+func (r *SQLiteRepository) FindVersion(ctx context.Context, id string, version int64) (*student.Student, error) {
+	row := r.q.QueryRowContext(ctx,
+		`SELECT `+sqliteStudentColumns+` FROM student_versions WHERE id = ? AND version = ?`, id, version)
+	return scanStudent(row)
+}
+
+// FindAll retrieves all active student records.
+func (r *SQLiteRepository) FindAll(ctx context.Context) ([]*student.Student, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT `+sqliteStudentColumns+` FROM students WHERE deleted_at IS NULL ORDER BY student_number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := make([]*student.Student, 0)
+	for rows.Next() {
+		s, err := scanStudent(rows)
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+	return students, rows.Err()
+}
+
+// List retrieves one page of students matching q using keyset pagination.
+func (r *SQLiteRepository) List(ctx context.Context, q *student.ListQuery) (*student.StudentPage, error) {
+	orders := effectiveSort(q)
+	cur, err := decodePageToken(q.PageToken, orders)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"deleted_at IS NULL"}
+	if q.Deleted {
+		where[0] = "deleted_at IS NOT NULL"
+	}
+	var args []any
+	if q.Class != "" {
+		where = append(where, "class = ?")
+		args = append(args, q.Class)
+	}
+	if q.Grade != nil {
+		where = append(where, "grade = ?")
+		args = append(args, *q.Grade)
+	}
+	if q.Search != "" {
+		needle := foldCase(q.Search)
+		where = append(where, `(instr(fold_case(name), ?) > 0 OR instr(fold_case(student_number), ?) > 0 OR instr(fold_case(email), ?) > 0)`)
+		args = append(args, needle, needle, needle)
+	}
+	if cur != nil {
+		clause, keyArgs := keysetClause(orders, cur)
+		where = append(where, clause)
+		args = append(args, keyArgs...)
+	}
+
+	query := `SELECT ` + sqliteStudentColumns + ` FROM students WHERE ` + strings.Join(where, " AND ")
+	terms := make([]string, 0, len(orders)+1)
+	for _, o := range orders {
+		term := sqliteSortColumns[o.Field]
+		if o.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	terms = append(terms, "id")
+	query += ` ORDER BY ` + strings.Join(terms, ", ")
+	if q.PageSize > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.PageSize+1)
+	}
+
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := make([]*student.Student, 0)
+	for rows.Next() {
+		s, err := scanStudent(rows)
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &student.StudentPage{Students: students}
+	if q.PageSize > 0 && len(students) > q.PageSize {
+		page.Students = students[:q.PageSize]
+		page.NextPageToken = encodePageToken(page.Students[q.PageSize-1], orders)
+	}
+	return page, nil
+}
+
+// keysetClause builds the predicate selecting rows after cur, i.e.
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... with "<" for descending keys.
+func keysetClause(orders []student.SortOrder, cur *pageCursor) (string, []any) {
+	var (
+		alternatives []string
+		args         []any
+	)
+	for i := 0; i <= len(orders); i++ {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, sqliteSortColumns[orders[j].Field]+" = ?")
+			args = append(args, cur.Keys[j])
+		}
+		if i < len(orders) {
+			op := " > ?"
+			if orders[i].Desc {
+				op = " < ?"
+			}
+			parts = append(parts, sqliteSortColumns[orders[i].Field]+op)
+			args = append(args, cur.Keys[i])
+		} else {
+			parts = append(parts, "id > ?")
+			args = append(args, cur.ID)
+		}
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// Update updates an existing student record identified by ID if its
+// version matches. The partial UNIQUE index on the student numbers of
+// active students guards re-keying and restoring.
+func (r *SQLiteRepository) Update(ctx context.Context, s *student.Student, expectedVersion int64) error {
+	return r.inTx(ctx, func(tx sqlQuerier) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE students SET student_number = ?, name = ?, email = ?, class = ?, grade = ?, version = ?,
+			 updated_at = ?, deleted_at = ?
+			 WHERE id = ? AND version = ?`,
+			s.StudentNumber, s.Name, s.Email, s.Class, nullableGrade(s.Grade), s.Version,
+			formatTime(s.UpdatedAt), nullableTime(s.DeletedAt),
+			s.ID, expectedVersion,
+		)
+		if err != nil {
+			return mapSQLiteError(err)
+		}
+		if err := requireVersionMatch(ctx, tx, res, `SELECT EXISTS (SELECT 1 FROM students WHERE id = ?)`, s.ID); err != nil {
+			return err
+		}
+		if err := insertVersion(ctx, tx, s); err != nil {
+			return err
+		}
+		return recordChange(ctx, tx, s)
+	})
+}
+
+// Purge permanently removes the trashed students deleted before the given
+// time.
+func (r *SQLiteRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	var purged int
+	err := r.inTx(ctx, func(tx sqlQuerier) error {
+		before := formatTime(deletedBefore)
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM student_versions WHERE id IN
+			 (SELECT id FROM students WHERE deleted_at IS NOT NULL AND deleted_at < ?)`, before); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx,
+			`DELETE FROM students WHERE deleted_at IS NOT NULL AND deleted_at < ?`, before)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		purged = int(n)
+		return err
+	})
+	return purged, err
+}
+
+// AppendAuditEntry records a change of a student. The field changes are
+// stored as a JSON array.
+func (r *SQLiteRepository) AppendAuditEntry(ctx context.Context, e *student.AuditEntry) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx,
+		`INSERT INTO student_audit (id, student_id, action, actor, request_id, version, timestamp, changes)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.StudentID, string(e.Action), e.Actor, e.RequestID, e.Version, formatTime(e.Timestamp), string(changes),
+	)
+	return err
+}
+
+// FindAuditEntries retrieves the audit trail of a student, oldest first.
+func (r *SQLiteRepository) FindAuditEntries(ctx context.Context, studentID string) ([]*student.AuditEntry, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT id, student_id, action, actor, request_id, version, timestamp, changes FROM student_audit
+		 WHERE student_id = ? ORDER BY version, timestamp, id`, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*student.AuditEntry, 0)
+	for rows.Next() {
+		var (
+			e                  student.AuditEntry
+			timestamp, changes string
+		)
+		if err := rows.Scan(&e.ID, &e.StudentID, &e.Action, &e.Actor, &e.RequestID, &e.Version,
+			&timestamp, &changes); err != nil {
+			return nil, err
+		}
+		if e.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+			return nil, fmt.Errorf("parse audit timestamp: %w", err)
+		}
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, fmt.Errorf("parse audit changes: %w", err)
+		}
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
+
+// AppendOutbox adds a message to the outbox.
+func (r *SQLiteRepository) AppendOutbox(ctx context.Context, m *student.OutboxMessage) error {
+	deliveredTo, err := json.Marshal(nonNil(m.DeliveredTo))
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx,
+		`INSERT INTO student_outbox (id, event_type, student_id, payload, created_at, status, attempts,
+		 next_attempt_at, last_error, delivered_at, delivered_to)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ID, string(m.EventType), m.StudentID, string(m.Payload), formatTime(m.CreatedAt), string(m.Status),
+		m.Attempts, formatTime(m.NextAttemptAt), m.LastError, nullableTime(m.DeliveredAt), string(deliveredTo),
+	)
+	return err
+}
+
+// FindPendingOutbox retrieves up to limit pending messages due at now.
+func (r *SQLiteRepository) FindPendingOutbox(ctx context.Context, now time.Time, limit int) ([]*student.OutboxMessage, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT id, event_type, student_id, payload, created_at, status, attempts, next_attempt_at, last_error,
+		 delivered_at, delivered_to
+		 FROM student_outbox WHERE status = ? AND next_attempt_at <= ? ORDER BY seq LIMIT ?`,
+		string(student.OutboxPending), formatTime(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*student.OutboxMessage, 0)
+	for rows.Next() {
+		var (
+			m                                              student.OutboxMessage
+			payload, createdAt, nextAttemptAt, deliveredTo string
+			deliveredAt                                    sql.NullString
+		)
+		if err := rows.Scan(&m.ID, &m.EventType, &m.StudentID, &payload, &createdAt, &m.Status, &m.Attempts,
+			&nextAttemptAt, &m.LastError, &deliveredAt, &deliveredTo); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(deliveredTo), &m.DeliveredTo); err != nil {
+			return nil, fmt.Errorf("parse outbox delivered_to: %w", err)
+		}
+		m.Payload = json.RawMessage(payload)
+		if m.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return nil, fmt.Errorf("parse outbox created_at: %w", err)
+		}
+		if m.NextAttemptAt, err = time.Parse(time.RFC3339Nano, nextAttemptAt); err != nil {
+			return nil, fmt.Errorf("parse outbox next_attempt_at: %w", err)
+		}
+		if deliveredAt.Valid {
+			t, err := time.Parse(time.RFC3339Nano, deliveredAt.String)
+			if err != nil {
+				return nil, fmt.Errorf("parse outbox delivered_at: %w", err)
+			}
+			m.DeliveredAt = &t
+		}
+		messages = append(messages, &m)
+	}
+	return messages, rows.Err()
+}
+
+// UpdateOutbox replaces the delivery state of a message.
+func (r *SQLiteRepository) UpdateOutbox(ctx context.Context, m *student.OutboxMessage) error {
+	deliveredTo, err := json.Marshal(nonNil(m.DeliveredTo))
+	if err != nil {
+		return err
+	}
+	res, err := r.q.ExecContext(ctx,
+		`UPDATE student_outbox SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, delivered_at = ?,
+		 delivered_to = ? WHERE id = ?`,
+		string(m.Status), m.Attempts, formatTime(m.NextAttemptAt), m.LastError, nullableTime(m.DeliveredAt),
+		string(deliveredTo), m.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("outbox message %s not found", m.ID)
+	}
+	return nil
+}
+
+// SaveWebhook saves a new subscription.
+func (r *SQLiteRepository) SaveWebhook(ctx context.Context, s *student.WebhookSubscription) error {
+	eventTypes, err := json.Marshal(nonNil(s.EventTypes))
+	if err != nil {
+		return err
+	}
+	classes, err := json.Marshal(nonNil(s.Classes))
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx,
+		`INSERT INTO webhooks (id, url, secret, event_types, classes, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		s.ID, s.URL, s.Secret, string(eventTypes), string(classes), formatTime(s.CreatedAt))
+	return err
+}
+
+// sqliteWebhookColumns is the column list read by scanWebhook.
+const sqliteWebhookColumns = `id, url, secret, event_types, classes, created_at`
+
+// FindWebhook retrieves a subscription by ID.
+func (r *SQLiteRepository) FindWebhook(ctx context.Context, id string) (*student.WebhookSubscription, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+sqliteWebhookColumns+` FROM webhooks WHERE id = ?`, id)
+	return scanWebhook(row)
+}
+
+// FindAllWebhooks retrieves every subscription, oldest first.
+func (r *SQLiteRepository) FindAllWebhooks(ctx context.Context) ([]*student.WebhookSubscription, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+sqliteWebhookColumns+` FROM webhooks ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]*student.WebhookSubscription, 0)
+	for rows.Next() {
+		s, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, s)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes a subscription; its deliveries go with it through
+// the foreign key.
+func (r *SQLiteRepository) DeleteWebhook(ctx context.Context, id string) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return student.NewWebhookNotFoundError()
+	}
+	return nil
+}
+
+// EnqueueWebhookDelivery adds a delivery unless one exists for the
+// subscription and event.
+func (r *SQLiteRepository) EnqueueWebhookDelivery(ctx context.Context, d *student.WebhookDelivery) error {
+	_, err := r.q.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, attempts,
+		 next_attempt_at, last_error, response_status, created_at, delivered_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (webhook_id, event_id) DO NOTHING`,
+		d.ID, d.SubscriptionID, d.EventID, string(d.EventType), string(d.Payload), string(d.Status), d.Attempts,
+		formatTime(d.NextAttemptAt), d.LastError, d.ResponseStatus, formatTime(d.CreatedAt), nullableTime(d.DeliveredAt),
+	)
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+		return student.NewWebhookNotFoundError()
+	}
+	return err
+}
+
+// sqliteDeliveryColumns is the column list read by scanWebhookDelivery.
+const sqliteDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_error, response_status, created_at, delivered_at`
+
+// FindDueWebhookDeliveries retrieves up to limit pending deliveries due at
+// now.
+func (r *SQLiteRepository) FindDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*student.WebhookDelivery, error) {
+	return r.queryWebhookDeliveries(ctx,
+		`SELECT `+sqliteDeliveryColumns+` FROM webhook_deliveries
+		 WHERE status = ? AND next_attempt_at <= ? ORDER BY seq LIMIT ?`,
+		string(student.WebhookDeliveryPending), formatTime(now), limit)
+}
+
+// UpdateWebhookDelivery replaces the delivery state of a delivery.
+func (r *SQLiteRepository) UpdateWebhookDelivery(ctx context.Context, d *student.WebhookDelivery) error {
+	res, err := r.q.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?,
+		 response_status = ?, delivered_at = ?
+		 WHERE id = ?`,
+		string(d.Status), d.Attempts, formatTime(d.NextAttemptAt), d.LastError, d.ResponseStatus,
+		nullableTime(d.DeliveredAt), d.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("webhook delivery %s not found", d.ID)
+	}
+	return nil
+}
+
+// FindWebhookDeliveries retrieves the deliveries of a subscription, newest
+// first.
+func (r *SQLiteRepository) FindWebhookDeliveries(ctx context.Context, subscriptionID string) ([]*student.WebhookDelivery, error) {
+	return r.queryWebhookDeliveries(ctx,
+		`SELECT `+sqliteDeliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? ORDER BY seq DESC`,
+		subscriptionID)
+}
+
+// queryWebhookDeliveries runs a query selecting sqliteDeliveryColumns.
+func (r *SQLiteRepository) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]*student.WebhookDelivery, error) {
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*student.WebhookDelivery, 0)
+	for rows.Next() {
+		var (
+			d                                 student.WebhookDelivery
+			payload, nextAttemptAt, createdAt string
+			deliveredAt                       sql.NullString
+		)
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&nextAttemptAt, &d.LastError, &d.ResponseStatus, &createdAt, &deliveredAt); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		if d.NextAttemptAt, err = time.Parse(time.RFC3339Nano, nextAttemptAt); err != nil {
+			return nil, fmt.Errorf("parse delivery next_attempt_at: %w", err)
+		}
+		if d.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return nil, fmt.Errorf("parse delivery created_at: %w", err)
+		}
+		if deliveredAt.Valid {
+			t, err := time.Parse(time.RFC3339Nano, deliveredAt.String)
+			if err != nil {
+				return nil, fmt.Errorf("parse delivery delivered_at: %w", err)
+			}
+			d.DeliveredAt = &t
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+// requireVersionMatch explains a conditional write that touched no rows:
+// StudentNotFound if existsQuery finds no row, VersionConflict otherwise.
+func requireVersionMatch(ctx context.Context, tx sqlQuerier, res sql.Result, existsQuery string, key string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, existsQuery, key).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return student.NewStudentNotFoundError()
+	}
+	return student.NewVersionConflictError()
+}
+
+// recordChange replaces the change log entry of s with one at the next
+// sequence.
+func recordChange(ctx context.Context, tx sqlQuerier, s *student.Student) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO student_changes (student_id, student_number, deleted) VALUES (?, ?, ?)`,
+		s.ID, s.StudentNumber, s.IsDeleted())
+	return err
+}
+
+// FindChanges retrieves up to limit changes with a sequence above since.
+// The log and the students are read in one transaction, so upserts carry
+// the state they were logged for.
+func (r *SQLiteRepository) FindChanges(ctx context.Context, since int64, limit int) ([]*student.Change, error) {
+	changes := make([]*student.Change, 0)
+	err := r.inTx(ctx, func(tx sqlQuerier) error {
+		rows, err := tx.QueryContext(ctx,
+			`SELECT seq, student_id, student_number, deleted FROM student_changes WHERE seq > ? ORDER BY seq LIMIT ?`,
+			since, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				c       student.Change
+				deleted bool
+			)
+			if err := rows.Scan(&c.Sequence, &c.StudentID, &c.StudentNumber, &deleted); err != nil {
+				return err
+			}
+			c.Type = student.ChangeUpsert
+			if deleted {
+				c.Type = student.ChangeDelete
+			}
+			changes = append(changes, &c)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, c := range changes {
+			if c.Type != student.ChangeUpsert {
+				continue
+			}
+			row := tx.QueryRowContext(ctx, `SELECT `+sqliteStudentColumns+` FROM students WHERE id = ?`, c.StudentID)
+			if c.Student, err = scanStudent(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ExistsByStudentNumber checks if a student number exists.
+func (r *SQLiteRepository) ExistsByStudentNumber(ctx context.Context, studentNumber string) (bool, error) {
+	var exists bool
+	err := r.q.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM students WHERE student_number = ? AND deleted_at IS NULL)`, studentNumber).Scan(&exists)
+	return exists, err
+}
+
+// WithinTx runs fn in a database transaction. With a single connection
+// the transaction holds the database, so units of work are serializable.
+func (r *SQLiteRepository) WithinTx(ctx context.Context, fn func(tx Repository) error) error {
+	return r.inTx(ctx, func(tx sqlQuerier) error {
+		return fn(&SQLiteRepository{q: tx})
+	})
+}
+
+// inTx runs the statements of fn atomically, in the enclosing transaction
+// if there is one and in a new one otherwise.
+func (r *SQLiteRepository) inTx(ctx context.Context, fn func(tx sqlQuerier) error) error {
+	if r.db == nil {
+		return fn(r.q)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanStudent reads one students row into a Student.
+func scanStudent(row rowScanner) (*student.Student, error) {
+	var (
+		s                    student.Student
+		grade                sql.NullInt64
+		createdAt, updatedAt string
+		deletedAt            sql.NullString
+	)
+	err := row.Scan(&s.ID, &s.StudentNumber, &s.Name, &s.Email, &s.Class, &grade, &s.Version,
+		&createdAt, &updatedAt, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, student.NewStudentNotFoundError()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if grade.Valid {
+		g := int(grade.Int64)
+		s.Grade = &g
+	}
+	if s.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	if s.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	if deletedAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, deletedAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("parse deleted_at: %w", err)
+		}
+		s.DeletedAt = &t
+	}
+	return &s, nil
+}
+
+// scanWebhook reads one webhooks row into a WebhookSubscription.
+func scanWebhook(row rowScanner) (*student.WebhookSubscription, error) {
+	var (
+		s                              student.WebhookSubscription
+		eventTypes, classes, createdAt string
+	)
+	err := row.Scan(&s.ID, &s.URL, &s.Secret, &eventTypes, &classes, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, student.NewWebhookNotFoundError()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(eventTypes), &s.EventTypes); err != nil {
+		return nil, fmt.Errorf("parse webhook event_types: %w", err)
+	}
+	if err := json.Unmarshal([]byte(classes), &s.Classes); err != nil {
+		return nil, fmt.Errorf("parse webhook classes: %w", err)
+	}
+	if s.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("parse webhook created_at: %w", err)
+	}
+	return &s, nil
+}
+
+// nonNil returns s, or an empty slice for nil so that it marshals as [].
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// mapSQLiteError translates driver errors into domain errors.
+func mapSQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+			return student.NewStudentNumberAlreadyExistsError()
+		}
+	}
+	return err
+}
+
+func nullableGrade(grade *int) sql.NullInt64 {
+	if grade == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*grade), Valid: true}
+}
+
+func nullableTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
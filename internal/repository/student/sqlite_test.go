@@ -0,0 +1,109 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/internal/domain/student"
+	studentrepo "todo/internal/repository/student"
+	"todo/internal/repository/student/repositorytest"
+)
+
+func newTestSQLiteRepository(t *testing.T) *studentrepo.SQLiteRepository {
+	t.Helper()
+	db, err := studentrepo.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	repo, err := studentrepo.NewSQLiteRepository(context.Background(), db)
+	require.NoError(t, err)
+	return repo
+}
+
+func TestSQLiteRepository_Conformance(t *testing.T) {
+	repositorytest.RunConformance(t, func(t *testing.T) studentrepo.Repository {
+		return newTestSQLiteRepository(t)
+	})
+}
+
+func TestSQLiteRepository_WebhookConformance(t *testing.T) {
+	repositorytest.RunWebhookConformance(t, func(t *testing.T) studentrepo.WebhookRepository {
+		return newTestSQLiteRepository(t)
+	})
+}
+
+func TestSQLiteRepository_MigrationsAreIdempotent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "students.db")
+
+	db, err := studentrepo.OpenSQLite(path)
+	require.NoError(t, err)
+	repo, err := studentrepo.NewSQLiteRepository(ctx, db)
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(ctx, repositorytest.NewStudent("2024001")))
+	require.NoError(t, db.Close())
+
+	// Reopening the same file must not re-run migrations or lose data.
+	db, err = studentrepo.OpenSQLite(path)
+	require.NoError(t, err)
+	defer db.Close()
+	repo, err = studentrepo.NewSQLiteRepository(ctx, db)
+	require.NoError(t, err)
+
+	exists, err := repo.ExistsByStudentNumber(ctx, "2024001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestSQLiteRepository_MigrationNormalisesTimestamps(t *testing.T) {
+	ctx := context.Background()
+	db, err := studentrepo.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Given: a database of the first schema version, whose timestamps
+	// were written as variable-width time.RFC3339Nano
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY);
+		INSERT INTO schema_migrations (version) VALUES (1);
+		CREATE TABLE students (
+			id             TEXT PRIMARY KEY,
+			student_number TEXT NOT NULL UNIQUE,
+			name           TEXT NOT NULL,
+			email          TEXT NOT NULL,
+			class          TEXT NOT NULL,
+			grade          INTEGER,
+			created_at     TEXT NOT NULL,
+			updated_at     TEXT NOT NULL
+		);
+		INSERT INTO students VALUES
+			('a', '2024001', '王小明', 'a@school.edu', '一年一班', NULL, '2024-01-02T03:04:05Z', '2024-01-02T03:04:05Z'),
+			('b', '2024002', '李小華', 'b@school.edu', '一年一班', NULL, '2024-01-02T03:04:05.5Z', '2024-01-02T03:04:05.5Z')`)
+	require.NoError(t, err)
+
+	// When: the remaining migrations are applied
+	repo, err := studentrepo.NewSQLiteRepository(ctx, db)
+	require.NoError(t, err)
+
+	// Then: timestamps compare chronologically with new ones
+	var updatedAt string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT updated_at FROM students WHERE id = 'a'`).Scan(&updatedAt))
+	assert.Equal(t, "2024-01-02T03:04:05.000000000Z", updatedAt)
+
+	sort, err := student.ParseSort("updated_at")
+	require.NoError(t, err)
+	page, err := repo.List(ctx, &student.ListQuery{Sort: sort})
+	require.NoError(t, err)
+	require.Len(t, page.Students, 2)
+	assert.Equal(t, "2024001", page.Students[0].StudentNumber)
+
+	found, err := repo.FindAsOf(ctx, "2024001", time.Date(2024, 1, 2, 3, 4, 5, 200_000_000, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "a", found.ID)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), found.UpdatedAt)
+}
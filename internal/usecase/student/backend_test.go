@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	studentrepo "todo/internal/repository/student"
+)
+
+// testStore is the persistence the use cases are tested against.
+type testStore interface {
+	studentrepo.Repository
+	studentrepo.WebhookRepository
+}
+
+// backends creates an empty store of every backend; the use cases must
+// behave the same on each.
+var backends = []struct {
+	name string
+	new  func(t *testing.T) testStore
+}{
+	{"Memory", func(*testing.T) testStore { return studentrepo.NewMemoryRepository() }},
+	{"SQLite", func(t *testing.T) testStore {
+		db, err := studentrepo.OpenSQLite(":memory:")
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		repo, err := studentrepo.NewSQLiteRepository(context.Background(), db)
+		require.NoError(t, err)
+		return repo
+	}},
+}
+
+// forEachBackend runs test as a subtest per backend with a fresh store.
+func forEachBackend(t *testing.T, test func(t *testing.T, repo testStore)) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			test(t, b.new(t))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+
+	"todo/internal/domain/student"
+)
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	requestIDKey
+)
+
+// AnonymousActor is recorded in the audit trail for changes made without
+// a principal in the context.
+const AnonymousActor = "anonymous"
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller,
+// whose subject is recorded in the audit trail of the changes made with
+// it.
+func WithPrincipal(ctx context.Context, p *student.Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFrom returns the principal carried by ctx, if any.
+func PrincipalFrom(ctx context.Context) (*student.Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*student.Principal)
+	return p, ok && p != nil
+}
+
+// WithRequestID returns a copy of ctx carrying the ID of the request the
+// changes made with it belong to.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// actorFrom returns the subject of the principal carried by ctx, or
+// AnonymousActor.
+func actorFrom(ctx context.Context) string {
+	if p, ok := PrincipalFrom(ctx); ok && p.Subject != "" {
+		return p.Subject
+	}
+	return AnonymousActor
+}
+
+// requestIDFrom returns the request ID carried by ctx, if any.
+func requestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
@@ -2,7 +2,10 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/mail"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,16 +17,48 @@ import (
 // UseCase handles all business logic for student management.
 // Satisfies scenarios from lines 5-83 of the feature specification.
 type UseCase struct {
-	repo studentrepo.Repository
+	repo   studentrepo.Repository
+	events EventPublisher
+
+	// pending collects the events of a unit of work spanning several
+	// changes, to be published once it commits; nil outside such a unit.
+	pending *[]student.Event
+}
+
+// EventPublisher receives the domain events of committed changes. It is
+// satisfied by the event bus.
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...student.Event)
+}
+
+// Option configures a UseCase.
+type Option func(*UseCase)
+
+// WithEventPublisher publishes the domain events of every change to p.
+// Without it events are discarded.
+func WithEventPublisher(p EventPublisher) Option {
+	return func(uc *UseCase) {
+		uc.events = p
+	}
 }
 
 // NewUseCase creates a new StudentUseCase.
-func NewUseCase(repo studentrepo.Repository) *UseCase {
-	return &UseCase{
-		repo: repo,
+func NewUseCase(repo studentrepo.Repository, opts ...Option) *UseCase {
+	uc := &UseCase{
+		repo:   repo,
+		events: discardEvents{},
 	}
+	for _, opt := range opts {
+		opt(uc)
+	}
+	return uc
 }
 
+// discardEvents is the EventPublisher of a UseCase without one.
+type discardEvents struct{}
+
+func (discardEvents) Publish(context.Context, ...student.Event) {}
+
 // CreateStudent creates a new student with validation.
 // Source: "我提交新學生資訊" (第 5-10 行)
 //
@@ -31,21 +66,12 @@ func NewUseCase(repo studentrepo.Repository) *UseCase {
 // When: 我提交新學生資訊，包含姓名、學號、電子郵件和班級
 // Then: 系統應該成功建立學生記錄，並返回學生 ID
 func (uc *UseCase) CreateStudent(ctx context.Context, req *student.CreateStudentRequest) (*student.Student, error) {
-	// Validate required fields (第 36-40 行)
+	// Validate required fields, email format and grade range at once
+	// (第 36-40、48-52、79-83 行)
 	if err := validateCreateRequest(req); err != nil {
 		return nil, err
 	}
 
-	// Validate email format (第 48-52 行)
-	if err := validateEmail(req.Email); err != nil {
-		return nil, err
-	}
-
-	// Validate grade if provided (第 79-83 行)
-	if req.Grade != nil && (*req.Grade < student.MinGrade || *req.Grade > student.MaxGrade) {
-		return nil, student.NewInvalidGradeError()
-	}
-
 	// Check student number uniqueness (第 42-46 行)
 	exists, err := uc.repo.ExistsByStudentNumber(ctx, req.StudentNumber)
 	if err != nil {
@@ -64,12 +90,16 @@ func (uc *UseCase) CreateStudent(ctx context.Context, req *student.CreateStudent
 		Email:         req.Email,
 		Class:         req.Class,
 		Grade:         req.Grade,
+		Version:       student.InitialVersion,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 	}
 
-	// Save to repository
-	if err := uc.repo.Save(ctx, s); err != nil {
+	// Save to repository together with its audit entry
+	err = uc.writeAudited(ctx, student.AuditCreated, nil, s, func(repo studentrepo.Repository) error {
+		return repo.Save(ctx, s)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -91,6 +121,18 @@ func (uc *UseCase) GetStudent(ctx context.Context, studentNumber string) (*stude
 	return s, nil
 }
 
+// GetStudentAsOf retrieves the student that had the given student number
+// at asOf, as it was then.
+func (uc *UseCase) GetStudentAsOf(ctx context.Context, studentNumber string, asOf time.Time) (*student.Student, error) {
+	return uc.repo.FindAsOf(ctx, studentNumber, asOf)
+}
+
+// GetStudentByID retrieves a student by its stable internal ID, which
+// survives student number changes.
+func (uc *UseCase) GetStudentByID(ctx context.Context, id string) (*student.Student, error) {
+	return uc.repo.FindByID(ctx, id)
+}
+
 // GetAllStudents retrieves all students.
 // Source: "我請求查詢所有學生" (第 18-22 行)
 //
@@ -111,6 +153,80 @@ func (uc *UseCase) GetAllStudents(ctx context.Context) ([]*student.Student, erro
 	return students, nil
 }
 
+// ListStudents retrieves one page of students matching q in a stable order.
+// A zero PageSize returns every matching student.
+func (uc *UseCase) ListStudents(ctx context.Context, q *student.ListQuery) (*student.StudentPage, error) {
+	if q.PageSize < 0 || q.PageSize > student.MaxPageSize {
+		return nil, student.NewInvalidQueryParameterError("page_size", strconv.Itoa(q.PageSize))
+	}
+
+	return uc.repo.List(ctx, q)
+}
+
+// GetChanges returns up to limit changes recorded after the since
+// cursor, oldest first, for incremental sync. A zero limit returns a full
+// page of MaxPageSize changes.
+func (uc *UseCase) GetChanges(ctx context.Context, since int64, limit int) (*student.ChangeFeed, error) {
+	if since < 0 {
+		return nil, student.NewInvalidQueryParameterError("since", strconv.FormatInt(since, 10))
+	}
+	if limit < 0 || limit > student.MaxPageSize {
+		return nil, student.NewInvalidQueryParameterError("limit", strconv.Itoa(limit))
+	}
+	if limit == 0 {
+		limit = student.MaxPageSize
+	}
+
+	// Read one change more than asked for to learn whether the page is
+	// the last one.
+	changes, err := uc.repo.FindChanges(ctx, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &student.ChangeFeed{Changes: changes, NextSince: since}
+	if len(changes) > limit {
+		feed.Changes = changes[:limit]
+		feed.HasMore = true
+	}
+	if n := len(feed.Changes); n > 0 {
+		feed.NextSince = feed.Changes[n-1].Sequence
+	} else {
+		feed.Changes = make([]*student.Change, 0)
+	}
+	return feed, nil
+}
+
+// exportPageSize is the number of students read per repository call while
+// exporting.
+const exportPageSize = 500
+
+// ExportStudents calls fn for every student matching q's filters, in q's
+// order. The repository is read one page at a time so that the roster is
+// never held in memory; q's PageSize and PageToken are ignored. An error
+// returned by fn stops the export and is returned.
+func (uc *UseCase) ExportStudents(ctx context.Context, q *student.ListQuery, fn func(*student.Student) error) error {
+	page := *q
+	page.PageSize = exportPageSize
+	page.PageToken = ""
+
+	for {
+		result, err := uc.repo.List(ctx, &page)
+		if err != nil {
+			return err
+		}
+		for _, s := range result.Students {
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+		if result.NextPageToken == "" {
+			return nil
+		}
+		page.PageToken = result.NextPageToken
+	}
+}
+
 // UpdateStudent updates an existing student with partial update support.
 // Source: "我將該學生的電子郵件更新" (第 24-28 行)
 //
@@ -124,101 +240,537 @@ func (uc *UseCase) UpdateStudent(ctx context.Context, studentNumber string, req
 		return nil, err
 	}
 
-	// Apply partial updates (第 72-77 行)
-	if req.StudentNumber != nil {
-		// Check uniqueness if changing student number
-		if *req.StudentNumber != existing.StudentNumber {
-			exists, err := uc.repo.ExistsByStudentNumber(ctx, *req.StudentNumber)
-			if err != nil {
-				return nil, err
-			}
-			if exists {
-				return nil, student.NewStudentNumberAlreadyExistsError()
-			}
-			existing.StudentNumber = *req.StudentNumber
-		}
+	return uc.applyUpdate(ctx, student.AuditUpdated, existing, req)
+}
+
+// UpdateStudentByID updates the student with the given internal ID.
+// Behaves like UpdateStudent, including student number changes.
+func (uc *UseCase) UpdateStudentByID(ctx context.Context, id string, req *student.UpdateStudentRequest) (*student.Student, error) {
+	existing, err := uc.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	if req.Name != nil {
-		if *req.Name == "" {
-			return nil, student.NewMissingRequiredFieldError("Name")
-		}
-		existing.Name = *req.Name
+	return uc.applyUpdate(ctx, student.AuditUpdated, existing, req)
+}
+
+// ReplaceStudent replaces every field of the student with the given
+// student number (PUT semantics). Fields are validated like CreateStudent.
+func (uc *UseCase) ReplaceStudent(ctx context.Context, studentNumber string, req *student.ReplaceStudentRequest) (*student.Student, error) {
+	if err := validateReplaceRequest(req); err != nil {
+		return nil, err
 	}
 
-	if req.Email != nil {
-		if err := validateEmail(*req.Email); err != nil {
+	return uc.UpdateStudent(ctx, studentNumber, req.AsUpdate())
+}
+
+// ReplaceStudentByID replaces every field of the student with the given
+// internal ID.
+func (uc *UseCase) ReplaceStudentByID(ctx context.Context, id string, req *student.ReplaceStudentRequest) (*student.Student, error) {
+	if err := validateReplaceRequest(req); err != nil {
+		return nil, err
+	}
+
+	return uc.UpdateStudentByID(ctx, id, req.AsUpdate())
+}
+
+// applyUpdate applies a partial update to existing and persists it,
+// recording it in the audit trail as action. The whole patch is validated
+// before anything is applied, so a rejected update leaves no trace.
+func (uc *UseCase) applyUpdate(ctx context.Context, action student.AuditAction, existing *student.Student, req *student.UpdateStudentRequest) (*student.Student, error) {
+	// Validate every provided field first (第 72-77 行)
+	if err := validateUpdateRequest(req); err != nil {
+		return nil, err
+	}
+
+	// Honour the caller's precondition before doing any other work.
+	if req.ExpectedVersion != student.AnyVersion && req.ExpectedVersion != existing.Version {
+		return nil, student.NewVersionConflictError()
+	}
+
+	// Check uniqueness if changing student number; the repository
+	// repeats the check atomically when re-keying the record.
+	if req.StudentNumber != nil && *req.StudentNumber != existing.StudentNumber {
+		exists, err := uc.repo.ExistsByStudentNumber(ctx, *req.StudentNumber)
+		if err != nil {
 			return nil, err
 		}
-		existing.Email = *req.Email
+		if exists {
+			return nil, student.NewStudentNumberAlreadyExistsError()
+		}
 	}
 
+	// Apply partial updates to a copy so the record read from the
+	// repository is never mutated in place.
+	updated := existing.Clone()
+	if req.StudentNumber != nil {
+		updated.StudentNumber = *req.StudentNumber
+	}
+	if req.Name != nil {
+		updated.Name = *req.Name
+	}
+	if req.Email != nil {
+		updated.Email = *req.Email
+	}
 	if req.Class != nil {
-		if *req.Class == "" {
-			return nil, student.NewMissingRequiredFieldError("Class")
-		}
-		existing.Class = *req.Class
+		updated.Class = *req.Class
 	}
-
 	if req.Grade != nil {
-		if *req.Grade < student.MinGrade || *req.Grade > student.MaxGrade {
-			return nil, student.NewInvalidGradeError()
-		}
-		existing.Grade = req.Grade
+		grade := *req.Grade
+		updated.Grade = &grade
+	} else if req.ClearGrade {
+		updated.Grade = nil
 	}
 
-	// Update timestamp
-	existing.UpdatedAt = time.Now()
+	// Update timestamp and version
+	updated.UpdatedAt = time.Now()
+	updated.Version = existing.Version + 1
 
-	// Save updated student
-	if err := uc.repo.Update(ctx, existing); err != nil {
+	// Save updated student; the repository rejects the write if another
+	// update landed since existing was read.
+	if err := uc.auditedUpdate(ctx, action, existing, updated); err != nil {
 		return nil, err
 	}
 
-	return existing, nil
+	return updated, nil
 }
 
-// DeleteStudent deletes a student by student number.
+// DeleteStudent moves a student to the trash by student number. A non-zero
+// expectedVersion rejects the delete with VersionConflict unless the
+// student still has that version; pass student.AnyVersion to skip it.
+// Trashed students can be restored until they are purged.
 // Source: "我請求刪除該學生記錄" (第 30-34 行)
 //
 // Given: 系統中已存在學號為「2024001」的學生記錄
 // When: 我請求刪除該學生記錄
 // Then: 系統應該成功刪除該學生
-func (uc *UseCase) DeleteStudent(ctx context.Context, studentNumber string) error {
+func (uc *UseCase) DeleteStudent(ctx context.Context, studentNumber string, expectedVersion int64) error {
 	// Verify student exists before deletion
-	_, err := uc.repo.FindByStudentNumber(ctx, studentNumber)
+	existing, err := uc.repo.FindByStudentNumber(ctx, studentNumber)
 	if err != nil {
 		// Returns StudentNotFound error (第 66-70 行)
 		return err
 	}
 
-	return uc.repo.Delete(ctx, studentNumber)
+	return uc.trash(ctx, existing, expectedVersion)
+}
+
+// DeleteStudentByID moves the student with the given internal ID to the
+// trash, with the same version semantics as DeleteStudent.
+func (uc *UseCase) DeleteStudentByID(ctx context.Context, id string, expectedVersion int64) error {
+	existing, err := uc.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return uc.trash(ctx, existing, expectedVersion)
+}
+
+// trash marks existing as deleted. The write is conditional on the version
+// that was read, so a concurrent change cannot be silently discarded.
+func (uc *UseCase) trash(ctx context.Context, existing *student.Student, expectedVersion int64) error {
+	if expectedVersion != student.AnyVersion && expectedVersion != existing.Version {
+		return student.NewVersionConflictError()
+	}
+
+	now := time.Now()
+	trashed := existing.Clone()
+	trashed.DeletedAt = &now
+	trashed.UpdatedAt = now
+	trashed.Version = existing.Version + 1
+
+	return uc.auditedUpdate(ctx, student.AuditDeleted, existing, trashed)
+}
+
+// ListDeletedStudents retrieves one page of trashed students matching q,
+// with the same paging rules as ListStudents.
+func (uc *UseCase) ListDeletedStudents(ctx context.Context, q *student.ListQuery) (*student.StudentPage, error) {
+	trash := *q
+	trash.Deleted = true
+	return uc.ListStudents(ctx, &trash)
+}
+
+// GetDeletedStudent retrieves the most recently trashed student with the
+// given student number.
+func (uc *UseCase) GetDeletedStudent(ctx context.Context, studentNumber string) (*student.Student, error) {
+	return uc.repo.FindDeleted(ctx, studentNumber)
+}
+
+// RestoreStudent takes the most recently trashed student with the given
+// student number out of the trash, with the same version semantics as
+// DeleteStudent. Returns StudentNumberAlreadyExists if the number was
+// given to another student meanwhile.
+func (uc *UseCase) RestoreStudent(ctx context.Context, studentNumber string, expectedVersion int64) (*student.Student, error) {
+	trashed, err := uc.repo.FindDeleted(ctx, studentNumber)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != student.AnyVersion && expectedVersion != trashed.Version {
+		return nil, student.NewVersionConflictError()
+	}
+
+	restored := trashed.Clone()
+	restored.DeletedAt = nil
+	restored.UpdatedAt = time.Now()
+	restored.Version = trashed.Version + 1
+
+	if err := uc.auditedUpdate(ctx, student.AuditRestored, trashed, restored); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// RevertStudent sets the fields of the student with the given student
+// number back to those of one of its earlier versions. The revert is an
+// ordinary replacement: it is validated like ReplaceStudent, creates a
+// new version and honours expectedVersion like UpdateStudent. Returns
+// StudentNotFound if the student has no such version.
+func (uc *UseCase) RevertStudent(ctx context.Context, studentNumber string, version, expectedVersion int64) (*student.Student, error) {
+	existing, err := uc.repo.FindByStudentNumber(ctx, studentNumber)
+	if err != nil {
+		return nil, err
+	}
+	old, err := uc.repo.FindVersion(ctx, existing.ID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &student.ReplaceStudentRequest{
+		StudentNumber:   old.StudentNumber,
+		Name:            old.Name,
+		Email:           old.Email,
+		Class:           old.Class,
+		Grade:           old.Grade,
+		ExpectedVersion: expectedVersion,
+	}
+	if err := validateReplaceRequest(req); err != nil {
+		return nil, err
+	}
+	return uc.applyUpdate(ctx, student.AuditReverted, existing, req.AsUpdate())
+}
+
+// GetStudentHistory retrieves the audit trail of the student with the
+// given student number, oldest first. A trashed student's history is
+// returned when no active student has the number.
+func (uc *UseCase) GetStudentHistory(ctx context.Context, studentNumber string) ([]*student.AuditEntry, error) {
+	s, err := uc.repo.FindByStudentNumber(ctx, studentNumber)
+	var studentErr *student.StudentError
+	if errors.As(err, &studentErr) && studentErr.Type == student.ErrorTypeStudentNotFound {
+		s, err = uc.repo.FindDeleted(ctx, studentNumber)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.repo.FindAuditEntries(ctx, s.ID)
+}
+
+// auditedUpdate replaces before with after, conditional on before's
+// version, and records the change as action.
+func (uc *UseCase) auditedUpdate(ctx context.Context, action student.AuditAction, before, after *student.Student) error {
+	return uc.writeAudited(ctx, action, before, after, func(repo studentrepo.Repository) error {
+		return repo.Update(ctx, after, before.Version)
+	})
+}
+
+// writeAudited performs write, appends the audit entry of the change from
+// before to after and adds its domain event to the outbox as one unit of
+// work, so that no change goes unrecorded or unannounced, then publishes
+// the event in process. The entry names the actor and request ID carried
+// by ctx.
+func (uc *UseCase) writeAudited(ctx context.Context, action student.AuditAction, before, after *student.Student, write func(repo studentrepo.Repository) error) error {
+	entry := &student.AuditEntry{
+		ID:        uuid.New().String(),
+		StudentID: after.ID,
+		Action:    action,
+		Actor:     actorFrom(ctx),
+		RequestID: requestIDFrom(ctx),
+		Version:   after.Version,
+		Timestamp: after.UpdatedAt,
+		Changes:   student.Diff(before, after),
+	}
+
+	event := student.NewEvent(entry, after.Clone())
+	message, err := student.NewOutboxMessage(event)
+	if err != nil {
+		return err
+	}
+
+	err = uc.repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+		if err := tx.AppendAuditEntry(ctx, entry); err != nil {
+			return err
+		}
+		return tx.AppendOutbox(ctx, message)
+	})
+	if err != nil {
+		return err
+	}
+
+	if uc.pending != nil {
+		*uc.pending = append(*uc.pending, event)
+	} else {
+		uc.events.Publish(ctx, event)
+	}
+	return nil
+}
+
+// PurgeDeletedStudents permanently removes the students that have been in
+// the trash for longer than retention and returns how many were removed.
+func (uc *UseCase) PurgeDeletedStudents(ctx context.Context, retention time.Duration) (int, error) {
+	return uc.repo.Purge(ctx, time.Now().Add(-retention))
+}
+
+// ImportStudents creates one student per row, validating each row like
+// CreateStudent. Rows are independent: a rejected row, including a student
+// number repeated within the import, is reported and the remaining rows
+// are still imported. With dryRun the rows are only checked and nothing is
+// saved. Errors other than row rejections abort the import.
+func (uc *UseCase) ImportStudents(ctx context.Context, rows []*student.ImportRow, dryRun bool) (*student.ImportReport, error) {
+	report := &student.ImportReport{
+		DryRun: dryRun,
+		Rows:   make([]*student.ImportRowResult, 0, len(rows)),
+	}
+	seen := make(map[string]bool, len(rows))
+
+	for _, row := range rows {
+		result := &student.ImportRowResult{
+			Line:          row.Line,
+			StudentNumber: row.Request.StudentNumber,
+		}
+
+		err := row.Err
+		if err == nil {
+			result.Student, err = uc.importRow(ctx, &row.Request, seen, dryRun)
+		}
+		if err != nil {
+			if !isRowError(err) {
+				return nil, err
+			}
+			result.Status = student.ImportStatusFailed
+			result.Err = err
+			report.Failed++
+		} else {
+			result.Status = student.ImportStatusCreated
+			if dryRun {
+				result.Status = student.ImportStatusValid
+			}
+			seen[row.Request.StudentNumber] = true
+			report.Succeeded++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	return report, nil
 }
 
-// validateCreateRequest validates required fields in CreateStudentRequest.
+// importRow validates and, unless dryRun, creates the student of one
+// import row. seen holds the student numbers of the rows accepted so far.
+func (uc *UseCase) importRow(ctx context.Context, req *student.CreateStudentRequest, seen map[string]bool, dryRun bool) (*student.Student, error) {
+	if err := validateCreateRequest(req); err != nil {
+		return nil, err
+	}
+	if seen[req.StudentNumber] {
+		return nil, student.NewStudentNumberAlreadyExistsError()
+	}
+
+	if !dryRun {
+		return uc.CreateStudent(ctx, req)
+	}
+
+	exists, err := uc.repo.ExistsByStudentNumber(ctx, req.StudentNumber)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, student.NewStudentNumberAlreadyExistsError()
+	}
+	return nil, nil
+}
+
+// isRowError reports whether err rejects a single import row or batch
+// operation rather than the whole request.
+func isRowError(err error) bool {
+	var validationErrs student.ValidationErrors
+	var studentErr *student.StudentError
+	return errors.As(err, &validationErrs) || errors.As(err, &studentErr)
+}
+
+// errBatchAborted rolls back an atomic batch after an operation failed.
+var errBatchAborted = errors.New("batch aborted")
+
+// BatchStudents applies a list of create, update and delete operations in
+// order and reports the outcome of each. Operations behave exactly like
+// CreateStudent, UpdateStudent and DeleteStudent. Without atomic a failed
+// operation does not affect the others; with atomic the batch runs as one
+// unit of work and the first failure rolls back every change, the
+// remaining operations being skipped. An error other than an operation
+// rejection fails an atomic batch as a whole; without atomic the earlier
+// changes are already committed, so it is reported on its operation
+// instead, and the remaining operations are skipped.
+func (uc *UseCase) BatchStudents(ctx context.Context, ops []*student.BatchOperation, atomic bool) (*student.BatchReport, error) {
+	report := &student.BatchReport{
+		Atomic:    atomic,
+		Committed: true,
+		Results:   make([]*student.BatchResult, len(ops)),
+	}
+	for i, op := range ops {
+		report.Results[i] = &student.BatchResult{Type: op.Type, Status: student.BatchStatusSkipped}
+	}
+
+	if atomic {
+		var events []student.Event
+		err := uc.repo.WithinTx(ctx, func(tx studentrepo.Repository) error {
+			txUseCase := *uc
+			txUseCase.repo = tx
+			txUseCase.pending = &events
+			for i, op := range ops {
+				if err := txUseCase.applyBatchOperation(ctx, op, report.Results[i]); err != nil {
+					return err
+				}
+				if report.Results[i].Status == student.BatchStatusFailed {
+					return errBatchAborted
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			uc.events.Publish(ctx, events...)
+		} else if errors.Is(err, errBatchAborted) {
+			report.Committed = false
+			for _, result := range report.Results {
+				if result.Status == student.BatchStatusSucceeded {
+					result.Status = student.BatchStatusRolledBack
+					result.Student = nil
+				}
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		for i, op := range ops {
+			if err := uc.applyBatchOperation(ctx, op, report.Results[i]); err != nil {
+				report.Results[i].Status = student.BatchStatusFailed
+				report.Results[i].Err = err
+				break
+			}
+		}
+	}
+
+	for _, result := range report.Results {
+		switch result.Status {
+		case student.BatchStatusSucceeded:
+			report.Succeeded++
+		case student.BatchStatusFailed:
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// applyBatchOperation performs op and records its outcome in result. Only
+// errors that do not merely reject op are returned.
+func (uc *UseCase) applyBatchOperation(ctx context.Context, op *student.BatchOperation, result *student.BatchResult) error {
+	var (
+		s   *student.Student
+		err error
+	)
+	switch op.Type {
+	case student.BatchCreate:
+		s, err = uc.CreateStudent(ctx, op.Create)
+	case student.BatchUpdate:
+		s, err = uc.UpdateStudent(ctx, op.StudentNumber, op.Update)
+	case student.BatchDelete:
+		err = uc.DeleteStudent(ctx, op.StudentNumber, op.ExpectedVersion)
+	default:
+		return fmt.Errorf("unknown batch operation %q", op.Type)
+	}
+
+	if err != nil {
+		if !isRowError(err) {
+			return err
+		}
+		result.Status = student.BatchStatusFailed
+		result.Err = err
+		return nil
+	}
+	result.Status = student.BatchStatusSucceeded
+	result.Student = s
+	return nil
+}
+
+// validateCreateRequest validates every field in CreateStudentRequest and
+// reports all violations as student.ValidationErrors.
 // Source: "新增時缺少必填欄位" (第 36-40 行)
 func validateCreateRequest(req *student.CreateStudentRequest) error {
-	if req.StudentNumber == "" {
-		return student.NewMissingRequiredFieldError("StudentNumber")
+	return validateStudentFields(req.StudentNumber, req.Name, req.Email, req.Class, req.Grade)
+}
+
+// validateReplaceRequest validates every field in ReplaceStudentRequest.
+func validateReplaceRequest(req *student.ReplaceStudentRequest) error {
+	return validateStudentFields(req.StudentNumber, req.Name, req.Email, req.Class, req.Grade)
+}
+
+// validateStudentFields validates a complete set of student fields and
+// reports all violations as student.ValidationErrors.
+func validateStudentFields(studentNumber, name, email, class string, grade *int) error {
+	var errs student.ValidationErrors
+	if studentNumber == "" {
+		errs.Add(student.NewMissingRequiredFieldError("student_number"))
 	}
-	if req.Name == "" {
-		return student.NewMissingRequiredFieldError("Name")
+	if name == "" {
+		errs.Add(student.NewMissingRequiredFieldError("name"))
 	}
-	if req.Email == "" {
-		return student.NewMissingRequiredFieldError("Email")
+	if email == "" {
+		errs.Add(student.NewMissingRequiredFieldError("email"))
+	} else {
+		errs.Add(validateEmail(email))
 	}
-	if req.Class == "" {
-		return student.NewMissingRequiredFieldError("Class")
+	if class == "" {
+		errs.Add(student.NewMissingRequiredFieldError("class"))
 	}
-	return nil
+	errs.Add(validateGrade(grade))
+	return errs.Err()
+}
+
+// validateUpdateRequest validates every provided field in UpdateStudentRequest
+// and reports all violations as student.ValidationErrors.
+// Source: "部分更新學生資訊" (第 72-77 行)
+func validateUpdateRequest(req *student.UpdateStudentRequest) error {
+	var errs student.ValidationErrors
+	if req.StudentNumber != nil && *req.StudentNumber == "" {
+		errs.Add(student.NewMissingRequiredFieldError("student_number"))
+	}
+	if req.Name != nil && *req.Name == "" {
+		errs.Add(student.NewMissingRequiredFieldError("name"))
+	}
+	if req.Email != nil {
+		errs.Add(validateEmail(*req.Email))
+	}
+	if req.Class != nil && *req.Class == "" {
+		errs.Add(student.NewMissingRequiredFieldError("class"))
+	}
+	errs.Add(validateGrade(req.Grade))
+	return errs.Err()
 }
 
 // validateEmail validates email format.
 // Source: "電子郵件格式驗證" (第 48-52 行)
-func validateEmail(email string) error {
+func validateEmail(email string) *student.StudentError {
 	_, err := mail.ParseAddress(email)
 	if err != nil {
 		return student.NewInvalidEmailError()
 	}
 	return nil
 }
+
+// validateGrade validates the grade range when a grade is provided.
+// Source: "驗證年級範圍" (第 79-83 行)
+func validateGrade(grade *int) *student.StudentError {
+	if grade != nil && (*grade < student.MinGrade || *grade > student.MaxGrade) {
+		return student.NewInvalidGradeError()
+	}
+	return nil
+}
@@ -2,380 +2,1131 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"todo/internal/domain/student"
-	studentrepo "todo/internal/repository/student"
 )
 
 func TestCreateStudent_Success(t *testing.T) {
-	// Scenario: 成功新增學生 (第 5-10 行)
-	// Given: 系統已初始化
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	// When: 我提交新學生資訊
-	grade := 1
-	req := &student.CreateStudentRequest{
-		StudentNumber: "2024001",
-		Name:          "王小明",
-		Email:         "wang@school.edu",
-		Class:         "一年一班",
-		Grade:         &grade,
-	}
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 成功新增學生 (第 5-10 行)
+		// Given: 系統已初始化
+		uc := NewUseCase(repo)
+
+		// When: 我提交新學生資訊
+		grade := 1
+		req := &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+			Grade:         &grade,
+		}
 
-	// Then: 系統應該成功建立學生記錄
-	s, err := uc.CreateStudent(context.Background(), req)
-	require.NoError(t, err)
-	require.NotNil(t, s)
+		// Then: 系統應該成功建立學生記錄
+		s, err := uc.CreateStudent(context.Background(), req)
+		require.NoError(t, err)
+		require.NotNil(t, s)
 
-	// And: 返回的學生 ID 應該不為空
-	assert.NotEmpty(t, s.ID)
+		// And: 返回的學生 ID 應該不為空
+		assert.NotEmpty(t, s.ID)
 
-	// And: 返回的學生資訊應該與提交的資訊相符
-	assert.Equal(t, "2024001", s.StudentNumber)
-	assert.Equal(t, "王小明", s.Name)
-	assert.Equal(t, "wang@school.edu", s.Email)
-	assert.Equal(t, "一年一班", s.Class)
-	assert.Equal(t, &grade, s.Grade)
+		// And: 返回的學生資訊應該與提交的資訊相符
+		assert.Equal(t, "2024001", s.StudentNumber)
+		assert.Equal(t, "王小明", s.Name)
+		assert.Equal(t, "wang@school.edu", s.Email)
+		assert.Equal(t, "一年一班", s.Class)
+		assert.Equal(t, &grade, s.Grade)
+	})
 }
 
 func TestGetStudent_Success(t *testing.T) {
-	// Scenario: 查詢單一學生 (第 12-16 行)
-	// Given: 系統中已存在學號為「2024001」、姓名為「王小明」的學生記錄
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	grade := 1
-	s := &student.Student{
-		ID:            "test-id",
-		StudentNumber: "2024001",
-		Name:          "王小明",
-		Email:         "wang@school.edu",
-		Class:         "一年一班",
-		Grade:         &grade,
-	}
-	require.NoError(t, repo.Save(context.Background(), s))
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 查詢單一學生 (第 12-16 行)
+		// Given: 系統中已存在學號為「2024001」、姓名為「王小明」的學生記錄
+		uc := NewUseCase(repo)
+
+		grade := 1
+		s := &student.Student{
+			ID:            "test-id",
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+			Grade:         &grade,
+		}
+		require.NoError(t, repo.Save(context.Background(), s))
 
-	// When: 我使用學號「2024001」查詢學生
-	result, err := uc.GetStudent(context.Background(), "2024001")
+		// When: 我使用學號「2024001」查詢學生
+		result, err := uc.GetStudent(context.Background(), "2024001")
 
-	// Then: 系統應該返回該學生的完整資訊
-	require.NoError(t, err)
-	assert.Equal(t, "2024001", result.StudentNumber)
-	assert.Equal(t, "王小明", result.Name)
+		// Then: 系統應該返回該學生的完整資訊
+		require.NoError(t, err)
+		assert.Equal(t, "2024001", result.StudentNumber)
+		assert.Equal(t, "王小明", result.Name)
 
-	// And: 返回的資訊應該包含姓名、學號、電子郵件和班級
-	assert.Equal(t, "wang@school.edu", result.Email)
-	assert.Equal(t, "一年一班", result.Class)
+		// And: 返回的資訊應該包含姓名、學號、電子郵件和班級
+		assert.Equal(t, "wang@school.edu", result.Email)
+		assert.Equal(t, "一年一班", result.Class)
+	})
 }
 
 func TestGetAllStudents_Success(t *testing.T) {
-	// Scenario: 查詢所有學生 (第 18-22 行)
-	// Given: 系統中已存在 5 筆學生記錄
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	for i := 1; i <= 5; i++ {
-		s := &student.Student{
-			ID:            "test-id-" + string(rune(i)),
-			StudentNumber: "202400" + string(rune(i+'0')),
-			Name:          "学生" + string(rune(i+'0')),
-			Email:         "student" + string(rune(i+'0')) + "@school.edu",
-			Class:         "一年一班",
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 查詢所有學生 (第 18-22 行)
+		// Given: 系統中已存在 5 筆學生記錄
+		uc := NewUseCase(repo)
+
+		for i := 1; i <= 5; i++ {
+			s := &student.Student{
+				ID:            "test-id-" + string(rune(i)),
+				StudentNumber: "202400" + string(rune(i+'0')),
+				Name:          "学生" + string(rune(i+'0')),
+				Email:         "student" + string(rune(i+'0')) + "@school.edu",
+				Class:         "一年一班",
+			}
+			require.NoError(t, repo.Save(context.Background(), s))
 		}
-		require.NoError(t, repo.Save(context.Background(), s))
-	}
 
-	// When: 我請求查詢所有學生
-	students, err := uc.GetAllStudents(context.Background())
+		// When: 我請求查詢所有學生
+		students, err := uc.GetAllStudents(context.Background())
 
-	// Then: 系統應該返回所有 5 筆學生記錄
-	require.NoError(t, err)
-	assert.Len(t, students, 5)
+		// Then: 系統應該返回所有 5 筆學生記錄
+		require.NoError(t, err)
+		assert.Len(t, students, 5)
 
-	// And: 每筆記錄都應該包含學生的完整資訊
-	for _, s := range students {
-		assert.NotEmpty(t, s.ID)
-		assert.NotEmpty(t, s.StudentNumber)
-		assert.NotEmpty(t, s.Name)
-		assert.NotEmpty(t, s.Email)
-		assert.NotEmpty(t, s.Class)
-	}
+		// And: 每筆記錄都應該包含學生的完整資訊
+		for _, s := range students {
+			assert.NotEmpty(t, s.ID)
+			assert.NotEmpty(t, s.StudentNumber)
+			assert.NotEmpty(t, s.Name)
+			assert.NotEmpty(t, s.Email)
+			assert.NotEmpty(t, s.Class)
+		}
+	})
 }
 
 func TestUpdateStudent_Success(t *testing.T) {
-	// Scenario: 成功更新學生資訊 (第 24-28 行)
-	// Given: 系統中已存在學號為「2024001」的學生記錄
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	grade := 1
-	s := &student.Student{
-		ID:            "test-id",
-		StudentNumber: "2024001",
-		Name:          "王小明",
-		Email:         "wang@school.edu",
-		Class:         "一年一班",
-		Grade:         &grade,
-	}
-	require.NoError(t, repo.Save(context.Background(), s))
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 成功更新學生資訊 (第 24-28 行)
+		// Given: 系統中已存在學號為「2024001」的學生記錄
+		uc := NewUseCase(repo)
 
-	// When: 我將該學生的電子郵件更新為「wang.new@school.edu」
-	newEmail := "wang.new@school.edu"
-	updateReq := &student.UpdateStudentRequest{
-		Email: &newEmail,
-	}
-	updated, err := uc.UpdateStudent(context.Background(), "2024001", updateReq)
+		grade := 1
+		s := &student.Student{
+			ID:            "test-id",
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+			Grade:         &grade,
+		}
+		require.NoError(t, repo.Save(context.Background(), s))
+
+		// When: 我將該學生的電子郵件更新為「wang.new@school.edu」
+		newEmail := "wang.new@school.edu"
+		updateReq := &student.UpdateStudentRequest{
+			Email: &newEmail,
+		}
+		updated, err := uc.UpdateStudent(context.Background(), "2024001", updateReq)
 
-	// Then: 系統應該成功更新學生記錄
-	require.NoError(t, err)
+		// Then: 系統應該成功更新學生記錄
+		require.NoError(t, err)
 
-	// And: 查詢該學生時應該返回新的電子郵件地址
-	assert.Equal(t, "wang.new@school.edu", updated.Email)
-	assert.Equal(t, "王小明", updated.Name) // Other fields unchanged
+		// And: 查詢該學生時應該返回新的電子郵件地址
+		assert.Equal(t, "wang.new@school.edu", updated.Email)
+		assert.Equal(t, "王小明", updated.Name) // Other fields unchanged
+	})
 }
 
 func TestDeleteStudent_Success(t *testing.T) {
-	// Scenario: 成功刪除學生 (第 30-34 行)
-	// Given: 系統中已存在學號為「2024001」的學生記錄
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	s := &student.Student{
-		ID:            "test-id",
-		StudentNumber: "2024001",
-		Name:          "王小明",
-		Email:         "wang@school.edu",
-		Class:         "一年一班",
-	}
-	require.NoError(t, repo.Save(context.Background(), s))
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 成功刪除學生 (第 30-34 行)
+		// Given: 系統中已存在學號為「2024001」的學生記錄
+		uc := NewUseCase(repo)
 
-	// When: 我請求刪除該學生記錄
-	err := uc.DeleteStudent(context.Background(), "2024001")
+		s := &student.Student{
+			ID:            "test-id",
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		}
+		require.NoError(t, repo.Save(context.Background(), s))
+
+		// When: 我請求刪除該學生記錄
+		err := uc.DeleteStudent(context.Background(), "2024001", student.AnyVersion)
 
-	// Then: 系統應該成功刪除該學生
-	require.NoError(t, err)
+		// Then: 系統應該成功刪除該學生
+		require.NoError(t, err)
 
-	// And: 再次查詢該學號時應該返回「學生不存在」的錯誤
-	_, err = uc.GetStudent(context.Background(), "2024001")
-	require.Error(t, err)
-	var studentErr *student.StudentError
-	require.ErrorAs(t, err, &studentErr)
-	assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+		// And: 再次查詢該學號時應該返回「學生不存在」的錯誤
+		_, err = uc.GetStudent(context.Background(), "2024001")
+		require.Error(t, err)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+	})
 }
 
 func TestCreateStudent_MissingRequiredField(t *testing.T) {
-	// Scenario: 新增時缺少必填欄位 (第 36-40 行)
-	// Given: 系統已初始化
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	// When: 我提交不完整的學生資訊，缺少姓名欄位
-	req := &student.CreateStudentRequest{
-		StudentNumber: "2024001",
-		Name:          "", // Missing name
-		Email:         "wang@school.edu",
-		Class:         "一年一班",
-	}
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 新增時缺少必填欄位 (第 36-40 行)
+		// Given: 系統已初始化
+		uc := NewUseCase(repo)
+
+		// When: 我提交不完整的學生資訊，缺少姓名欄位
+		req := &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "", // Missing name
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		}
 
-	// Then: 系統應該拒絕並返回錯誤
-	_, err := uc.CreateStudent(context.Background(), req)
-	require.Error(t, err)
+		// Then: 系統應該拒絕並返回錯誤
+		_, err := uc.CreateStudent(context.Background(), req)
+		require.Error(t, err)
 
-	var studentErr *student.StudentError
-	require.ErrorAs(t, err, &studentErr)
-	assert.Equal(t, student.ErrorTypeMissingRequiredField, studentErr.Type)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeMissingRequiredField, studentErr.Type)
 
-	// And: 學生記錄不應該被建立
-	students, _ := uc.GetAllStudents(context.Background())
-	assert.Len(t, students, 0)
+		// And: 學生記錄不應該被建立
+		students, _ := uc.GetAllStudents(context.Background())
+		assert.Len(t, students, 0)
+	})
 }
 
 func TestCreateStudent_StudentNumberAlreadyExists(t *testing.T) {
-	// Scenario: 學號必須唯一 (第 42-46 行)
-	// Given: 系統中已存在學號為「2024001」的學生記錄
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	s := &student.Student{
-		ID:            "test-id",
-		StudentNumber: "2024001",
-		Name:          "王小明",
-		Email:         "wang@school.edu",
-		Class:         "一年一班",
-	}
-	require.NoError(t, repo.Save(context.Background(), s))
-
-	// When: 我嘗試新增另一個學號相同「2024001」的學生
-	req := &student.CreateStudentRequest{
-		StudentNumber: "2024001",
-		Name:          "另一個學生",
-		Email:         "another@school.edu",
-		Class:         "一年一班",
-	}
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 學號必須唯一 (第 42-46 行)
+		// Given: 系統中已存在學號為「2024001」的學生記錄
+		uc := NewUseCase(repo)
 
-	// Then: 系統應該拒絕並返回錯誤「學號已存在」
-	_, err := uc.CreateStudent(context.Background(), req)
-	require.Error(t, err)
+		s := &student.Student{
+			ID:            "test-id",
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		}
+		require.NoError(t, repo.Save(context.Background(), s))
+
+		// When: 我嘗試新增另一個學號相同「2024001」的學生
+		req := &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "另一個學生",
+			Email:         "another@school.edu",
+			Class:         "一年一班",
+		}
 
-	var studentErr *student.StudentError
-	require.ErrorAs(t, err, &studentErr)
-	assert.Equal(t, student.ErrorTypeStudentNumberAlreadyExists, studentErr.Type)
+		// Then: 系統應該拒絕並返回錯誤「學號已存在」
+		_, err := uc.CreateStudent(context.Background(), req)
+		require.Error(t, err)
 
-	// And: 新的學生記錄不應該被建立
-	students, _ := uc.GetAllStudents(context.Background())
-	assert.Len(t, students, 1)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNumberAlreadyExists, studentErr.Type)
+
+		// And: 新的學生記錄不應該被建立
+		students, _ := uc.GetAllStudents(context.Background())
+		assert.Len(t, students, 1)
+	})
 }
 
 func TestCreateStudent_InvalidEmail(t *testing.T) {
-	// Scenario: 電子郵件格式驗證 (第 48-52 行)
-	// Given: 系統已初始化
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	// When: 我提交學生資訊，電子郵件為無效格式「invalid-email」
-	req := &student.CreateStudentRequest{
-		StudentNumber: "2024001",
-		Name:          "王小明",
-		Email:         "invalid-email",
-		Class:         "一年一班",
-	}
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 電子郵件格式驗證 (第 48-52 行)
+		// Given: 系統已初始化
+		uc := NewUseCase(repo)
+
+		// When: 我提交學生資訊，電子郵件為無效格式「invalid-email」
+		req := &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "invalid-email",
+			Class:         "一年一班",
+		}
 
-	// Then: 系統應該拒絕並返回錯誤
-	_, err := uc.CreateStudent(context.Background(), req)
-	require.Error(t, err)
+		// Then: 系統應該拒絕並返回錯誤
+		_, err := uc.CreateStudent(context.Background(), req)
+		require.Error(t, err)
 
-	var studentErr *student.StudentError
-	require.ErrorAs(t, err, &studentErr)
-	assert.Equal(t, student.ErrorTypeInvalidEmail, studentErr.Type)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeInvalidEmail, studentErr.Type)
 
-	// And: 學生記錄不應該被建立
-	students, _ := uc.GetAllStudents(context.Background())
-	assert.Len(t, students, 0)
+		// And: 學生記錄不應該被建立
+		students, _ := uc.GetAllStudents(context.Background())
+		assert.Len(t, students, 0)
+	})
 }
 
 func TestGetStudent_NotFound(t *testing.T) {
-	// Scenario: 查詢不存在的學生 (第 54-58 行)
-	// Given: 系統已初始化
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 查詢不存在的學生 (第 54-58 行)
+		// Given: 系統已初始化
+		uc := NewUseCase(repo)
 
-	// When: 我使用不存在的學號「9999999」查詢學生
-	_, err := uc.GetStudent(context.Background(), "9999999")
+		// When: 我使用不存在的學號「9999999」查詢學生
+		_, err := uc.GetStudent(context.Background(), "9999999")
 
-	// Then: 系統應該返回錯誤「學生不存在」
-	require.Error(t, err)
+		// Then: 系統應該返回錯誤「學生不存在」
+		require.Error(t, err)
 
-	var studentErr *student.StudentError
-	require.ErrorAs(t, err, &studentErr)
-	assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+	})
 }
 
 func TestUpdateStudent_NotFound(t *testing.T) {
-	// Scenario: 更新不存在的學生 (第 60-64 行)
-	// Given: 系統已初始化
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	// When: 我嘗試更新不存在的學號「9999999」的學生資訊
-	newEmail := "new@school.edu"
-	updateReq := &student.UpdateStudentRequest{
-		Email: &newEmail,
-	}
-	_, err := uc.UpdateStudent(context.Background(), "9999999", updateReq)
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 更新不存在的學生 (第 60-64 行)
+		// Given: 系統已初始化
+		uc := NewUseCase(repo)
+
+		// When: 我嘗試更新不存在的學號「9999999」的學生資訊
+		newEmail := "new@school.edu"
+		updateReq := &student.UpdateStudentRequest{
+			Email: &newEmail,
+		}
+		_, err := uc.UpdateStudent(context.Background(), "9999999", updateReq)
 
-	// Then: 系統應該返回錯誤「學生不存在」
-	require.Error(t, err)
+		// Then: 系統應該返回錯誤「學生不存在」
+		require.Error(t, err)
 
-	var studentErr *student.StudentError
-	require.ErrorAs(t, err, &studentErr)
-	assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
 
-	// And: 不應該建立新的學生記錄
-	students, _ := uc.GetAllStudents(context.Background())
-	assert.Len(t, students, 0)
+		// And: 不應該建立新的學生記錄
+		students, _ := uc.GetAllStudents(context.Background())
+		assert.Len(t, students, 0)
+	})
 }
 
 func TestDeleteStudent_NotFound(t *testing.T) {
-	// Scenario: 刪除不存在的學生 (第 66-70 行)
-	// Given: 系統已初始化
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 刪除不存在的學生 (第 66-70 行)
+		// Given: 系統已初始化
+		uc := NewUseCase(repo)
 
-	// When: 我嘗試刪除不存在的學號「9999999」的學生
-	err := uc.DeleteStudent(context.Background(), "9999999")
+		// When: 我嘗試刪除不存在的學號「9999999」的學生
+		err := uc.DeleteStudent(context.Background(), "9999999", student.AnyVersion)
 
-	// Then: 系統應該返回錯誤「學生不存在」
-	require.Error(t, err)
+		// Then: 系統應該返回錯誤「學生不存在」
+		require.Error(t, err)
 
-	var studentErr *student.StudentError
-	require.ErrorAs(t, err, &studentErr)
-	assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+	})
 }
 
 func TestUpdateStudent_PartialUpdate(t *testing.T) {
-	// Scenario: 部分更新學生資訊 (第 72-77 行)
-	// Given: 系統中已存在學號為「2024001」的學生記錄，班級為「一年一班」
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	s := &student.Student{
-		ID:            "test-id",
-		StudentNumber: "2024001",
-		Name:          "王小明",
-		Email:         "wang@school.edu",
-		Class:         "一年一班",
-	}
-	require.NoError(t, repo.Save(context.Background(), s))
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 部分更新學生資訊 (第 72-77 行)
+		// Given: 系統中已存在學號為「2024001」的學生記錄，班級為「一年一班」
+		uc := NewUseCase(repo)
 
-	// When: 我只更新該學生的班級為「一年二班」，不更新其他欄位
-	newClass := "一年二班"
-	updateReq := &student.UpdateStudentRequest{
-		Class: &newClass,
-	}
-	updated, err := uc.UpdateStudent(context.Background(), "2024001", updateReq)
+		s := &student.Student{
+			ID:            "test-id",
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		}
+		require.NoError(t, repo.Save(context.Background(), s))
+
+		// When: 我只更新該學生的班級為「一年二班」，不更新其他欄位
+		newClass := "一年二班"
+		updateReq := &student.UpdateStudentRequest{
+			Class: &newClass,
+		}
+		updated, err := uc.UpdateStudent(context.Background(), "2024001", updateReq)
 
-	// Then: 系統應該成功更新班級欄位
-	require.NoError(t, err)
-	assert.Equal(t, "一年二班", updated.Class)
+		// Then: 系統應該成功更新班級欄位
+		require.NoError(t, err)
+		assert.Equal(t, "一年二班", updated.Class)
 
-	// And: 其他欄位應該保持不變
-	assert.Equal(t, "王小明", updated.Name)
-	assert.Equal(t, "wang@school.edu", updated.Email)
+		// And: 其他欄位應該保持不變
+		assert.Equal(t, "王小明", updated.Name)
+		assert.Equal(t, "wang@school.edu", updated.Email)
 
-	// And: 學號應該仍然是「2024001」
-	assert.Equal(t, "2024001", updated.StudentNumber)
+		// And: 學號應該仍然是「2024001」
+		assert.Equal(t, "2024001", updated.StudentNumber)
+	})
 }
 
 func TestCreateStudent_InvalidGrade(t *testing.T) {
-	// Scenario: 驗證年級範圍 (第 79-83 行)
-	// Given: 系統已初始化
-	repo := studentrepo.NewMemoryRepository()
-	uc := NewUseCase(repo)
-
-	// When: 我提交學生資訊，年級為無效值「10」（超出範圍）
-	grade := 10
-	req := &student.CreateStudentRequest{
-		StudentNumber: "2024001",
-		Name:          "王小明",
-		Email:         "wang@school.edu",
-		Class:         "一年一班",
-		Grade:         &grade,
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Scenario: 驗證年級範圍 (第 79-83 行)
+		// Given: 系統已初始化
+		uc := NewUseCase(repo)
+
+		// When: 我提交學生資訊，年級為無效值「10」（超出範圍）
+		grade := 10
+		req := &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+			Grade:         &grade,
+		}
+
+		// Then: 系統應該拒絕並返回錯誤
+		_, err := uc.CreateStudent(context.Background(), req)
+		require.Error(t, err)
+
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeInvalidGrade, studentErr.Type)
+
+		// And: 學生記錄不應該被建立
+		students, _ := uc.GetAllStudents(context.Background())
+		assert.Len(t, students, 0)
+	})
+}
+
+func TestUpdateStudent_ChangeStudentNumber(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統中已存在學號為「2024001」的學生記錄
+		uc := NewUseCase(repo)
+
+		s := &student.Student{
+			ID:            "test-id",
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		}
+		require.NoError(t, repo.Save(context.Background(), s))
+
+		// When: 我將該學生的學號更新為「2024100」
+		newNumber := "2024100"
+		updated, err := uc.UpdateStudent(context.Background(), "2024001", &student.UpdateStudentRequest{
+			StudentNumber: &newNumber,
+		})
+
+		// Then: 系統應該成功更新學號，且 ID 保持不變
+		require.NoError(t, err)
+		assert.Equal(t, "2024100", updated.StudentNumber)
+		assert.Equal(t, "test-id", updated.ID)
+
+		// And: 使用新學號可以查詢到該學生，舊學號則不存在
+		found, err := uc.GetStudent(context.Background(), "2024100")
+		require.NoError(t, err)
+		assert.Equal(t, "王小明", found.Name)
+
+		_, err = uc.GetStudent(context.Background(), "2024001")
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+	})
+}
+
+func TestUpdateStudent_ChangeStudentNumberConflict(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統中已存在學號為「2024001」與「2024002」的學生記錄
+		uc := NewUseCase(repo)
+
+		for _, number := range []string{"2024001", "2024002"} {
+			require.NoError(t, repo.Save(context.Background(), &student.Student{
+				ID:            "id-" + number,
+				StudentNumber: number,
+				Name:          "王小明",
+				Email:         "wang@school.edu",
+				Class:         "一年一班",
+			}))
+		}
+
+		// When: 我將「2024001」的學號改為已被使用的「2024002」
+		taken := "2024002"
+		_, err := uc.UpdateStudent(context.Background(), "2024001", &student.UpdateStudentRequest{
+			StudentNumber: &taken,
+		})
+
+		// Then: 系統應該拒絕並返回錯誤「學號已存在」
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNumberAlreadyExists, studentErr.Type)
+
+		// And: 原學生記錄應該保持不變
+		found, err := uc.GetStudent(context.Background(), "2024001")
+		require.NoError(t, err)
+		assert.Equal(t, "id-2024001", found.ID)
+	})
+}
+
+func TestUpdateStudent_RejectedPatchIsNotApplied(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統中已存在學號為「2024001」、姓名為「王小明」的學生記錄
+		uc := NewUseCase(repo)
+
+		s := &student.Student{
+			ID:            "test-id",
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		}
+		require.NoError(t, repo.Save(context.Background(), s))
+
+		// When: 我同時更新姓名與無效的電子郵件
+		newName := "王大明"
+		badEmail := "invalid-email"
+		_, err := uc.UpdateStudent(context.Background(), "2024001", &student.UpdateStudentRequest{
+			Name:  &newName,
+			Email: &badEmail,
+		})
+
+		// Then: 系統應該拒絕並返回錯誤
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeInvalidEmail, studentErr.Type)
+
+		// And: 姓名不應該被部分更新
+		found, err := uc.GetStudent(context.Background(), "2024001")
+		require.NoError(t, err)
+		assert.Equal(t, "王小明", found.Name)
+		assert.Equal(t, "wang@school.edu", found.Email)
+	})
+}
+
+func TestCreateStudent_AggregatesValidationErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統已初始化
+		uc := NewUseCase(repo)
+
+		// When: 我提交缺少學號與班級、且電子郵件格式無效的學生資訊
+		_, err := uc.CreateStudent(context.Background(), &student.CreateStudentRequest{
+			Name:  "王小明",
+			Email: "invalid-email",
+		})
+
+		// Then: 系統應該回報所有欄位錯誤
+		var validationErrs student.ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		require.Len(t, validationErrs, 3)
+		assert.Equal(t, "student_number", validationErrs[0].Field)
+		assert.Equal(t, student.ErrorTypeInvalidEmail, validationErrs[1].Type)
+		assert.Equal(t, "class", validationErrs[2].Field)
+	})
+}
+
+func TestUpdateStudent_StaleVersion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統中已存在學號為「2024001」的學生記錄
+		uc := NewUseCase(repo)
+
+		created, err := uc.CreateStudent(context.Background(), &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, student.InitialVersion, created.Version)
+
+		// When: 第一次更新成功，版本遞增
+		newClass := "一年二班"
+		updated, err := uc.UpdateStudent(context.Background(), "2024001", &student.UpdateStudentRequest{
+			Class:           &newClass,
+			ExpectedVersion: created.Version,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, created.Version+1, updated.Version)
+
+		// Then: 以舊版本再次更新或刪除應該返回版本衝突
+		otherClass := "一年三班"
+		_, err = uc.UpdateStudent(context.Background(), "2024001", &student.UpdateStudentRequest{
+			Class:           &otherClass,
+			ExpectedVersion: created.Version,
+		})
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeVersionConflict, studentErr.Type)
+
+		err = uc.DeleteStudent(context.Background(), "2024001", created.Version)
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeVersionConflict, studentErr.Type)
+	})
+}
+
+func TestImportStudents_ReportsEachRow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統中已存在學號為「2024001」的學生記錄
+		uc := NewUseCase(repo)
+		ctx := context.Background()
+
+		_, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+
+		rows := []*student.ImportRow{
+			{Line: 2, Request: student.CreateStudentRequest{StudentNumber: "2024002", Name: "李小華", Email: "lee@school.edu", Class: "一年一班"}},
+			{Line: 3, Request: student.CreateStudentRequest{StudentNumber: "2024001", Name: "重複", Email: "dup@school.edu", Class: "一年一班"}},
+			{Line: 4, Request: student.CreateStudentRequest{StudentNumber: "2024002", Name: "重複", Email: "dup@school.edu", Class: "一年一班"}},
+			{Line: 5, Request: student.CreateStudentRequest{StudentNumber: "2024003", Email: "invalid"}},
+			{Line: 6, Request: student.CreateStudentRequest{StudentNumber: "2024004"}, Err: student.NewInvalidGradeError()},
+		}
+
+		// When: 以試算模式匯入
+		report, err := uc.ImportStudents(ctx, rows, true)
+		require.NoError(t, err)
+
+		// Then: 每一列都有結果，且沒有任何資料被儲存
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 1, report.Succeeded)
+		assert.Equal(t, 4, report.Failed)
+		require.Len(t, report.Rows, 5)
+		assert.Equal(t, student.ImportStatusValid, report.Rows[0].Status)
+
+		students, err := uc.GetAllStudents(ctx)
+		require.NoError(t, err)
+		assert.Len(t, students, 1)
+
+		// And: 重複的學號（包含同一檔案內）與無效欄位都被回報
+		var studentErr *student.StudentError
+		require.ErrorAs(t, report.Rows[1].Err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNumberAlreadyExists, studentErr.Type)
+		require.ErrorAs(t, report.Rows[2].Err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNumberAlreadyExists, studentErr.Type)
+
+		var validationErrs student.ValidationErrors
+		require.ErrorAs(t, report.Rows[3].Err, &validationErrs)
+		assert.Len(t, validationErrs, 3)
+		assert.Equal(t, 6, report.Rows[4].Line)
+		assert.Equal(t, student.ImportStatusFailed, report.Rows[4].Status)
+
+		// When: 正式匯入
+		report, err = uc.ImportStudents(ctx, rows, false)
+		require.NoError(t, err)
+
+		// Then: 有效的列被建立
+		assert.Equal(t, 1, report.Succeeded)
+		assert.Equal(t, student.ImportStatusCreated, report.Rows[0].Status)
+		require.NotNil(t, report.Rows[0].Student)
+
+		created, err := uc.GetStudent(ctx, "2024002")
+		require.NoError(t, err)
+		assert.Equal(t, "李小華", created.Name)
+	})
+}
+
+func TestBatchStudents_AtomicRollsBack(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統中已存在學號為「2024001」的學生記錄
+		uc := NewUseCase(repo)
+		ctx := context.Background()
+
+		_, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+
+		newEmail := "wang.new@school.edu"
+		ops := []*student.BatchOperation{
+			{Type: student.BatchCreate, Create: &student.CreateStudentRequest{
+				StudentNumber: "2024002", Name: "李小華", Email: "lee@school.edu", Class: "一年一班",
+			}},
+			{Type: student.BatchUpdate, StudentNumber: "2024001", Update: &student.UpdateStudentRequest{Email: &newEmail}},
+			{Type: student.BatchDelete, StudentNumber: "9999999"},
+			{Type: student.BatchDelete, StudentNumber: "2024001"},
+		}
+
+		// When: 以原子模式執行，其中一個操作失敗
+		report, err := uc.BatchStudents(ctx, ops, true)
+		require.NoError(t, err)
+
+		// Then: 所有變更都應該被復原
+		assert.False(t, report.Committed)
+		assert.Equal(t, 0, report.Succeeded)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, student.BatchStatusRolledBack, report.Results[0].Status)
+		assert.Nil(t, report.Results[0].Student)
+		assert.Equal(t, student.BatchStatusRolledBack, report.Results[1].Status)
+		assert.Equal(t, student.BatchStatusFailed, report.Results[2].Status)
+		assert.Equal(t, student.BatchStatusSkipped, report.Results[3].Status)
+
+		var studentErr *student.StudentError
+		require.ErrorAs(t, report.Results[2].Err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+
+		students, err := uc.GetAllStudents(ctx)
+		require.NoError(t, err)
+		require.Len(t, students, 1)
+		assert.Equal(t, "wang@school.edu", students[0].Email)
+
+		// When: 以非原子模式執行相同的操作
+		report, err = uc.BatchStudents(ctx, ops, false)
+		require.NoError(t, err)
+
+		// Then: 只有失敗的操作不生效
+		assert.True(t, report.Committed)
+		assert.Equal(t, 3, report.Succeeded)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, student.BatchStatusSucceeded, report.Results[1].Status)
+		assert.Equal(t, newEmail, report.Results[1].Student.Email)
+
+		students, err = uc.GetAllStudents(ctx)
+		require.NoError(t, err)
+		require.Len(t, students, 1)
+		assert.Equal(t, "2024002", students[0].StudentNumber)
+	})
+}
+
+// unavailableRepository fails every lookup of student number down, like
+// a repository losing its database in the middle of a batch.
+type unavailableRepository struct {
+	testStore
+	down string
+}
+
+var errUnavailable = errors.New("database is locked")
+
+func (r *unavailableRepository) ExistsByStudentNumber(ctx context.Context, studentNumber string) (bool, error) {
+	if studentNumber == r.down {
+		return false, errUnavailable
 	}
+	return r.testStore.ExistsByStudentNumber(ctx, studentNumber)
+}
 
-	// Then: 系統應該拒絕並返回錯誤
-	_, err := uc.CreateStudent(context.Background(), req)
-	require.Error(t, err)
+func TestBatchStudents_ReportsRepositoryFailure(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 資料庫在處理第二個操作時失效
+		uc := NewUseCase(&unavailableRepository{testStore: repo, down: "2024002"})
+		ctx := context.Background()
+		ops := []*student.BatchOperation{
+			{Type: student.BatchCreate, Create: &student.CreateStudentRequest{
+				StudentNumber: "2024001", Name: "王小明", Email: "wang@school.edu", Class: "一年一班",
+			}},
+			{Type: student.BatchCreate, Create: &student.CreateStudentRequest{
+				StudentNumber: "2024002", Name: "李小華", Email: "lee@school.edu", Class: "一年一班",
+			}},
+			{Type: student.BatchCreate, Create: &student.CreateStudentRequest{
+				StudentNumber: "2024003", Name: "陳大文", Email: "chen@school.edu", Class: "一年一班",
+			}},
+		}
+
+		// When: 以非原子模式執行
+		report, err := uc.BatchStudents(ctx, ops, false)
+
+		// Then: 仍回傳報告，指出已完成、失敗與未執行的操作
+		require.NoError(t, err)
+		assert.True(t, report.Committed)
+		assert.Equal(t, 1, report.Succeeded)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, student.BatchStatusSucceeded, report.Results[0].Status)
+		assert.Equal(t, student.BatchStatusFailed, report.Results[1].Status)
+		assert.ErrorIs(t, report.Results[1].Err, errUnavailable)
+		assert.Equal(t, student.BatchStatusSkipped, report.Results[2].Status)
+
+		// And: 失敗前的變更已提交
+		_, err = uc.GetStudent(ctx, "2024001")
+		assert.NoError(t, err)
+		_, err = uc.GetStudent(ctx, "2024003")
+		assert.Error(t, err)
+	})
+}
 
-	var studentErr *student.StudentError
-	require.ErrorAs(t, err, &studentErr)
-	assert.Equal(t, student.ErrorTypeInvalidGrade, studentErr.Type)
+func TestDeleteStudent_TrashAndRestore(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統中已存在學號為「2024001」的學生記錄
+		uc := NewUseCase(repo)
+		ctx := context.Background()
 
-	// And: 學生記錄不應該被建立
-	students, _ := uc.GetAllStudents(context.Background())
-	assert.Len(t, students, 0)
+		created, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+
+		// When: 我刪除該學生
+		require.NoError(t, uc.DeleteStudent(ctx, "2024001", student.AnyVersion))
+
+		// Then: 學生應該移至垃圾桶，而不是永久刪除
+		_, err = uc.GetStudent(ctx, "2024001")
+		require.Error(t, err)
+
+		trash, err := uc.ListDeletedStudents(ctx, &student.ListQuery{})
+		require.NoError(t, err)
+		require.Len(t, trash.Students, 1)
+		assert.Equal(t, created.ID, trash.Students[0].ID)
+		assert.NotNil(t, trash.Students[0].DeletedAt)
+
+		// When: 我還原該學生
+		restored, err := uc.RestoreStudent(ctx, "2024001", trash.Students[0].Version)
+		require.NoError(t, err)
+
+		// Then: 學生應該恢復，且保留原本的 ID
+		assert.Equal(t, created.ID, restored.ID)
+		assert.Nil(t, restored.DeletedAt)
+		found, err := uc.GetStudent(ctx, "2024001")
+		require.NoError(t, err)
+		assert.Equal(t, restored.Version, found.Version)
+
+		// And: 學號在刪除期間被重複使用時，還原應該失敗
+		require.NoError(t, uc.DeleteStudent(ctx, "2024001", student.AnyVersion))
+		_, err = uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "李小華",
+			Email:         "lee@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+
+		_, err = uc.RestoreStudent(ctx, "2024001", student.AnyVersion)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNumberAlreadyExists, studentErr.Type)
+
+		// And: 超過保留期限的學生會被永久清除
+		purged, err := uc.PurgeDeletedStudents(ctx, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, 0, purged)
+
+		purged, err = uc.PurgeDeletedStudents(ctx, -time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		_, err = uc.GetDeletedStudent(ctx, "2024001")
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+	})
+}
+
+func TestGetStudentHistory_RecordsEveryChange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 一位管理員在同一個請求中新增學生
+		uc := NewUseCase(repo)
+		ctx := WithRequestID(WithPrincipal(context.Background(), &student.Principal{Subject: "admin"}), "req-1")
+
+		created, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+
+		// When: 我更新電子郵件、嘗試一次無效的更新，然後刪除該學生
+		newEmail := "wang.new@school.edu"
+		_, err = uc.UpdateStudent(ctx, "2024001", &student.UpdateStudentRequest{Email: &newEmail})
+		require.NoError(t, err)
+
+		invalidEmail := "invalid"
+		_, err = uc.UpdateStudent(ctx, "2024001", &student.UpdateStudentRequest{Email: &invalidEmail})
+		require.Error(t, err)
+
+		require.NoError(t, uc.DeleteStudent(context.Background(), "2024001", student.AnyVersion))
+
+		// Then: 每一次成功的變更都應該留下稽核紀錄，包含操作者、請求 ID 與欄位差異
+		history, err := uc.GetStudentHistory(context.Background(), "2024001")
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+
+		assert.Equal(t, student.AuditCreated, history[0].Action)
+		assert.Equal(t, created.ID, history[0].StudentID)
+		assert.Equal(t, "admin", history[0].Actor)
+		assert.Equal(t, "req-1", history[0].RequestID)
+		assert.Len(t, history[0].Changes, 4)
+
+		assert.Equal(t, student.AuditUpdated, history[1].Action)
+		assert.Equal(t, int64(2), history[1].Version)
+		require.Len(t, history[1].Changes, 1)
+		assert.Equal(t, "email", history[1].Changes[0].Field)
+		assert.JSONEq(t, `"wang@school.edu"`, string(history[1].Changes[0].Before))
+		assert.JSONEq(t, `"wang.new@school.edu"`, string(history[1].Changes[0].After))
+
+		assert.Equal(t, student.AuditDeleted, history[2].Action)
+		assert.Equal(t, AnonymousActor, history[2].Actor)
+		require.Len(t, history[2].Changes, 1)
+		assert.Equal(t, "deleted_at", history[2].Changes[0].Field)
+	})
+}
+
+func TestRevertStudent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 學生原本在「一年一班」，之後被調到「一年二班」
+		uc := NewUseCase(repo)
+		ctx := context.Background()
+
+		created, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+		beforeMove := time.Now()
+
+		newClass := "一年二班"
+		moved, err := uc.UpdateStudent(ctx, "2024001", &student.UpdateStudentRequest{Class: &newClass})
+		require.NoError(t, err)
+
+		// Then: 可以查詢學生在調班前的狀態
+		then, err := uc.GetStudentAsOf(ctx, "2024001", beforeMove)
+		require.NoError(t, err)
+		assert.Equal(t, "一年一班", then.Class)
+
+		// When: 以過期的版本還原
+		_, err = uc.RevertStudent(ctx, "2024001", created.Version, created.Version)
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeVersionConflict, studentErr.Type)
+
+		// When: 我將學生還原到第一個版本
+		reverted, err := uc.RevertStudent(ctx, "2024001", created.Version, moved.Version)
+		require.NoError(t, err)
+
+		// Then: 欄位應該回到第一個版本，並產生新的版本
+		assert.Equal(t, "一年一班", reverted.Class)
+		assert.Equal(t, moved.Version+1, reverted.Version)
+
+		history, err := uc.GetStudentHistory(ctx, "2024001")
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, student.AuditReverted, history[2].Action)
+
+		// And: 不存在的版本應該返回找不到
+		_, err = uc.RevertStudent(ctx, "2024001", 99, student.AnyVersion)
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNotFound, studentErr.Type)
+	})
+}
+
+func TestRevertStudent_StudentNumberTaken(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 學生的學號被更改，原學號已分配給另一位學生
+		uc := NewUseCase(repo)
+		ctx := context.Background()
+
+		created, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+		newNumber := "2024999"
+		_, err = uc.UpdateStudent(ctx, "2024001", &student.UpdateStudentRequest{StudentNumber: &newNumber})
+		require.NoError(t, err)
+		_, err = uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "李小華",
+			Email:         "lee@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+
+		// When: 我將學生還原到原本的版本
+		_, err = uc.RevertStudent(ctx, "2024999", created.Version, student.AnyVersion)
+
+		// Then: 還原應該與一般更新一樣因學號重複而失敗
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeStudentNumberAlreadyExists, studentErr.Type)
+	})
+}
+
+// recordingPublisher records the events published to it.
+type recordingPublisher struct {
+	events []student.Event
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, events ...student.Event) {
+	p.events = append(p.events, events...)
+}
+
+func TestUseCase_PublishesDomainEvents(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 使用案例連接事件發布者
+		publisher := &recordingPublisher{}
+		uc := NewUseCase(repo, WithEventPublisher(publisher))
+		ctx := WithPrincipal(context.Background(), &student.Principal{Subject: "admin"})
+
+		// When: 我新增、更新（含一次無效更新）並刪除學生
+		_, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+
+		newClass := "一年二班"
+		_, err = uc.UpdateStudent(ctx, "2024001", &student.UpdateStudentRequest{Class: &newClass})
+		require.NoError(t, err)
+
+		invalidEmail := "invalid"
+		_, err = uc.UpdateStudent(ctx, "2024001", &student.UpdateStudentRequest{Email: &invalidEmail})
+		require.Error(t, err)
+
+		require.NoError(t, uc.DeleteStudent(ctx, "2024001", student.AnyVersion))
+
+		// Then: 每個成功的變更都應該發布對應的事件
+		require.Len(t, publisher.events, 3)
+
+		created, ok := publisher.events[0].(*student.StudentCreated)
+		require.True(t, ok)
+		assert.Equal(t, "2024001", created.Student.StudentNumber)
+		assert.Equal(t, "admin", created.Actor)
+
+		updated, ok := publisher.events[1].(*student.StudentUpdated)
+		require.True(t, ok)
+		assert.Equal(t, "一年二班", updated.Student.Class)
+		require.Len(t, updated.Changes, 1)
+		assert.Equal(t, "class", updated.Changes[0].Field)
+
+		deleted, ok := publisher.events[2].(*student.StudentDeleted)
+		require.True(t, ok)
+		assert.True(t, deleted.Student.IsDeleted())
+	})
+}
+
+func TestBatchStudents_PublishesEventsOnCommit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 使用案例連接事件發布者
+		publisher := &recordingPublisher{}
+		uc := NewUseCase(repo, WithEventPublisher(publisher))
+		ctx := context.Background()
+
+		create := func(number string) *student.BatchOperation {
+			return &student.BatchOperation{
+				Type: student.BatchCreate,
+				Create: &student.CreateStudentRequest{
+					StudentNumber: number,
+					Name:          "王小明",
+					Email:         "wang@school.edu",
+					Class:         "一年一班",
+				},
+			}
+		}
+
+		// When: 一個原子批次因重複學號而復原
+		report, err := uc.BatchStudents(ctx, []*student.BatchOperation{create("2024001"), create("2024001")}, true)
+		require.NoError(t, err)
+		require.False(t, report.Committed)
+
+		// Then: 不應該發布任何事件
+		assert.Empty(t, publisher.events)
+
+		// When: 一個原子批次成功提交
+		report, err = uc.BatchStudents(ctx, []*student.BatchOperation{create("2024001"), create("2024002")}, true)
+		require.NoError(t, err)
+		require.True(t, report.Committed)
+
+		// Then: 提交後才發布所有事件
+		require.Len(t, publisher.events, 2)
+		assert.Equal(t, student.EventStudentCreated, publisher.events[0].Type())
+		assert.Equal(t, student.EventStudentCreated, publisher.events[1].Type())
+	})
+}
+
+func TestUseCase_WritesOutboxWithChange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 一個空的儲存庫
+		uc := NewUseCase(repo)
+		ctx := context.Background()
+
+		// When: 我新增一位學生，並執行一個失敗的原子批次
+		created, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+			StudentNumber: "2024001",
+			Name:          "王小明",
+			Email:         "wang@school.edu",
+			Class:         "一年一班",
+		})
+		require.NoError(t, err)
+
+		report, err := uc.BatchStudents(ctx, []*student.BatchOperation{
+			{Type: student.BatchDelete, StudentNumber: "2024001"},
+			{Type: student.BatchDelete, StudentNumber: "9999999"},
+		}, true)
+		require.NoError(t, err)
+		require.False(t, report.Committed)
+
+		// Then: 只有已提交的變更會寫入 outbox，並以事件 ID 作為冪等鍵
+		messages, err := repo.FindPendingOutbox(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+
+		history, err := uc.GetStudentHistory(ctx, "2024001")
+		require.NoError(t, err)
+		assert.Equal(t, history[0].ID, messages[0].ID)
+		assert.Equal(t, student.EventStudentCreated, messages[0].EventType)
+		assert.Equal(t, created.ID, messages[0].StudentID)
+
+		var envelope student.EventEnvelope
+		require.NoError(t, json.Unmarshal(messages[0].Payload, &envelope))
+		assert.Equal(t, student.EventStudentCreated, envelope.Type)
+		assert.Equal(t, "2024001", envelope.Student.StudentNumber)
+	})
+}
+
+func TestGetChanges_PagesFromCursor(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統中已新增三位學生，並刪除其中一位
+		uc := NewUseCase(repo)
+		ctx := context.Background()
+		for _, number := range []string{"2024001", "2024002", "2024003"} {
+			_, err := uc.CreateStudent(ctx, &student.CreateStudentRequest{
+				StudentNumber: number,
+				Name:          "王小明",
+				Email:         "s" + number + "@school.edu",
+				Class:         "一年一班",
+			})
+			require.NoError(t, err)
+		}
+		require.NoError(t, uc.DeleteStudent(ctx, "2024001", student.AnyVersion))
+
+		// When: 同步程式每次讀取兩筆異動
+		first, err := uc.GetChanges(ctx, 0, 2)
+		require.NoError(t, err)
+
+		// Then: 依序號回傳新增的學生，並提示還有下一頁
+		require.Len(t, first.Changes, 2)
+		assert.True(t, first.HasMore)
+		assert.Equal(t, first.Changes[1].Sequence, first.NextSince)
+		assert.Equal(t, student.ChangeUpsert, first.Changes[0].Type)
+		assert.Equal(t, "2024002", first.Changes[0].Student.StudentNumber)
+
+		// When: 從上一頁的游標繼續讀取
+		second, err := uc.GetChanges(ctx, first.NextSince, 2)
+		require.NoError(t, err)
+
+		// Then: 取得刪除的墓碑，且沒有更多異動
+		require.Len(t, second.Changes, 1)
+		assert.False(t, second.HasMore)
+		assert.Equal(t, student.ChangeDelete, second.Changes[0].Type)
+		assert.Equal(t, "2024001", second.Changes[0].StudentNumber)
+
+		// And: 已同步到最新時，游標保持不變
+		empty, err := uc.GetChanges(ctx, second.NextSince, 0)
+		require.NoError(t, err)
+		assert.Empty(t, empty.Changes)
+		assert.Equal(t, second.NextSince, empty.NextSince)
+
+		// And: 不合法的游標與筆數會被拒絕
+		_, err = uc.GetChanges(ctx, -1, 0)
+		assert.Error(t, err)
+		_, err = uc.GetChanges(ctx, 0, student.MaxPageSize+1)
+		assert.Error(t, err)
+	})
 }
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"todo/internal/domain/student"
+	studentrepo "todo/internal/repository/student"
+)
+
+// WebhookUseCase handles the webhook subscriptions through which admins
+// are notified of student changes. Deliveries themselves are made by the
+// webhook package.
+type WebhookUseCase struct {
+	repo studentrepo.WebhookRepository
+}
+
+// NewWebhookUseCase creates a new WebhookUseCase.
+func NewWebhookUseCase(repo studentrepo.WebhookRepository) *WebhookUseCase {
+	return &WebhookUseCase{repo: repo}
+}
+
+// CreateWebhook registers a subscription. Without a secret in req a
+// random one is generated; either way the returned subscription carries
+// it, and it is never returned again.
+func (uc *WebhookUseCase) CreateWebhook(ctx context.Context, req *student.CreateWebhookRequest) (*student.WebhookSubscription, error) {
+	if err := validateCreateWebhookRequest(req); err != nil {
+		return nil, err
+	}
+
+	s := &student.WebhookSubscription{
+		ID:         uuid.New().String(),
+		URL:        req.URL,
+		Secret:     req.Secret,
+		EventTypes: append([]student.EventType{}, req.EventTypes...),
+		Classes:    append([]string{}, req.Classes...),
+		CreatedAt:  time.Now().UTC(),
+	}
+	if s.Secret == "" {
+		s.Secret = rand.Text()
+	}
+	if err := uc.repo.SaveWebhook(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetWebhook retrieves a subscription by ID.
+func (uc *WebhookUseCase) GetWebhook(ctx context.Context, id string) (*student.WebhookSubscription, error) {
+	return uc.repo.FindWebhook(ctx, id)
+}
+
+// ListWebhooks retrieves every subscription, oldest first.
+func (uc *WebhookUseCase) ListWebhooks(ctx context.Context) ([]*student.WebhookSubscription, error) {
+	return uc.repo.FindAllWebhooks(ctx)
+}
+
+// DeleteWebhook removes a subscription; its pending deliveries are
+// dropped and its delivery log is discarded.
+func (uc *WebhookUseCase) DeleteWebhook(ctx context.Context, id string) error {
+	return uc.repo.DeleteWebhook(ctx, id)
+}
+
+// ListWebhookDeliveries retrieves the delivery log of a subscription,
+// newest first. A non-empty status keeps only the deliveries in it, e.g.
+// the dead-lettered ones.
+func (uc *WebhookUseCase) ListWebhookDeliveries(ctx context.Context, id string, status student.WebhookDeliveryStatus) ([]*student.WebhookDelivery, error) {
+	switch status {
+	case "", student.WebhookDeliveryPending, student.WebhookDeliverySucceeded, student.WebhookDeliveryDead:
+	default:
+		return nil, student.NewInvalidQueryParameterError("status", string(status))
+	}
+
+	if _, err := uc.repo.FindWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	deliveries, err := uc.repo.FindWebhookDeliveries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != "" {
+		deliveries = slices.DeleteFunc(deliveries, func(d *student.WebhookDelivery) bool {
+			return d.Status != status
+		})
+	}
+	return deliveries, nil
+}
+
+// validateCreateWebhookRequest reports every violation in req at once.
+func validateCreateWebhookRequest(req *student.CreateWebhookRequest) error {
+	var errs student.ValidationErrors
+	if strings.TrimSpace(req.URL) == "" {
+		errs.Add(student.NewMissingRequiredFieldError("url"))
+	} else if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.Add(student.NewInvalidWebhookURLError())
+	}
+	for _, t := range req.EventTypes {
+		if !slices.Contains(student.EventTypes, t) {
+			errs.Add(student.NewInvalidEventTypeError(string(t)))
+		}
+	}
+	return errs.Err()
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/internal/domain/student"
+)
+
+func TestCreateWebhook_GeneratesSecret(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統已初始化
+		uc := NewWebhookUseCase(repo)
+		ctx := context.Background()
+
+		// When: 管理員註冊只關心一年一班新增與刪除事件、未指定密鑰的 webhook
+		created, err := uc.CreateWebhook(ctx, &student.CreateWebhookRequest{
+			URL:        "https://hooks.example.com/students",
+			EventTypes: []student.EventType{student.EventStudentCreated, student.EventStudentDeleted},
+			Classes:    []string{"一年一班"},
+		})
+		require.NoError(t, err)
+
+		// Then: 系統產生密鑰，並保存篩選條件
+		assert.NotEmpty(t, created.ID)
+		assert.NotEmpty(t, created.Secret)
+
+		got, err := uc.GetWebhook(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, created.Secret, got.Secret)
+		assert.Equal(t, []student.EventType{student.EventStudentCreated, student.EventStudentDeleted}, got.EventTypes)
+		assert.Equal(t, []string{"一年一班"}, got.Classes)
+	})
+}
+
+func TestCreateWebhook_AggregatesValidationErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 系統已初始化
+		uc := NewWebhookUseCase(repo)
+
+		// When: 管理員以 ftp 網址與未知的事件類型註冊 webhook
+		_, err := uc.CreateWebhook(context.Background(), &student.CreateWebhookRequest{
+			URL:        "ftp://hooks.example.com",
+			EventTypes: []student.EventType{student.EventStudentCreated, "student.graduated"},
+		})
+
+		// Then: 系統應該回報所有欄位錯誤
+		var validationErrs student.ValidationErrors
+		require.ErrorAs(t, err, &validationErrs)
+		require.Len(t, validationErrs, 2)
+		assert.Equal(t, student.ErrorTypeInvalidWebhookURL, validationErrs[0].Type)
+		assert.Equal(t, student.ErrorTypeInvalidEventType, validationErrs[1].Type)
+	})
+}
+
+func TestListWebhookDeliveries_FiltersByStatus(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo testStore) {
+		// Given: 一個 webhook 有一筆已成功與一筆已放棄的派送紀錄
+		uc := NewWebhookUseCase(repo)
+		ctx := context.Background()
+		sub, err := uc.CreateWebhook(ctx, &student.CreateWebhookRequest{URL: "https://hooks.example.com"})
+		require.NoError(t, err)
+		for i, status := range []student.WebhookDeliveryStatus{student.WebhookDeliverySucceeded, student.WebhookDeliveryDead} {
+			d := &student.WebhookDelivery{
+				ID:             string(status),
+				SubscriptionID: sub.ID,
+				EventID:        string(rune('a' + i)),
+				Payload:        []byte(`{}`),
+				Status:         student.WebhookDeliveryPending,
+			}
+			require.NoError(t, repo.EnqueueWebhookDelivery(ctx, d))
+			d.Status = status
+			require.NoError(t, repo.UpdateWebhookDelivery(ctx, d))
+		}
+
+		// When: 查詢已放棄的派送紀錄
+		dead, err := uc.ListWebhookDeliveries(ctx, sub.ID, student.WebhookDeliveryDead)
+
+		// Then: 只回傳死信紀錄
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, student.WebhookDeliveryDead, dead[0].Status)
+
+		all, err := uc.ListWebhookDeliveries(ctx, sub.ID, "")
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		// And: 未知的狀態與不存在的 webhook 會被拒絕
+		_, err = uc.ListWebhookDeliveries(ctx, sub.ID, "lost")
+		assert.Error(t, err)
+		_, err = uc.ListWebhookDeliveries(ctx, "missing-id", "")
+		var studentErr *student.StudentError
+		require.ErrorAs(t, err, &studentErr)
+		assert.Equal(t, student.ErrorTypeWebhookNotFound, studentErr.Type)
+	})
+}
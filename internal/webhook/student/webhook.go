@@ -0,0 +1,227 @@
+// Package webhook delivers student events to the webhook subscriptions
+// registered by admins, as signed HTTP POST requests.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"todo/internal/domain/student"
+)
+
+// Store is the part of the webhook repository used to deliver events.
+type Store interface {
+	FindWebhook(ctx context.Context, id string) (*student.WebhookSubscription, error)
+	FindAllWebhooks(ctx context.Context) ([]*student.WebhookSubscription, error)
+	EnqueueWebhookDelivery(ctx context.Context, d *student.WebhookDelivery) error
+	FindDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*student.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, d *student.WebhookDelivery) error
+}
+
+// Sink is an outbox sink that turns each student event into a delivery
+// for every subscription matching it. Enqueueing is idempotent, so the
+// relay may hand it the same message again.
+type Sink struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewSink creates a sink enqueueing deliveries in store.
+func NewSink(store Store) *Sink {
+	return &Sink{store: store, now: time.Now}
+}
+
+// Name identifies the sink in the outbox delivery state.
+func (s *Sink) Name() string { return "webhooks" }
+
+// Deliver enqueues a delivery of m for every matching subscription.
+func (s *Sink) Deliver(ctx context.Context, m *student.OutboxMessage) error {
+	var env student.EventEnvelope
+	if err := json.Unmarshal(m.Payload, &env); err != nil {
+		return fmt.Errorf("decode event %s: %w", m.ID, err)
+	}
+
+	subscriptions, err := s.store.FindAllWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, sub := range subscriptions {
+		if !sub.Matches(&env) {
+			continue
+		}
+		err := s.store.EnqueueWebhookDelivery(ctx, &student.WebhookDelivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: sub.ID,
+			EventID:        m.ID,
+			EventType:      m.EventType,
+			Payload:        m.Payload,
+			Status:         student.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+		if err != nil && !isWebhookNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dispatcher POSTs due deliveries to their subscriptions. A delivery
+// succeeds on any 2xx response; a failed attempt is retried with
+// exponential backoff until MaxAttempts, after which the delivery is
+// dead-lettered: it stays in the delivery log as dead and is not retried.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+
+	BatchSize   int           // deliveries read per store call
+	MaxAttempts int           // attempts before a delivery is dead-lettered
+	MinBackoff  time.Duration // delay after the first failed attempt
+	MaxBackoff  time.Duration // upper bound of the delay between attempts
+
+	now func() time.Time
+}
+
+// NewDispatcher creates a dispatcher for the deliveries in store with
+// default settings.
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		BatchSize:   100,
+		MaxAttempts: 8,
+		MinBackoff:  10 * time.Second,
+		MaxBackoff:  time.Hour,
+		now:         time.Now,
+	}
+}
+
+// Run dispatches due deliveries every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := d.RunOnce(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("webhook: dispatcher: %v", err)
+				}
+				break
+			}
+			if n < d.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce makes one attempt for up to BatchSize due deliveries and returns
+// how many it attempted.
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.store.FindDueWebhookDeliveries(ctx, d.now(), d.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		sub, err := d.store.FindWebhook(ctx, delivery.SubscriptionID)
+		if isWebhookNotFound(err) {
+			// Deleted since; its deliveries went with it.
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		status, sendErr := d.send(ctx, sub, delivery)
+		if ctx.Err() != nil {
+			// An interrupted attempt does not count.
+			return 0, ctx.Err()
+		}
+
+		now := d.now()
+		delivery.Attempts++
+		delivery.ResponseStatus = status
+		switch {
+		case sendErr == nil:
+			delivery.Status = student.WebhookDeliverySucceeded
+			delivery.LastError = ""
+			delivery.DeliveredAt = &now
+		case delivery.Attempts >= d.MaxAttempts:
+			delivery.Status = student.WebhookDeliveryDead
+			delivery.LastError = sendErr.Error()
+			log.Printf("webhook: giving up on %s %s to %s after %d attempts: %v",
+				delivery.EventType, delivery.EventID, sub.URL, delivery.Attempts, sendErr)
+		default:
+			delivery.LastError = sendErr.Error()
+			delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+		}
+		if err := d.store.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// send POSTs the payload of delivery to sub, signed with its secret, and
+// returns the response status, or 0 if there was no response.
+func (d *Dispatcher) send(ctx context.Context, sub *student.WebhookSubscription, delivery *student.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, delivery.EventID)
+	req.Header.Set(EventTypeHeader, string(delivery.EventType))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay after the given number of failed attempts:
+// MinBackoff doubled for each attempt after the first, up to MaxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.MinBackoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.MaxBackoff)
+}
+
+// isWebhookNotFound reports whether err says the subscription is gone.
+func isWebhookNotFound(err error) bool {
+	var studentErr *student.StudentError
+	return errors.As(err, &studentErr) && studentErr.Type == student.ErrorTypeWebhookNotFound
+}
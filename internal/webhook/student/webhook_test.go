@@ -0,0 +1,284 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"todo/internal/domain/student"
+	outbox "todo/internal/outbox/student"
+	studentrepo "todo/internal/repository/student"
+	"todo/internal/repository/student/repositorytest"
+	studentusecase "todo/internal/usecase/student"
+)
+
+// receiver is a local webhook endpoint that verifies signatures like a
+// real receiver and answers with status.
+type receiver struct {
+	*httptest.Server
+	secret string
+
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+	verified []error
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	t.Helper()
+	rcv := &receiver{secret: secret, status: http.StatusNoContent}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		rcv.requests = append(rcv.requests, r)
+		rcv.bodies = append(rcv.bodies, body)
+		rcv.verified = append(rcv.verified, Verify(secret, r.Header.Get(SignatureHeader),
+			r.Header.Get(TimestampHeader), body, time.Now(), DefaultTolerance))
+		w.WriteHeader(rcv.status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+// newTestDispatcher returns a dispatcher over a memory repository.
+func newTestDispatcher(t *testing.T) (*Dispatcher, *studentrepo.MemoryRepository) {
+	t.Helper()
+	repo := studentrepo.NewMemoryRepository()
+	return NewDispatcher(repo), repo
+}
+
+// updatedMessage returns the outbox message announcing that s moved from
+// class from to its current class.
+func updatedMessage(t *testing.T, s *student.Student, from string) *student.OutboxMessage {
+	t.Helper()
+	before := s.Clone()
+	before.Class = from
+	entry := repositorytest.NewAuditEntry(s, student.AuditUpdated)
+	entry.Changes = student.Diff(before, s)
+	m, err := student.NewOutboxMessage(student.NewEvent(entry, s))
+	require.NoError(t, err)
+	return m
+}
+
+func TestSink_EnqueuesMatchingSubscriptions(t *testing.T) {
+	// Given: 四個篩選條件不同的訂閱
+	repo := studentrepo.NewMemoryRepository()
+	ctx := context.Background()
+	all := repositorytest.NewWebhook("https://all.example.com")
+	createdOnly := repositorytest.NewWebhook("https://created.example.com")
+	createdOnly.EventTypes = []student.EventType{student.EventStudentCreated}
+	deletedOnly := repositorytest.NewWebhook("https://deleted.example.com")
+	deletedOnly.EventTypes = []student.EventType{student.EventStudentDeleted}
+	otherClass := repositorytest.NewWebhook("https://class.example.com")
+	otherClass.Classes = []string{"一年二班"}
+	for _, s := range []*student.WebhookSubscription{all, createdOnly, deletedOnly, otherClass} {
+		require.NoError(t, repo.SaveWebhook(ctx, s))
+	}
+	sink := NewSink(repo)
+
+	// When: 一年一班建立學生的事件送達兩次
+	m := repositorytest.NewOutboxMessage(repositorytest.NewStudent("2024001"), time.Now())
+	require.NoError(t, sink.Deliver(ctx, m))
+	require.NoError(t, sink.Deliver(ctx, m))
+
+	// Then: 只有符合類型與班級的訂閱各收到一筆待送紀錄
+	for _, s := range []*student.WebhookSubscription{all, createdOnly} {
+		deliveries, err := repo.FindWebhookDeliveries(ctx, s.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1, s.URL)
+		assert.Equal(t, m.ID, deliveries[0].EventID)
+		assert.Equal(t, student.WebhookDeliveryPending, deliveries[0].Status)
+		assert.JSONEq(t, string(m.Payload), string(deliveries[0].Payload))
+	}
+	for _, s := range []*student.WebhookSubscription{deletedOnly, otherClass} {
+		deliveries, err := repo.FindWebhookDeliveries(ctx, s.ID)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries, s.URL)
+	}
+}
+
+func TestSink_ClassFilterMatchesStudentsMovedAway(t *testing.T) {
+	// Given: 訂閱一年二班的 webhook
+	repo := studentrepo.NewMemoryRepository()
+	ctx := context.Background()
+	sub := repositorytest.NewWebhook("https://class.example.com")
+	sub.Classes = []string{"一年二班"}
+	require.NoError(t, repo.SaveWebhook(ctx, sub))
+
+	// When: 學生從一年二班轉到一年一班
+	moved := repositorytest.NewStudent("2024001")
+	require.NoError(t, NewSink(repo).Deliver(ctx, updatedMessage(t, moved, "一年二班")))
+
+	// Then: 原班級的訂閱仍會收到通知
+	deliveries, err := repo.FindWebhookDeliveries(ctx, sub.ID)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+}
+
+func TestDispatcher_PostsSignedPayload(t *testing.T) {
+	// Given: 一個本機接收端與指向它的訂閱
+	dispatcher, repo := newTestDispatcher(t)
+	ctx := context.Background()
+	rcv := newReceiver(t, "s3cret")
+	sub := repositorytest.NewWebhook(rcv.URL)
+	sub.Secret = rcv.secret
+	require.NoError(t, repo.SaveWebhook(ctx, sub))
+	m := repositorytest.NewOutboxMessage(repositorytest.NewStudent("2024001"), time.Now())
+	require.NoError(t, NewSink(repo).Deliver(ctx, m))
+
+	// When: 派送工作執行一次
+	n, err := dispatcher.RunOnce(ctx)
+	require.NoError(t, err)
+
+	// Then: 接收端收到簽章有效的事件內容
+	assert.Equal(t, 1, n)
+	require.Len(t, rcv.requests, 1)
+	assert.NoError(t, rcv.verified[0])
+	assert.JSONEq(t, string(m.Payload), string(rcv.bodies[0]))
+	assert.Equal(t, m.ID, rcv.requests[0].Header.Get(EventIDHeader))
+	assert.Equal(t, string(student.EventStudentCreated), rcv.requests[0].Header.Get(EventTypeHeader))
+
+	// And: 派送紀錄標記為成功
+	deliveries, err := repo.FindWebhookDeliveries(ctx, sub.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, student.WebhookDeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+
+	// And: 使用錯誤密鑰驗證會失敗
+	assert.ErrorIs(t, Verify("wrong", rcv.requests[0].Header.Get(SignatureHeader),
+		rcv.requests[0].Header.Get(TimestampHeader), rcv.bodies[0], time.Now(), DefaultTolerance), ErrInvalidSignature)
+}
+
+func TestWebhooks_DeliverStudentMutations(t *testing.T) {
+	// Given: 一個訂閱一年一班所有事件的接收端
+	repo := studentrepo.NewMemoryRepository()
+	ctx := context.Background()
+	rcv := newReceiver(t, "s3cret")
+	_, err := studentusecase.NewWebhookUseCase(repo).CreateWebhook(ctx, &student.CreateWebhookRequest{
+		URL:     rcv.URL,
+		Secret:  rcv.secret,
+		Classes: []string{"一年一班"},
+	})
+	require.NoError(t, err)
+
+	// When: 透過 use case 新增、修改並刪除學生，再執行轉送與派送工作
+	uc := studentusecase.NewUseCase(repo)
+	_, err = uc.CreateStudent(ctx, &student.CreateStudentRequest{
+		StudentNumber: "2024001",
+		Name:          "王小明",
+		Email:         "wang@school.edu",
+		Class:         "一年一班",
+	})
+	require.NoError(t, err)
+	name := "王大明"
+	_, err = uc.UpdateStudent(ctx, "2024001", &student.UpdateStudentRequest{Name: &name})
+	require.NoError(t, err)
+	require.NoError(t, uc.DeleteStudent(ctx, "2024001", 2))
+
+	_, err = outbox.NewRelay(repo, NewSink(repo)).RunOnce(ctx)
+	require.NoError(t, err)
+	_, err = NewDispatcher(repo).RunOnce(ctx)
+	require.NoError(t, err)
+
+	// Then: 接收端依序收到三個簽章有效的事件
+	require.Len(t, rcv.requests, 3)
+	var types []string
+	for i, r := range rcv.requests {
+		assert.NoError(t, rcv.verified[i])
+		types = append(types, r.Header.Get(EventTypeHeader))
+	}
+	assert.Equal(t, []string{"student.created", "student.updated", "student.deleted"}, types)
+}
+
+func TestDispatcher_RetriesWithBackoffThenDeadLetters(t *testing.T) {
+	// Given: 一個持續回應 503 的接收端
+	dispatcher, repo := newTestDispatcher(t)
+	dispatcher.MaxAttempts = 3
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	dispatcher.now = func() time.Time { return now }
+	ctx := context.Background()
+	rcv := newReceiver(t, "s3cret")
+	rcv.status = http.StatusServiceUnavailable
+	sub := repositorytest.NewWebhook(rcv.URL)
+	require.NoError(t, repo.SaveWebhook(ctx, sub))
+	m := repositorytest.NewOutboxMessage(repositorytest.NewStudent("2024001"), now)
+	require.NoError(t, repo.EnqueueWebhookDelivery(ctx, repositorytest.NewWebhookDelivery(sub, m, now)))
+
+	// When: 第一次派送失敗
+	_, err := dispatcher.RunOnce(ctx)
+	require.NoError(t, err)
+
+	// Then: 紀錄保留回應狀態，並在退避時間後才重試
+	due, err := repo.FindDueWebhookDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	due, err = repo.FindDueWebhookDeliveries(ctx, now.Add(dispatcher.MinBackoff), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, 1, due[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, due[0].ResponseStatus)
+	assert.Contains(t, due[0].LastError, "503")
+
+	// When: 之後的重試持續失敗直到上限
+	for range 2 {
+		now = now.Add(2 * time.Hour)
+		_, err = dispatcher.RunOnce(ctx)
+		require.NoError(t, err)
+	}
+
+	// Then: 紀錄進入死信狀態，接收端恢復後也不再重送
+	rcv.status = http.StatusOK
+	n, err := dispatcher.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Len(t, rcv.requests, 3)
+
+	deliveries, err := repo.FindWebhookDeliveries(ctx, sub.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, student.WebhookDeliveryDead, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	dispatcher := NewDispatcher(nil)
+	dispatcher.MinBackoff = time.Second
+	dispatcher.MaxBackoff = 5 * time.Second
+
+	assert.Equal(t, time.Second, dispatcher.backoff(1))
+	assert.Equal(t, 2*time.Second, dispatcher.backoff(2))
+	assert.Equal(t, 4*time.Second, dispatcher.backoff(3))
+	assert.Equal(t, 5*time.Second, dispatcher.backoff(4))
+	assert.Equal(t, 5*time.Second, dispatcher.backoff(100))
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":"1"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("s3cret", timestamp, body)
+
+	assert.NoError(t, Verify("s3cret", signature, timestamp, body, now, DefaultTolerance))
+	assert.ErrorIs(t, Verify("s3cret", signature, timestamp, []byte(`{"id":"2"}`), now, DefaultTolerance),
+		ErrInvalidSignature, "tampered body")
+	assert.ErrorIs(t, Verify("s3cret", "sha256=00", timestamp, body, now, DefaultTolerance),
+		ErrInvalidSignature, "forged signature")
+
+	// A captured request replayed later is rejected even with its
+	// original signature.
+	assert.ErrorIs(t, Verify("s3cret", signature, timestamp, body, now.Add(time.Hour), DefaultTolerance),
+		ErrStaleTimestamp)
+}